@@ -0,0 +1,41 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"os"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+	"github.com/markkurossi/blackbox-os/lib/coreutils"
+)
+
+func main() {
+	env := &coreutils.Env{
+		FS:     bbos.FS{},
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+		Exec:   exec,
+	}
+	os.Exit(coreutils.Find(env, os.Args))
+}
+
+// exec runs the command with the standard files of find and waits
+// for it to terminate.
+func exec(argv []string) (int, error) {
+	pid, err := bbos.Spawn(argv, &bbos.ProcAttr{
+		Files: []int{0, 1, 2},
+	})
+	if err != nil {
+		return 0, err
+	}
+	ws, err := bbos.Wait(pid)
+	if err != nil {
+		return 0, err
+	}
+	return ws.ExitStatus(), nil
+}
@@ -20,7 +20,7 @@ func init() {
 	})
 }
 
-func cmd_date(args []string) {
+func cmd_date(stdio *Stdio, args []string) {
 	now := time.Now()
-	fmt.Printf("%s\n", now.Format(time.UnixDate))
+	fmt.Fprintf(stdio.Stdout, "%s\n", now.Format(time.UnixDate))
 }
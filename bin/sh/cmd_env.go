@@ -0,0 +1,96 @@
+//
+// cmd_env.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name: "env",
+			Cmd:  cmd_env,
+		},
+		Builtin{
+			Name: "export",
+			Cmd:  cmd_export,
+		},
+		Builtin{
+			Name: "unset",
+			Cmd:  cmd_unset,
+		},
+	}...)
+}
+
+func validName(name string) bool {
+	for idx, r := range name {
+		if idx == 0 && !isNameStart(r) || !isNameChar(r) {
+			return false
+		}
+	}
+	return len(name) > 0
+}
+
+func cmd_env(stdio *Stdio, args []string) {
+	for _, e := range os.Environ() {
+		fmt.Fprintf(stdio.Stdout, "%s\n", e)
+	}
+}
+
+func cmd_export(stdio *Stdio, args []string) {
+	if len(args) < 2 {
+		env := os.Environ()
+		sort.Strings(env)
+		for _, e := range env {
+			parts := strings.SplitN(e, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			fmt.Fprintf(stdio.Stdout, "export %s=\"%s\"\n", parts[0],
+				strings.ReplaceAll(parts[1], "\"", "\\\""))
+		}
+		return
+	}
+	for _, arg := range args[1:] {
+		parts := strings.SplitN(arg, "=", 2)
+		if !validName(parts[0]) {
+			fmt.Fprintf(stdio.Stderr, "export: `%s': not a valid identifier\n",
+				arg)
+			continue
+		}
+		var err error
+		if len(parts) == 2 {
+			err = setVar(parts[0], parts[1])
+		}
+		if err == nil {
+			err = exportVar(parts[0])
+		}
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "export: %s\n", err)
+		}
+	}
+}
+
+func cmd_unset(stdio *Stdio, args []string) {
+	for _, arg := range args[1:] {
+		if !validName(arg) {
+			fmt.Fprintf(stdio.Stderr, "unset: `%s': not a valid identifier\n",
+				arg)
+			continue
+		}
+		err := unsetVar(arg)
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "unset: %s\n", err)
+		}
+	}
+}
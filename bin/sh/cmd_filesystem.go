@@ -11,11 +11,9 @@ package main
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 
 	"github.com/markkurossi/blackbox-os/lib/bbos"
-	"github.com/markkurossi/blackbox-os/lib/readline"
 )
 
 func init() {
@@ -28,27 +26,27 @@ func init() {
 			Name: "cd",
 			Cmd:  cmd_cd,
 		},
-		Builtin{
-			Name: "ls",
-			Cmd:  cmd_ls,
-		},
 		Builtin{
 			Name: "cat",
 			Cmd:  cmd_cat,
 		},
+		Builtin{
+			Name: "mount",
+			Cmd:  cmd_mount,
+		},
 	}...)
 }
 
-func cmd_pwd(args []string) {
+func cmd_pwd(stdio *Stdio, args []string) {
 	str, err := bbos.Getwd()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "pwd: %s\n", err)
+		fmt.Fprintf(stdio.Stderr, "pwd: %s\n", err)
 	} else {
-		fmt.Printf("%s\n", str)
+		fmt.Fprintf(stdio.Stdout, "%s\n", str)
 	}
 }
 
-func cmd_cd(args []string) {
+func cmd_cd(stdio *Stdio, args []string) {
 	var err error
 	if len(args) < 2 {
 		err = bbos.Chdir("/")
@@ -56,55 +54,40 @@ func cmd_cd(args []string) {
 		err = bbos.Chdir(args[1])
 	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "cd: %s\n", err)
+		fmt.Fprintf(stdio.Stderr, "cd: %s\n", err)
+		status = 1
 	}
 }
 
-func cmd_ls(args []string) {
-	args = args[1:]
-	switch len(args) {
-	case 0:
-		ls(".")
-
-	case 1:
-		ls(args[0])
-
-	default:
-		for idx, arg := range args {
-			if idx > 0 {
-				fmt.Println()
-			}
-			fmt.Printf("%s:\n", arg)
-			ls(arg)
+func cmd_cat(stdio *Stdio, args []string) {
+	if len(args) < 2 {
+		_, err := io.Copy(stdio.Stdout, stdio.Stdin)
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "cat: %s\n", err)
 		}
-	}
-}
-
-func ls(dir string) {
-	files, err := ioutil.ReadDir(dir)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "ls: %s\n", err)
 		return
 	}
-	var names []string
-	for _, f := range files {
-		names = append(names, f.Name())
-	}
-	readline.Tabulate(names, os.Stdout)
-}
-
-func cmd_cat(args []string) {
 	for i := 1; i < len(args); i++ {
 		file, err := os.Open(args[i])
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "cat: %s: %s\n", args[i], err)
+			fmt.Fprintf(stdio.Stderr, "cat: %s: %s\n", args[i], err)
 			continue
 		}
-		defer file.Close()
-
-		_, err = io.Copy(os.Stdout, file)
+		_, err = io.Copy(stdio.Stdout, file)
+		file.Close()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "cat: %s: %s\n", args[i], err)
+			fmt.Fprintf(stdio.Stderr, "cat: %s: %s\n", args[i], err)
 		}
 	}
 }
+
+func cmd_mount(stdio *Stdio, args []string) {
+	mounts, err := bbos.Mounts()
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "mount: %s\n", err)
+		return
+	}
+	for _, m := range mounts {
+		fmt.Fprintf(stdio.Stdout, "%s on %s type %s\n", m.Type, m.Path, m.Type)
+	}
+}
@@ -0,0 +1,86 @@
+//
+// cmd_kill.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+)
+
+func init() {
+	builtin = append(builtin, Builtin{
+		Name: "kill",
+		Cmd:  cmd_kill,
+	})
+}
+
+func cmd_kill(stdio *Stdio, args []string) {
+	sig := bbos.SIGTERM
+	args = args[1:]
+
+	if len(args) > 0 && args[0] == "-l" {
+		for _, s := range bbos.Signals() {
+			fmt.Fprintf(stdio.Stdout, "%2d) %s\n", int(s),
+				strings.TrimPrefix(s.String(), "SIG"))
+		}
+		return
+	}
+	if len(args) > 1 && args[0] == "-s" {
+		s, err := bbos.ParseSignal(args[1])
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "kill: %s\n", err)
+			return
+		}
+		sig = s
+		args = args[2:]
+	} else if len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' &&
+		args[0] != "--" {
+		s, err := bbos.ParseSignal(args[0][1:])
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "kill: %s\n", err)
+			return
+		}
+		sig = s
+		args = args[1:]
+	}
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		fmt.Fprintf(stdio.Stderr,
+			"Usage: kill [-s signal | -signal] pid | %%job...\n       kill -l\n")
+		return
+	}
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "%") {
+			job, err := findJob(arg)
+			if err != nil {
+				fmt.Fprintf(stdio.Stderr, "kill: %s\n", err)
+				continue
+			}
+			err = bbos.Kill(-job.Pgid, sig)
+			if err != nil {
+				fmt.Fprintf(stdio.Stderr, "kill: %s: %s\n", arg, err)
+			}
+			continue
+		}
+		pid, err := strconv.Atoi(arg)
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "kill: %s: invalid process ID\n", arg)
+			continue
+		}
+		err = bbos.Kill(pid, sig)
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "kill: %d: %s\n", pid, err)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+//
+// cmd_ls.go
+//
+// Copyright (c) 2018-2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+)
+
+func init() {
+	builtin = append(builtin, Builtin{
+		Name: "ls",
+		Cmd:  cmd_ls,
+	})
+}
+
+const (
+	colorReset  = "\x1b[0m"
+	colorDir    = "\x1b[1;34m"
+	colorExec   = "\x1b[1;32m"
+	colorLink   = "\x1b[1;36m"
+	colorDevice = "\x1b[1;33m"
+	colorPipe   = "\x1b[33m"
+	colorSocket = "\x1b[1;35m"
+)
+
+type lsOptions struct {
+	long      bool
+	all       bool
+	human     bool
+	byTime    bool
+	reverse   bool
+	recursive bool
+	single    bool
+	color     bool
+}
+
+// lsEntry describes a file to list. The name is the name to display
+// and path is the path to the file.
+type lsEntry struct {
+	name string
+	path string
+	info os.FileInfo
+}
+
+func cmd_ls(stdio *Stdio, args []string) {
+	var opts lsOptions
+	var operands []string
+
+	for idx := 1; idx < len(args); idx++ {
+		arg := args[idx]
+		if arg == "--" {
+			operands = append(operands, args[idx+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			operands = append(operands, arg)
+			continue
+		}
+		for _, r := range arg[1:] {
+			switch r {
+			case 'l':
+				opts.long = true
+			case 'a':
+				opts.all = true
+			case 'h':
+				opts.human = true
+			case 't':
+				opts.byTime = true
+			case 'r':
+				opts.reverse = true
+			case 'R':
+				opts.recursive = true
+			case '1':
+				opts.single = true
+			default:
+				fmt.Fprintf(stdio.Stderr, "ls: invalid option -- '%c'\n", r)
+				fmt.Fprintf(stdio.Stderr, "usage: ls [-1ahlRrt] [file ...]\n")
+				return
+			}
+		}
+	}
+	if bbos.IsATTY(int(stdio.Stdout.Fd())) {
+		opts.color = true
+	} else {
+		opts.single = true
+	}
+	if len(operands) == 0 {
+		operands = []string{"."}
+	}
+
+	var files, dirs []lsEntry
+	for _, operand := range operands {
+		info, err := os.Stat(operand)
+		if err != nil {
+			fmt.Fprintf(stdio.Stderr, "ls: %s\n", err)
+			continue
+		}
+		entry := lsEntry{
+			name: operand,
+			path: operand,
+			info: info,
+		}
+		if info.IsDir() {
+			dirs = append(dirs, entry)
+		} else {
+			files = append(files, entry)
+		}
+	}
+	opts.sort(files)
+	opts.sort(dirs)
+
+	if len(files) > 0 {
+		opts.print(stdio.Stdout, files)
+	}
+	header := len(operands) > 1 || opts.recursive
+	for idx, dir := range dirs {
+		if idx > 0 || len(files) > 0 {
+			fmt.Fprintln(stdio.Stdout)
+		}
+		opts.listDir(stdio, dir.path, header)
+	}
+}
+
+// listDir lists the directory dir. If header is true, the directory
+// listing starts with the directory name.
+func (opts *lsOptions) listDir(stdio *Stdio, dir string, header bool) {
+	if header {
+		fmt.Fprintf(stdio.Stdout, "%s:\n", dir)
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "ls: %s\n", err)
+		return
+	}
+	var entries []lsEntry
+	if opts.all {
+		for _, name := range []string{".", ".."} {
+			info, err := os.Stat(path.Join(dir, name))
+			if err == nil {
+				entries = append(entries, lsEntry{
+					name: name,
+					path: path.Join(dir, name),
+					info: info,
+				})
+			}
+		}
+	}
+	for _, info := range infos {
+		if !opts.all && strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+		entries = append(entries, lsEntry{
+			name: info.Name(),
+			path: path.Join(dir, info.Name()),
+			info: info,
+		})
+	}
+	opts.sort(entries)
+	if opts.long {
+		var blocks int64
+		for _, e := range entries {
+			blocks += fileBlocks(e.info)
+		}
+		fmt.Fprintf(stdio.Stdout, "total %d\n", blocks)
+	}
+	opts.print(stdio.Stdout, entries)
+
+	if !opts.recursive {
+		return
+	}
+	for _, e := range entries {
+		if !e.info.IsDir() || e.name == "." || e.name == ".." {
+			continue
+		}
+		fmt.Fprintln(stdio.Stdout)
+		opts.listDir(stdio, e.path, true)
+	}
+}
+
+func (opts *lsOptions) sort(entries []lsEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if opts.reverse {
+			a, b = b, a
+		}
+		if opts.byTime && !a.info.ModTime().Equal(b.info.ModTime()) {
+			return a.info.ModTime().After(b.info.ModTime())
+		}
+		return a.name < b.name
+	})
+}
+
+func (opts *lsOptions) print(out io.Writer, entries []lsEntry) {
+	if opts.long {
+		opts.printLong(out, entries)
+		return
+	}
+	var names, display []string
+	for _, e := range entries {
+		name := e.name + typeSuffix(e.info.Mode())
+		names = append(names, name)
+		display = append(display, opts.colorize(e.name, e.info.Mode())+
+			typeSuffix(e.info.Mode()))
+	}
+	if opts.single {
+		for _, d := range display {
+			fmt.Fprintln(out, d)
+		}
+		return
+	}
+	tabulate(out, names, display)
+}
+
+func (opts *lsOptions) printLong(out io.Writer, entries []lsEntry) {
+	var rows [][]string
+	var widths [4]int
+
+	for _, e := range entries {
+		uid, gid := fileOwner(e.info)
+		row := []string{
+			fmt.Sprintf("%d", fileNlink(e.info)),
+			fmt.Sprintf("%d", uid),
+			fmt.Sprintf("%d", gid),
+			formatSize(e.info.Size(), opts.human),
+		}
+		for idx, col := range row {
+			if len(col) > widths[idx] {
+				widths[idx] = len(col)
+			}
+		}
+		rows = append(rows, row)
+	}
+	now := time.Now()
+	for idx, e := range entries {
+		row := rows[idx]
+		fmt.Fprintf(out, "%s %*s %-*s %-*s %*s %s %s%s\n",
+			modeString(e.info.Mode()),
+			widths[0], row[0], widths[1], row[1], widths[2], row[2],
+			widths[3], row[3],
+			formatTime(e.info.ModTime(), now),
+			opts.colorize(e.name, e.info.Mode()), typeSuffix(e.info.Mode()))
+	}
+}
+
+// colorize returns the name decorated with the color of the file
+// mode. The name is returned as-is if colors are not enabled.
+func (opts *lsOptions) colorize(name string, mode os.FileMode) string {
+	if !opts.color {
+		return name
+	}
+	var color string
+	switch {
+	case mode.IsDir():
+		color = colorDir
+	case mode&os.ModeSymlink != 0:
+		color = colorLink
+	case mode&os.ModeDevice != 0:
+		color = colorDevice
+	case mode&os.ModeNamedPipe != 0:
+		color = colorPipe
+	case mode&os.ModeSocket != 0:
+		color = colorSocket
+	case mode&0111 != 0:
+		color = colorExec
+	default:
+		return name
+	}
+	return color + name + colorReset
+}
+
+// typeSuffix returns the file type indicator for the file mode.
+func typeSuffix(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "/"
+	case mode&os.ModeSymlink != 0:
+		return "@"
+	case mode&os.ModeNamedPipe != 0:
+		return "|"
+	case mode&os.ModeSocket != 0:
+		return "="
+	case mode.IsRegular() && mode&0111 != 0:
+		return "*"
+	default:
+		return ""
+	}
+}
+
+// modeString formats the file mode in the ls long listing format.
+func modeString(mode os.FileMode) string {
+	var buf [10]byte
+
+	switch {
+	case mode.IsDir():
+		buf[0] = 'd'
+	case mode&os.ModeSymlink != 0:
+		buf[0] = 'l'
+	case mode&os.ModeCharDevice != 0:
+		buf[0] = 'c'
+	case mode&os.ModeDevice != 0:
+		buf[0] = 'b'
+	case mode&os.ModeNamedPipe != 0:
+		buf[0] = 'p'
+	case mode&os.ModeSocket != 0:
+		buf[0] = 's'
+	default:
+		buf[0] = '-'
+	}
+	const rwx = "rwxrwxrwx"
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) != 0 {
+			buf[i+1] = rwx[i]
+		} else {
+			buf[i+1] = '-'
+		}
+	}
+	return string(buf[:])
+}
+
+// formatSize formats the file size. If human is true, the size is
+// formatted with a unit suffix.
+func formatSize(size int64, human bool) string {
+	if !human || size < 1024 {
+		return fmt.Sprintf("%d", size)
+	}
+	value := float64(size)
+	var unit int
+	for value >= 1024 && unit < len("KMGTPE") {
+		value /= 1024
+		unit++
+	}
+	if value < 10 {
+		return fmt.Sprintf("%.1f%c", value, "KMGTPE"[unit-1])
+	}
+	return fmt.Sprintf("%.0f%c", value, "KMGTPE"[unit-1])
+}
+
+// formatTime formats the modification time. Times older than six
+// months, or in the future, are shown with the year instead of the
+// time of day.
+func formatTime(t, now time.Time) string {
+	if t.After(now) || now.Sub(t) > 182*24*time.Hour {
+		return t.Format("Jan _2  2006")
+	}
+	return t.Format("Jan _2 15:04")
+}
+
+// fileNlink returns the number of hard links of the file.
+func fileNlink(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink)
+	}
+	return 1
+}
+
+// fileOwner returns the user and group IDs of the file.
+func fileOwner(info os.FileInfo) (int, int) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(st.Uid), int(st.Gid)
+	}
+	return 0, 0
+}
+
+// fileBlocks returns the number of 1024 byte blocks the file uses.
+func fileBlocks(info os.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return (int64(st.Blocks) + 1) / 2
+	}
+	return (info.Size() + 1023) / 1024
+}
+
+// tabulate prints the names in columns. The display contains the
+// names as they are printed, possibly with terminal escape sequences.
+func tabulate(out io.Writer, names, display []string) {
+	var max int
+	for _, name := range names {
+		if len(name) > max {
+			max = len(name)
+		}
+	}
+	width := max + 2
+	perLine := 80 / width
+	if perLine < 1 {
+		perLine = 1
+	}
+	rows := (len(names) + perLine - 1) / perLine
+
+	for row := 0; row < rows; row++ {
+		for idx := row; idx < len(names); idx += rows {
+			fmt.Fprint(out, display[idx])
+			if idx+rows < len(names) {
+				fmt.Fprint(out, strings.Repeat(" ", width-len(names[idx])))
+			}
+		}
+		fmt.Fprintln(out)
+	}
+}
@@ -0,0 +1,92 @@
+//
+// cmd_ls_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestModeString(t *testing.T) {
+	tests := []struct {
+		mode os.FileMode
+		want string
+	}{
+		{0644, "-rw-r--r--"},
+		{os.ModeDir | 0755, "drwxr-xr-x"},
+		{os.ModeDevice | os.ModeCharDevice | 0666, "crw-rw-rw-"},
+		{os.ModeNamedPipe | 0600, "prw-------"},
+	}
+	for _, test := range tests {
+		if got := modeString(test.mode); got != test.want {
+			t.Errorf("modeString(%s)=%q, expected %q", test.mode, got,
+				test.want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size  int64
+		human bool
+		want  string
+	}{
+		{1023, false, "1023"},
+		{1023, true, "1023"},
+		{1536, true, "1.5K"},
+		{20 * 1024 * 1024, true, "20M"},
+	}
+	for _, test := range tests {
+		if got := formatSize(test.size, test.human); got != test.want {
+			t.Errorf("formatSize(%d,%v)=%q, expected %q", test.size,
+				test.human, got, test.want)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	now := time.Now()
+	entries := func() []lsEntry {
+		return []lsEntry{
+			{name: "c", info: &testInfo{modTime: now.Add(-time.Hour)}},
+			{name: "a", info: &testInfo{modTime: now.Add(-2 * time.Hour)}},
+			{name: "b", info: &testInfo{modTime: now}},
+		}
+	}
+	tests := []struct {
+		opts lsOptions
+		want string
+	}{
+		{lsOptions{}, "abc"},
+		{lsOptions{reverse: true}, "cba"},
+		{lsOptions{byTime: true}, "bca"},
+		{lsOptions{byTime: true, reverse: true}, "acb"},
+	}
+	for _, test := range tests {
+		e := entries()
+		test.opts.sort(e)
+		var got string
+		for _, entry := range e {
+			got += entry.name
+		}
+		if got != test.want {
+			t.Errorf("sort(%+v)=%q, expected %q", test.opts, got, test.want)
+		}
+	}
+}
+
+type testInfo struct {
+	os.FileInfo
+	modTime time.Time
+}
+
+func (info *testInfo) ModTime() time.Time {
+	return info.modTime
+}
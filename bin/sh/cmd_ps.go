@@ -0,0 +1,82 @@
+//
+// cmd_ps.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	builtin = append(builtin, Builtin{
+		Name: "ps",
+		Cmd:  cmd_ps,
+	})
+}
+
+// procStatus reads the status file of the process pid from the
+// process filesystem.
+func procStatus(pid string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path.Join("/proc", pid, "status"))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 {
+			result[parts[0]] = strings.TrimSpace(parts[1])
+		}
+	}
+	return result, nil
+}
+
+func cmd_ps(stdio *Stdio, args []string) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "ps: %s\n", err)
+		return
+	}
+	fmt.Fprintf(stdio.Stdout, "%5s %5s %5s %s %-8s %s\n",
+		"PID", "PPID", "PGID", "S", "START", "CMD")
+
+	for _, entry := range entries {
+		pid := entry.Name()
+		if _, err := strconv.Atoi(pid); err != nil {
+			continue
+		}
+		status, err := procStatus(pid)
+		if err != nil {
+			// The process exited.
+			continue
+		}
+		cmdline, err := ioutil.ReadFile(path.Join("/proc", pid, "cmdline"))
+		if err != nil {
+			continue
+		}
+		cmd := strings.Join(strings.Split(strings.TrimRight(string(cmdline),
+			"\x00"), "\x00"), " ")
+
+		var state string
+		if len(status["State"]) > 0 {
+			state = status["State"][:1]
+		}
+		var start string
+		t, err := time.Parse(time.RFC3339, status["Start"])
+		if err == nil {
+			start = t.Format("15:04:05")
+		}
+		fmt.Fprintf(stdio.Stdout, "%5s %5s %5s %s %-8s %s\n",
+			pid, status["PPid"], status["PGid"], state, start, cmd)
+	}
+}
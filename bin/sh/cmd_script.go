@@ -0,0 +1,291 @@
+//
+// cmd_script.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// lastStatus is the exit status of the command preceding the
+// running builtin.
+var lastStatus int
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name: "exit",
+			Cmd:  cmd_exit,
+		},
+		Builtin{
+			Name: "return",
+			Cmd:  cmd_return,
+		},
+		Builtin{
+			Name: "break",
+			Cmd:  cmd_break,
+		},
+		Builtin{
+			Name: "continue",
+			Cmd:  cmd_break,
+		},
+		Builtin{
+			Name: "true",
+			Cmd: func(stdio *Stdio, args []string) {
+				status = 0
+			},
+		},
+		Builtin{
+			Name: "false",
+			Cmd: func(stdio *Stdio, args []string) {
+				status = 1
+			},
+		},
+		Builtin{
+			Name: "test",
+			Cmd:  cmd_test,
+		},
+		Builtin{
+			Name: "[",
+			Cmd:  cmd_test,
+		},
+		Builtin{
+			Name: "shift",
+			Cmd:  cmd_shift,
+		},
+		Builtin{
+			Name: "source",
+			Cmd:  cmd_source,
+		},
+		Builtin{
+			Name: ".",
+			Cmd:  cmd_source,
+		},
+	}...)
+}
+
+// statusArg returns the exit status argument of the builtin or the
+// exit status of the previous command if the argument is not given.
+func statusArg(stdio *Stdio, args []string) (int, bool) {
+	if len(args) < 2 {
+		return lastStatus, true
+	}
+	code, err := strconv.Atoi(args[1])
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "%s: %s: numeric argument required\n",
+			args[0], args[1])
+		return 2, false
+	}
+	return code & 0xff, true
+}
+
+func cmd_exit(stdio *Stdio, args []string) {
+	status, _ = statusArg(stdio, args)
+	running = false
+}
+
+func cmd_return(stdio *Stdio, args []string) {
+	if funcDepth == 0 {
+		fmt.Fprintf(stdio.Stderr,
+			"return: can only `return' from a function or sourced script\n")
+		status = 1
+		return
+	}
+	code, ok := statusArg(stdio, args)
+	status = code
+	if ok {
+		flow = FlowReturn
+	}
+}
+
+func cmd_break(stdio *Stdio, args []string) {
+	if loopDepth == 0 {
+		fmt.Fprintf(stdio.Stderr,
+			"%s: only meaningful in a `for', `while', or `until' loop\n",
+			args[0])
+		return
+	}
+	count := 1
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 1 {
+			fmt.Fprintf(stdio.Stderr, "%s: %s: loop count out of range\n",
+				args[0], args[1])
+			status = 1
+			return
+		}
+		count = n
+	}
+	if count > loopDepth {
+		count = loopDepth
+	}
+	if args[0] == "break" {
+		flow = FlowBreak
+	} else {
+		flow = FlowContinue
+	}
+	flowCount = count
+}
+
+func cmd_shift(stdio *Stdio, args []string) {
+	n := 1
+	if len(args) > 1 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			fmt.Fprintf(stdio.Stderr, "shift: %s: numeric argument required\n",
+				args[1])
+			status = 1
+			return
+		}
+	}
+	if n > len(positional)-1 {
+		status = 1
+		return
+	}
+	positional = append([]string{positional[0]}, positional[1+n:]...)
+}
+
+func cmd_source(stdio *Stdio, args []string) {
+	if len(args) < 2 {
+		fmt.Fprintf(stdio.Stderr, "usage: %s file [arg ...]\n", args[0])
+		status = 2
+		return
+	}
+	if len(args) > 2 {
+		saved := positional
+		positional = append([]string{positional[0]}, args[2:]...)
+		defer func() {
+			positional = saved
+		}()
+	}
+	err := sourceFile(args[1])
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "%s: %s\n", args[0], err)
+		status = 1
+	}
+}
+
+func cmd_test(stdio *Stdio, args []string) {
+	name := args[0]
+	args = args[1:]
+	if name == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			fmt.Fprintf(stdio.Stderr, "[: missing `]'\n")
+			status = 2
+			return
+		}
+		args = args[:len(args)-1]
+	}
+	result, err := evalTest(args)
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "%s: %s\n", name, err)
+		status = 2
+		return
+	}
+	if result {
+		status = 0
+	} else {
+		status = 1
+	}
+}
+
+// evalTest evaluates the test expression args.
+func evalTest(args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+
+	case 1:
+		return len(args[0]) > 0, nil
+
+	case 2:
+		if args[0] == "!" {
+			result, err := evalTest(args[1:])
+			return !result, err
+		}
+		return evalUnary(args[0], args[1])
+
+	case 3:
+		if args[0] == "!" {
+			result, err := evalTest(args[1:])
+			return !result, err
+		}
+		return evalBinary(args[0], args[1], args[2])
+
+	default:
+		if args[0] == "!" {
+			result, err := evalTest(args[1:])
+			return !result, err
+		}
+		return false, fmt.Errorf("too many arguments")
+	}
+}
+
+func evalUnary(op, arg string) (bool, error) {
+	switch op {
+	case "-n":
+		return len(arg) > 0, nil
+	case "-z":
+		return len(arg) == 0, nil
+	}
+
+	info, err := os.Stat(arg)
+	switch op {
+	case "-e":
+		return err == nil, nil
+	case "-f":
+		return err == nil && info.Mode().IsRegular(), nil
+	case "-d":
+		return err == nil && info.IsDir(), nil
+	case "-s":
+		return err == nil && info.Size() > 0, nil
+	case "-r", "-w":
+		return err == nil, nil
+	case "-x":
+		return err == nil && info.Mode()&0111 != 0, nil
+	default:
+		return false, fmt.Errorf("%s: unary operator expected", op)
+	}
+}
+
+func evalBinary(a, op, b string) (bool, error) {
+	switch op {
+	case "=", "==":
+		return a == b, nil
+	case "!=":
+		return a != b, nil
+	}
+
+	x, err := strconv.ParseInt(a, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("%s: integer expression expected", a)
+	}
+	y, err := strconv.ParseInt(b, 10, 64)
+	if err != nil {
+		return false, fmt.Errorf("%s: integer expression expected", b)
+	}
+	switch op {
+	case "-eq":
+		return x == y, nil
+	case "-ne":
+		return x != y, nil
+	case "-lt":
+		return x < y, nil
+	case "-le":
+		return x <= y, nil
+	case "-gt":
+		return x > y, nil
+	case "-ge":
+		return x >= y, nil
+	default:
+		return false, fmt.Errorf("%s: binary operator expected", op)
+	}
+}
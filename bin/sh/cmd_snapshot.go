@@ -0,0 +1,89 @@
+//
+// cmd_snapshot.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name: "snapshot",
+			Cmd:  cmd_snapshot,
+		},
+		Builtin{
+			Name: "log",
+			Cmd:  cmd_log,
+		},
+		Builtin{
+			Name: "checkout",
+			Cmd:  cmd_checkout,
+		},
+	}...)
+}
+
+func cmd_snapshot(stdio *Stdio, args []string) {
+	message := flag.String("m", "", "snapshot message")
+	flag.Parse()
+
+	if len(*message) == 0 {
+		*message = strings.Join(flag.Args(), " ")
+	}
+	id, err := bbos.Commit(*message)
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "snapshot: %s\n", err)
+		return
+	}
+	fmt.Fprintf(stdio.Stdout, "Created snapshot @%s\n", id)
+}
+
+func cmd_log(stdio *Stdio, args []string) {
+	snapshots, err := bbos.Snapshots()
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "log: %s\n", err)
+		return
+	}
+	for idx, s := range snapshots {
+		if idx > 0 {
+			fmt.Fprintln(stdio.Stdout)
+		}
+		fmt.Fprintf(stdio.Stdout, "snapshot @%s\n", s.ID)
+		fmt.Fprintf(stdio.Stdout, "Date: %s\n", s.Timestamp.Format(time.UnixDate))
+		if len(s.Message) > 0 {
+			fmt.Fprintf(stdio.Stdout, "\n    %s\n", s.Message)
+		}
+	}
+}
+
+func cmd_checkout(stdio *Stdio, args []string) {
+	force := flag.Bool("f", false, "discard uncommitted changes")
+	flag.Parse()
+
+	if len(flag.Args()) != 1 {
+		fmt.Fprintf(stdio.Stderr, "Usage: checkout [-f] @snapshot\n")
+		return
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(flag.Args()[0], "@"), "/")
+	err := bbos.Checkout(id, *force)
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "checkout: %s\n", err)
+		return
+	}
+	// The working directory may not exist in the snapshot.
+	_, err = bbos.Stat(".")
+	if err != nil {
+		bbos.Chdir("/")
+	}
+}
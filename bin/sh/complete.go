@@ -0,0 +1,250 @@
+//
+// complete.go
+//
+// Copyright (c) 2018-2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+	"github.com/markkurossi/blackbox-os/lib/readline"
+)
+
+// binDir is the directory containing the programs.
+const binDir = "/bin"
+
+// sysctlDir is the directory containing the kernel control values.
+var sysctlDir = "/proc/sys"
+
+var completion = readline.NewCompletion()
+
+func init() {
+	completion.Command = completeCommand
+	completion.Register("cd", readline.CompleteDirs)
+	completion.Register("ssh", completeHosts)
+	completion.Register("sysctl", completeSysctl)
+}
+
+// isSeparator tests if the operator token separates commands.
+func isSeparator(t Token) bool {
+	if t.Type != TOperator {
+		return false
+	}
+	switch t.Value {
+	case "|", "||", "&", "&&", ";", ";;", "(", ")", "\n":
+		return true
+	}
+	return false
+}
+
+// completionLookup expands the variables which are set. The
+// references to unset variables are kept so that their names can be
+// completed.
+func completionLookup(name string) string {
+	value := lookupVar(name)
+	if len(value) == 0 {
+		return "$" + name
+	}
+	return value
+}
+
+func tabCompletion(line string) (string, []string) {
+	tokens, _ := Lex(line, completionLookup)
+	runes := []rune(line)
+
+	// Collect the arguments of the current command and the word
+	// being completed.
+	var args []string
+	var word string
+	var redirect bool
+	start := len(runes)
+
+	for idx, t := range tokens {
+		last := idx+1 == len(tokens) && t.End == len(runes)
+		if last && t.Type == TWord {
+			word = t.Value
+			start = t.Start
+			break
+		}
+		if isSeparator(t) {
+			args = nil
+			redirect = false
+			continue
+		}
+		if t.Type == TOperator {
+			_, redirect = newRedirect(t.Value)
+			continue
+		}
+		if redirect {
+			redirect = false
+			continue
+		}
+		args = append(args, t.Value)
+	}
+	prefix := string(runes[:start])
+	raw := string(runes[start:])
+
+	switch {
+	case strings.HasPrefix(raw, "$") && validVarPrefix(raw[1:]):
+		expanded, completions := readline.Expand(raw,
+			completeVariables(args, raw))
+		return prefix + expanded, completions
+
+	case len(word) > 0 && word[0] == '@' && strings.IndexByte(word, '/') < 0:
+		expanded, completions := readline.Expand(word,
+			completeSnapshots(args, word))
+		return prefix + expanded, completions
+
+	case redirect:
+		args = []string{""}
+	}
+
+	expanded, completions := completion.Complete(args, word)
+	if expanded == word {
+		return line, displayNames(word, completions)
+	}
+	return prefix + CommandEscape(expanded), displayNames(word, completions)
+}
+
+// validVarPrefix tests if the name is a prefix of a variable name.
+func validVarPrefix(name string) bool {
+	return len(name) == 0 || validName(name)
+}
+
+// displayNames removes the directory part of the word from the
+// completions.
+func displayNames(word string, completions []string) []string {
+	idx := strings.LastIndexByte(word, '/')
+	if idx < 0 {
+		return completions
+	}
+	var result []string
+	for _, c := range completions {
+		result = append(result, strings.TrimPrefix(c, word[:idx+1]))
+	}
+	return result
+}
+
+// completeVariables completes the shell variable names. The word
+// must start with the '$' character.
+func completeVariables(args []string, word string) []string {
+	var result []string
+	for _, name := range varNames() {
+		if strings.HasPrefix(name, word[1:]) {
+			result = append(result, "$"+name)
+		}
+	}
+	return result
+}
+
+// completeCommand completes the command names. The command names are
+// the shell builtins, functions, and the programs in binDir.
+func completeCommand(args []string, word string) []string {
+	if strings.IndexByte(word, '/') >= 0 {
+		return readline.CompleteFiles(args, word)
+	}
+	var names []string
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range functions {
+		names = append(names, name)
+	}
+	files, err := ioutil.ReadDir(binDir)
+	if err == nil {
+		for _, f := range files {
+			if !f.IsDir() {
+				names = append(names, f.Name())
+			}
+		}
+	}
+	return readline.FilterPrefix(names, word)
+}
+
+func completeSnapshots(args []string, word string) []string {
+	snapshots, err := bbos.Snapshots()
+	if err != nil {
+		return nil
+	}
+	var result []string
+	for _, s := range snapshots {
+		result = append(result, fmt.Sprintf("@%s", s.ID))
+	}
+	return readline.FilterPrefix(result, word)
+}
+
+// completeHosts completes the host names from the user's
+// known_hosts file. The word can have the user@ prefix.
+func completeHosts(args []string, word string) []string {
+	var user string
+	if idx := strings.IndexByte(word, '@'); idx >= 0 {
+		user = word[:idx+1]
+		word = word[idx+1:]
+	}
+	home, _ := getVar("HOME")
+	f, err := os.Open(path.Join(home, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var result []string
+	for _, host := range knownHosts(bufio.NewScanner(f)) {
+		if strings.HasPrefix(host, word) {
+			result = append(result, user+host)
+		}
+	}
+	return result
+}
+
+// knownHosts returns the host names of the known_hosts file. The
+// hashed host names are ignored.
+func knownHosts(scanner *bufio.Scanner) []string {
+	var result []string
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "@") {
+			// Marker, e.g. @cert-authority.
+			fields = fields[1:]
+		}
+		for _, host := range strings.Split(fields[0], ",") {
+			if strings.HasPrefix(host, "|") || strings.ContainsAny(host, "*?!") {
+				continue
+			}
+			if strings.HasPrefix(host, "[") {
+				// [host]:port
+				if idx := strings.IndexByte(host, ']'); idx > 0 {
+					host = host[1:idx]
+				}
+			}
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+// completeSysctl completes the kernel control value names.
+func completeSysctl(args []string, word string) []string {
+	files, err := ioutil.ReadDir(sysctlDir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	return readline.FilterPrefix(names, word)
+}
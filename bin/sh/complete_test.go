@@ -0,0 +1,81 @@
+//
+// complete_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testKnownHosts = `# comment
+example.com,192.0.2.1 ssh-ed25519 AAAAC3Nza
+[git.example.org]:2222 ssh-rsa AAAAB3Nza
+|1|F1E1KeoE/eEWhi10WpGv4OdiO6Y=|3988QV0VE8wmZL7suNrYQLITLCg= ssh-rsa AAAA
+@cert-authority *.example.net ssh-rsa AAAA
+`
+
+func TestKnownHosts(t *testing.T) {
+	hosts := knownHosts(bufio.NewScanner(strings.NewReader(testKnownHosts)))
+	expected := []string{"example.com", "192.0.2.1", "git.example.org"}
+	if !reflect.DeepEqual(hosts, expected) {
+		t.Errorf("knownHosts=%q, expected %q", hosts, expected)
+	}
+}
+
+func TestTabCompletion(t *testing.T) {
+	os.Setenv("COMPLETE_TEST", "1")
+
+	dir, err := ioutil.TempDir("", "sysctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"kernel.power", "user", "user.uid"} {
+		err := ioutil.WriteFile(path.Join(dir, name), nil, 0444)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := sysctlDir
+	sysctlDir = dir
+	defer func() {
+		sysctlDir = saved
+	}()
+
+	tests := []struct {
+		line     string
+		expanded string
+	}{
+		{"echo $COMPLETE_T", "echo $COMPLETE_TEST"},
+		{"sysctl kernel.p", "sysctl kernel.power"},
+		{"ls | sysctl us", "ls | sysctl user"},
+	}
+	for _, test := range tests {
+		expanded, _ := tabCompletion(test.line)
+		if expanded != test.expanded {
+			t.Errorf("tabCompletion(%q)=%q, expected %q", test.line, expanded,
+				test.expanded)
+		}
+	}
+	if _, list := tabCompletion("sysctl user"); len(list) != 2 {
+		t.Errorf("sysctl user: got completions %q", list)
+	}
+}
+
+func TestDisplayNames(t *testing.T) {
+	names := displayNames("/etc/p", []string{"/etc/passwd", "/etc/profile"})
+	if !reflect.DeepEqual(names, []string{"passwd", "profile"}) {
+		t.Errorf("displayNames=%q", names)
+	}
+}
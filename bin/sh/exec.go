@@ -0,0 +1,398 @@
+//
+// exec.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Flow specifies how the execution continues after a command.
+type Flow int
+
+// Control flow values.
+const (
+	FlowNext Flow = iota
+	FlowBreak
+	FlowContinue
+	FlowReturn
+)
+
+var (
+	interactive bool
+	positional  []string
+	functions   = make(map[string]*FuncNode)
+	flow        Flow
+	flowCount   int
+	loopDepth   int
+	funcDepth   int
+)
+
+// stopped tests if the execution of the current list must stop.
+func stopped() bool {
+	return !running || flow != FlowNext
+}
+
+func execList(list *List) {
+	for _, ao := range list.Items {
+		execAndOr(ao)
+		if stopped() {
+			return
+		}
+	}
+}
+
+func execAndOr(ao *AndOr) {
+	for idx, pipeline := range ao.Pipelines {
+		if idx > 0 {
+			op := ao.Ops[idx-1]
+			if op == "&&" && status != 0 || op == "||" && status == 0 {
+				continue
+			}
+		}
+		execPipeline(pipeline, ao.Background)
+		if stopped() {
+			return
+		}
+	}
+}
+
+func execPipeline(node *PipelineNode, background bool) {
+	if node.Compound != nil {
+		if background {
+			fmt.Fprintf(os.Stderr,
+				"sh: compound commands can't be run in the background\n")
+			status = 2
+			return
+		}
+		execNode(node.Compound)
+		return
+	}
+
+	tokens, err := Lex(node.Text, lookupVar)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sh: %s\n", err)
+		status = 2
+		return
+	}
+	pipeline, err := parsePipeline(tokens)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sh: %s\n", err)
+		status = 2
+		return
+	}
+	switch {
+	case len(pipeline) == 0:
+
+	case len(pipeline) == 1 && pipeline[0].assignment():
+		for _, arg := range pipeline[0].Args {
+			idx := strings.IndexByte(arg, '=')
+			setVar(arg[:idx], arg[idx+1:])
+		}
+		status = 0
+
+	case len(pipeline) == 1 && functions[pipeline[0].name()] != nil:
+		// The functions run in the shell process and use its standard
+		// files so they can't be redirected or put into background.
+		if background {
+			fmt.Fprintf(os.Stderr,
+				"sh: %s: functions can't be run in the background\n",
+				pipeline[0].name())
+			status = 2
+			return
+		}
+		if len(pipeline[0].Redirects) > 0 {
+			fmt.Fprintf(os.Stderr,
+				"sh: %s: functions can't be redirected\n",
+				pipeline[0].name())
+			status = 2
+			return
+		}
+		callFunction(functions[pipeline[0].name()], pipeline[0].Args)
+
+	case len(pipeline) == 1 && !pipeline[0].external():
+		err = runCommand(pipeline[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+
+	default:
+		err = runPipeline(pipeline, node.Text, background)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sh: %s\n", err)
+		}
+	}
+}
+
+func execNode(node Node) {
+	switch n := node.(type) {
+	case *IfNode:
+		for idx, cond := range n.Conds {
+			execList(cond)
+			if stopped() {
+				return
+			}
+			if status == 0 {
+				execList(n.Bodies[idx])
+				return
+			}
+		}
+		status = 0
+		if n.Else != nil {
+			execList(n.Else)
+		}
+
+	case *LoopNode:
+		var result int
+		loopDepth++
+		defer func() {
+			loopDepth--
+		}()
+		for {
+			execList(n.Cond)
+			if stopped() {
+				break
+			}
+			if (status == 0) == n.Until {
+				break
+			}
+			execList(n.Body)
+			result = status
+			if loopDone() {
+				break
+			}
+		}
+		if running && flow == FlowNext {
+			status = result
+		}
+
+	case *ForNode:
+		var words []string
+		if n.HasIn {
+			words = expandWords(n.Words)
+		} else {
+			words = positional[1:]
+		}
+		loopDepth++
+		defer func() {
+			loopDepth--
+		}()
+		status = 0
+		for _, word := range words {
+			setVar(n.Name, word)
+			execList(n.Body)
+			if loopDone() {
+				break
+			}
+		}
+
+	case *CaseNode:
+		word := strings.Join(expandWords(n.Word), " ")
+		status = 0
+		for _, item := range n.Items {
+			for _, pattern := range item.Patterns {
+				if matchPattern(strings.Join(expandWords(pattern), " "), word) {
+					execList(item.Body)
+					return
+				}
+			}
+		}
+
+	case *GroupNode:
+		execList(n.Body)
+
+	case *FuncNode:
+		functions[n.Name] = n
+		status = 0
+
+	default:
+		panic(fmt.Sprintf("unsupported node %T", node))
+	}
+}
+
+// loopDone handles the break and continue commands of the
+// innermost loop. It returns true if the loop must terminate.
+func loopDone() bool {
+	switch flow {
+	case FlowNext:
+		return !running
+
+	case FlowBreak:
+		flowCount--
+		if flowCount <= 0 {
+			flow = FlowNext
+		}
+		return true
+
+	case FlowContinue:
+		flowCount--
+		if flowCount <= 0 {
+			flow = FlowNext
+			return false
+		}
+		return true
+
+	default:
+		return true
+	}
+}
+
+// callFunction calls the shell function fn with the arguments
+// args. The args[0] is the name of the function.
+func callFunction(fn *FuncNode, args []string) {
+	saved := positional
+	positional = append([]string{positional[0]}, args[1:]...)
+	funcDepth++
+	defer func() {
+		positional = saved
+		funcDepth--
+	}()
+
+	savedLoops := loopDepth
+	loopDepth = 0
+	execNode(fn.Body)
+	loopDepth = savedLoops
+
+	if flow == FlowReturn {
+		flow = FlowNext
+	}
+}
+
+// expandWords expands the words of the source text.
+func expandWords(text string) []string {
+	tokens, err := Lex(text, lookupVar)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sh: %s\n", err)
+	}
+	var result []string
+	for _, t := range tokens {
+		if t.Type == TWord {
+			result = append(result, t.Value)
+		}
+	}
+	return result
+}
+
+// matchPattern tests if the value matches the shell pattern.
+func matchPattern(pattern, value string) bool {
+	var sb strings.Builder
+	sb.WriteRune('^')
+
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteRune('.')
+		case '[':
+			end := i + 1
+			if end < len(runes) && (runes[end] == '!' || runes[end] == '^') {
+				end++
+			}
+			if end < len(runes) && runes[end] == ']' {
+				end++
+			}
+			for end < len(runes) && runes[end] != ']' {
+				end++
+			}
+			if end >= len(runes) {
+				sb.WriteString(regexp.QuoteMeta(string(r)))
+				continue
+			}
+			class := runes[i+1 : end]
+			sb.WriteRune('[')
+			if class[0] == '!' {
+				sb.WriteRune('^')
+				class = class[1:]
+			}
+			sb.WriteString(strings.ReplaceAll(string(class), `\`, `\\`))
+			sb.WriteRune(']')
+			i = end
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+			}
+			sb.WriteString(regexp.QuoteMeta(string(runes[i])))
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	sb.WriteRune('$')
+
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return pattern == value
+	}
+	return re.MatchString(value)
+}
+
+// runScript parses and runs the shell script.
+func runScript(name, script string) {
+	list, err := Parse(script)
+	if err != nil {
+		if err == ErrIncomplete {
+			err = fmt.Errorf("syntax error: unexpected end of file")
+		}
+		fmt.Fprintf(os.Stderr, "sh: %s: %s\n", name, err)
+		status = 2
+		return
+	}
+	execList(list)
+}
+
+// sourceFile runs the commands of the file in the current shell.
+func sourceFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	funcDepth++
+	runScript(file, string(data))
+	funcDepth--
+	if flow == FlowReturn {
+		flow = FlowNext
+	}
+	return nil
+}
+
+// interpreter returns the arguments for running the script file
+// args[0] with the interpreter of its "#!" line. The args are
+// returned unchanged if args[0] is not a script file.
+func interpreter(args []string) []string {
+	if strings.IndexByte(args[0], '/') < 0 {
+		return args
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return args
+	}
+	defer f.Close()
+
+	var buf [256]byte
+	n, _ := f.Read(buf[:])
+	line := string(buf[:n])
+	if !strings.HasPrefix(line, "#!") {
+		return args
+	}
+	if idx := strings.IndexByte(line, '\n'); idx >= 0 {
+		line = line[:idx]
+	}
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return args
+	}
+	result := []string{path.Base(fields[0])}
+	result = append(result, fields[1:]...)
+	return append(result, args...)
+}
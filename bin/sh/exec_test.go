@@ -0,0 +1,238 @@
+//
+// exec_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func runTestScript(t *testing.T, script string, args ...string) {
+	if builtins == nil {
+		builtins = make(map[string]Builtin)
+		for _, bi := range builtin {
+			builtins[bi.Name] = bi
+		}
+	}
+	savedArgs := os.Args
+	defer func() {
+		os.Args = savedArgs
+	}()
+
+	positional = append([]string{"sh"}, args...)
+	running = true
+	status = 0
+	runScript("test", script)
+}
+
+var execTests = []struct {
+	script string
+	args   []string
+	result string
+	status int
+}{
+	{
+		script: "r=a; false && r=b; true || r=c",
+		result: "a",
+	},
+	{
+		script: "if false; then r=a; elif true; then r=b; else r=c; fi",
+		result: "b",
+	},
+	{
+		script: `r=; for i in a "b c" $@; do r=$r$i; done`,
+		args:   []string{"d e", "f"},
+		result: "ab cdef",
+	},
+	{
+		script: `r=; for i; do r=$r-$i; done`,
+		args:   []string{"x y", "z"},
+		result: "-x y-z",
+	},
+	{
+		script: `
+r=
+for i in 1 2 3 4; do
+  if [ $i = 2 ]; then continue; fi
+  if [ $i -ge 4 ]; then break; fi
+  r=$r$i
+done`,
+		result: "13",
+	},
+	{
+		script: `
+r=
+while [ "$r" != xxx ]; do
+  r=${r}x
+done`,
+		result: "xxx",
+	},
+	{
+		script: `r=; until test -n "$r"; do r=y; done`,
+		result: "y",
+	},
+	{
+		script: `
+case $1 in
+  *.go|*.c) r=source;;
+  [abc]*) r=abc;;
+  *) r=other;;
+esac`,
+		args:   []string{"dir/main.go"},
+		result: "source",
+	},
+	{
+		script: `case $1 in [!x]*) r=notx;; esac`,
+		args:   []string{"yes"},
+		result: "notx",
+	},
+	{
+		script: `
+f() {
+  r="$# $1"
+  return 3
+  r=unreachable
+}
+f "a b" c`,
+		result: "2 a b",
+		status: 3,
+	},
+	{
+		script: "f() { r=called; }; r=a; f > /dev/null",
+		result: "a",
+		status: 2,
+	},
+	{
+		script: "f() { r=called; }; r=a; f &",
+		result: "a",
+		status: 2,
+	},
+	{
+		script: "r=$#; shift; r=$r$1; exit 4; r=x",
+		args:   []string{"a", "b"},
+		result: "2b",
+		status: 4,
+	},
+	{
+		script: "r=a; false; r=$?",
+		result: "1",
+	},
+}
+
+func TestExec(t *testing.T) {
+	for _, test := range execTests {
+		unsetVar("r")
+		runTestScript(t, test.script, test.args...)
+		if r, _ := getVar("r"); r != test.result {
+			t.Errorf("%q: r=%q, expected %q", test.script, r, test.result)
+		}
+		if status != test.status {
+			t.Errorf("%q: status=%d, expected %d", test.script, status,
+				test.status)
+		}
+	}
+	running = true
+}
+
+var matchTests = []struct {
+	pattern string
+	value   string
+	match   bool
+}{
+	{"*", "", true},
+	{"*.go", "a/b.go", true},
+	{"?.go", "ab.go", false},
+	{"[a-c]x", "bx", true},
+	{"[!a-c]x", "bx", false},
+	{`\*`, "*", true},
+	{"a.b", "axb", false},
+	{"[", "[", true},
+}
+
+func TestMatchPattern(t *testing.T) {
+	for _, test := range matchTests {
+		if matchPattern(test.pattern, test.value) != test.match {
+			t.Errorf("matchPattern(%q, %q) != %v", test.pattern, test.value,
+				test.match)
+		}
+	}
+}
+
+var evalTests = []struct {
+	args   []string
+	result bool
+}{
+	{nil, false},
+	{[]string{""}, false},
+	{[]string{"a"}, true},
+	{[]string{"-z", ""}, true},
+	{[]string{"!", "-n", ""}, true},
+	{[]string{"a", "=", "a"}, true},
+	{[]string{"a", "!=", "a"}, false},
+	{[]string{"10", "-gt", "9"}, true},
+	{[]string{"-1", "-le", "-2"}, false},
+}
+
+func TestEvalTest(t *testing.T) {
+	for _, test := range evalTests {
+		result, err := evalTest(test.args)
+		if err != nil {
+			t.Errorf("evalTest(%q) failed: %s", test.args, err)
+			continue
+		}
+		if result != test.result {
+			t.Errorf("evalTest(%q)=%v, expected %v", test.args, result,
+				test.result)
+		}
+	}
+	if _, err := evalTest([]string{"a", "-eq", "1"}); err == nil {
+		t.Errorf("evalTest succeeded with invalid integer")
+	}
+}
+
+func TestVars(t *testing.T) {
+	for _, name := range []string{"v", "e", "p", "i"} {
+		unsetVar(name)
+	}
+	runTestScript(t, `
+v=local
+export e=exported
+export p
+p=pending
+for i in a; do v=$v$i; done`)
+
+	if v, _ := getVar("v"); v != "locala" {
+		t.Errorf("v=%q, expected locala", v)
+	}
+	for _, name := range []string{"v", "i"} {
+		if _, ok := os.LookupEnv(name); ok {
+			t.Errorf("local variable %s in the environment", name)
+		}
+	}
+	if e := os.Getenv("e"); e != "exported" {
+		t.Errorf("e=%q, expected exported", e)
+	}
+	if p := os.Getenv("p"); p != "pending" {
+		t.Errorf("p=%q, expected pending", p)
+	}
+
+	runTestScript(t, "export v; unset e")
+	if v := os.Getenv("v"); v != "locala" {
+		t.Errorf("exported v=%q, expected locala", v)
+	}
+	if _, ok := getVar("e"); ok {
+		t.Errorf("unset variable e is set")
+	}
+	if _, ok := os.LookupEnv("e"); ok {
+		t.Errorf("unset variable e in the environment")
+	}
+	for _, name := range []string{"v", "p", "i"} {
+		unsetVar(name)
+	}
+}
@@ -0,0 +1,189 @@
+//
+// history.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/markkurossi/blackbox-os/lib/readline"
+)
+
+const (
+	historySize = 500
+	historyFile = ".sh_history"
+)
+
+var history = readline.NewHistory(historySize)
+
+func init() {
+	builtin = append(builtin, Builtin{
+		Name: "history",
+		Cmd:  cmd_history,
+	})
+}
+
+// historyPath returns the path of the history file.
+func historyPath() string {
+	home, _ := getVar("HOME")
+	if len(home) == 0 {
+		return ""
+	}
+	return path.Join(home, historyFile)
+}
+
+// loadHistory loads the saved history.
+func loadHistory() {
+	file := historyPath()
+	if len(file) == 0 {
+		return
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	history.Load(f)
+}
+
+// addHistory adds the line to the history and appends it to the
+// history file. The history file is not updated if the filesystem is
+// not writable.
+func addHistory(line string) {
+	if !history.Add(line) {
+		return
+	}
+	file := historyPath()
+	if len(file) == 0 {
+		return
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// saveHistory rewrites the history file with the current history
+// entries. This drops the entries which do not fit into the history.
+func saveHistory() {
+	file := historyPath()
+	if len(file) == 0 {
+		return
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	history.Save(f)
+}
+
+// expandHistory expands the history references "!!", "!n", and "!-n"
+// of the line. The references are not expanded inside single quotes
+// or after a backslash.
+func expandHistory(line string, h *readline.History) (string, error) {
+	if strings.IndexByte(line, '!') < 0 {
+		return line, nil
+	}
+	var sb strings.Builder
+	var quoted bool
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'':
+			quoted = !quoted
+
+		case r == '\\' && !quoted && i+1 < len(runes):
+			sb.WriteRune(r)
+			i++
+			r = runes[i]
+
+		case r == '!' && !quoted && i+1 < len(runes):
+			n, end, ok := historyEvent(runes, i+1, h)
+			if end == i+1 {
+				break
+			}
+			if !ok {
+				return "", fmt.Errorf("%s: event not found",
+					string(runes[i:end]))
+			}
+			entry, ok := h.Get(n)
+			if !ok {
+				return "", fmt.Errorf("%s: event not found",
+					string(runes[i:end]))
+			}
+			sb.WriteString(entry)
+			i = end - 1
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String(), nil
+}
+
+// historyEvent parses the event designator starting from runes[pos].
+// It returns the event number and the end position of the
+// designator. If the input does not start an event designator, the
+// end position is equal to pos.
+func historyEvent(runes []rune, pos int, h *readline.History) (int, int, bool) {
+	if runes[pos] == '!' {
+		return h.Last(), pos + 1, h.Len() > 0
+	}
+	end := pos
+	if runes[end] == '-' {
+		end++
+	}
+	start := end
+	for end < len(runes) && runes[end] >= '0' && runes[end] <= '9' {
+		end++
+	}
+	if end == start {
+		return 0, pos, false
+	}
+	n, err := strconv.Atoi(string(runes[start:end]))
+	if err != nil {
+		return 0, end, false
+	}
+	if start > pos {
+		n = h.Last() + 1 - n
+	}
+	return n, end, true
+}
+
+func cmd_history(stdio *Stdio, args []string) {
+	count := history.Len()
+	if len(args) > 1 {
+		if args[1] == "-c" {
+			history.Clear()
+			saveHistory()
+			return
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			fmt.Fprintf(stdio.Stderr, "history: %s: numeric argument required\n",
+				args[1])
+			status = 1
+			return
+		}
+		if n < count {
+			count = n
+		}
+	}
+	for n := history.Last() - count + 1; n <= history.Last(); n++ {
+		entry, _ := history.Get(n)
+		fmt.Fprintf(stdio.Stdout, "%5d  %s\n", n, entry)
+	}
+}
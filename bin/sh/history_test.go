@@ -0,0 +1,54 @@
+//
+// history_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"testing"
+
+	"github.com/markkurossi/blackbox-os/lib/readline"
+)
+
+var expandHistoryTests = []struct {
+	input  string
+	output string
+}{
+	{"ls", "ls"},
+	{"!!", "echo c"},
+	{"sudo !! | wc", "sudo echo c | wc"},
+	{"!1 x", "echo a x"},
+	{"!-2", "echo b"},
+	{"[ a != b ]", "[ a != b ]"},
+	{"echo !", "echo !"},
+	{"echo '!!' \\!!", "echo '!!' \\!!"},
+}
+
+func TestExpandHistory(t *testing.T) {
+	h := readline.NewHistory(10)
+	h.Add("echo a")
+	h.Add("echo b")
+	h.Add("echo c")
+
+	for _, test := range expandHistoryTests {
+		output, err := expandHistory(test.input, h)
+		if err != nil {
+			t.Errorf("expandHistory(%q) failed: %s", test.input, err)
+			continue
+		}
+		if output != test.output {
+			t.Errorf("expandHistory(%q)=%q, expected %q", test.input, output,
+				test.output)
+		}
+	}
+	for _, input := range []string{"!4", "!-4", "!0"} {
+		_, err := expandHistory(input, h)
+		if err == nil {
+			t.Errorf("expandHistory(%q) succeeded", input)
+		}
+	}
+}
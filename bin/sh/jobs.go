@@ -0,0 +1,304 @@
+//
+// jobs.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+)
+
+// Job defines a pipeline which is running in the background or
+// which is stopped.
+type Job struct {
+	ID      int
+	Pgid    int
+	Cmd     string
+	Procs   []*JobProc
+	Stopped bool
+	termios *bbos.Termios
+}
+
+// JobProc defines a process of a job.
+type JobProc struct {
+	Pid    int
+	Name   string
+	Done   bool
+	Status bbos.WaitStatus
+}
+
+var (
+	jobs         []*Job
+	shellTermios *bbos.Termios
+)
+
+func init() {
+	builtin = append(builtin, []Builtin{
+		Builtin{
+			Name: "jobs",
+			Cmd:  cmd_jobs,
+		},
+		Builtin{
+			Name: "fg",
+			Cmd:  cmd_fg,
+		},
+		Builtin{
+			Name: "bg",
+			Cmd:  cmd_bg,
+		},
+	}...)
+}
+
+func (job *Job) add(pid int, name string) {
+	if job.Pgid == 0 {
+		job.Pgid = pid
+	}
+	job.Procs = append(job.Procs, &JobProc{
+		Pid:  pid,
+		Name: name,
+	})
+}
+
+func (job *Job) done() bool {
+	for _, proc := range job.Procs {
+		if !proc.Done {
+			return false
+		}
+	}
+	return true
+}
+
+func (job *Job) state() string {
+	if job.Stopped {
+		return "Stopped"
+	}
+	if job.done() {
+		ws := job.Procs[len(job.Procs)-1].Status
+		if ws.Signaled() {
+			return ws.Signal.String()
+		}
+		if ws.Code != 0 {
+			return fmt.Sprintf("Exit %d", ws.Code)
+		}
+		return "Done"
+	}
+	return "Running"
+}
+
+func (job *Job) print(stdio *Stdio) {
+	var current byte = ' '
+	if len(jobs) > 0 && jobs[len(jobs)-1] == job {
+		current = '+'
+	} else if len(jobs) > 1 && jobs[len(jobs)-2] == job {
+		current = '-'
+	}
+	fmt.Fprintf(stdio.Stdout, "[%d]%c  %-10s %s\n",
+		job.ID, current, job.state(), job.Cmd)
+}
+
+// addJob adds the job to the job table.
+func addJob(job *Job) {
+	if job.ID != 0 {
+		return
+	}
+	job.ID = 1
+	for _, j := range jobs {
+		if j.ID >= job.ID {
+			job.ID = j.ID + 1
+		}
+	}
+	jobs = append(jobs, job)
+}
+
+// removeJob removes the job from the job table.
+func removeJob(job *Job) {
+	for idx, j := range jobs {
+		if j == job {
+			jobs = append(jobs[:idx], jobs[idx+1:]...)
+			return
+		}
+	}
+}
+
+// findJob finds the job by its job specification. An empty spec,
+// `%%', and `%+' specify the current job and `%-' the previous
+// job. Other jobs are specified by their job number, optionally
+// prefixed with `%'.
+func findJob(spec string) (*Job, error) {
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no current job")
+	}
+	switch spec {
+	case "", "%", "%%", "%+":
+		return jobs[len(jobs)-1], nil
+	case "%-":
+		if len(jobs) < 2 {
+			return nil, fmt.Errorf("%s: no such job", spec)
+		}
+		return jobs[len(jobs)-2], nil
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(spec, "%"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+	for _, job := range jobs {
+		if job.ID == id {
+			return job, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no such job", spec)
+}
+
+// reapJobs updates the states of the jobs. The terminated jobs are
+// reported and removed from the job table. If verbose is true, also
+// the stopped jobs are reported.
+func reapJobs(stdio *Stdio, verbose bool) {
+	for _, job := range append([]*Job(nil), jobs...) {
+		var stopped bool
+		for _, proc := range job.Procs {
+			if proc.Done {
+				continue
+			}
+			ws, err := bbos.WaitPid(proc.Pid, bbos.WNOHANG|bbos.WUNTRACED)
+			if err != nil {
+				proc.Done = true
+				continue
+			}
+			if ws.Stopped {
+				stopped = true
+			} else if !ws.Running() {
+				proc.Done = true
+				proc.Status = ws
+			}
+		}
+		if job.done() {
+			job.Stopped = false
+			job.print(stdio)
+			removeJob(job)
+		} else if stopped && !job.Stopped {
+			job.Stopped = true
+			if verbose {
+				job.print(stdio)
+			}
+		}
+	}
+}
+
+// waitForeground waits until all processes of the foreground job
+// terminate or the job is stopped. The caller must have set the job
+// as the terminal foreground process group. The stopped jobs are
+// added to the job table and the terminal is returned to the shell.
+func waitForeground(job *Job) error {
+	defer foreground(shellPgrp)
+
+	for _, proc := range job.Procs {
+		if proc.Done {
+			continue
+		}
+		ws, err := bbos.WaitPid(proc.Pid, bbos.WUNTRACED)
+		if err != nil {
+			return err
+		}
+		if ws.Stopped {
+			// Save the job's terminal modes and restore the shell's.
+			termios, err := bbos.Tcgetattr(0)
+			if err == nil && shellTermios != nil {
+				job.termios = termios
+				bbos.Tcsetattr(0, shellTermios)
+			}
+			job.Stopped = true
+			addJob(job)
+			status = ws.ExitStatus()
+
+			fmt.Println()
+			job.print(NewStdio())
+			return nil
+		}
+		proc.Done = true
+		proc.Status = ws
+	}
+	removeJob(job)
+
+	last := job.Procs[len(job.Procs)-1]
+	status = last.Status.ExitStatus()
+	reportStatus(last.Pid, last.Status, last.Name)
+
+	return nil
+}
+
+// continueJob continues the stopped job.
+func continueJob(job *Job) error {
+	if !job.Stopped {
+		return nil
+	}
+	job.Stopped = false
+	return bbos.Kill(-job.Pgid, bbos.SIGCONT)
+}
+
+func cmd_jobs(stdio *Stdio, args []string) {
+	reapJobs(stdio, false)
+	for _, job := range jobs {
+		job.print(stdio)
+	}
+}
+
+func jobArg(stdio *Stdio, args []string) *Job {
+	var spec string
+	if len(args) > 1 {
+		spec = args[1]
+	}
+	job, err := findJob(spec)
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "%s: %s\n", args[0], err)
+		return nil
+	}
+	return job
+}
+
+func cmd_fg(stdio *Stdio, args []string) {
+	job := jobArg(stdio, args)
+	if job == nil {
+		return
+	}
+	fmt.Fprintf(stdio.Stdout, "%s\n", job.Cmd)
+
+	if job.termios != nil {
+		bbos.Tcsetattr(0, job.termios)
+		job.termios = nil
+	}
+	foreground(job.Pgid)
+	err := continueJob(job)
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "fg: %s\n", err)
+	}
+	err = waitForeground(job)
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "fg: %s\n", err)
+	}
+}
+
+func cmd_bg(stdio *Stdio, args []string) {
+	job := jobArg(stdio, args)
+	if job == nil {
+		return
+	}
+	if !job.Stopped {
+		fmt.Fprintf(stdio.Stderr, "bg: job %d already in background\n",
+			job.ID)
+		return
+	}
+	err := continueJob(job)
+	if err != nil {
+		fmt.Fprintf(stdio.Stderr, "bg: %s\n", err)
+		return
+	}
+	fmt.Fprintf(stdio.Stdout, "[%d]+ %s &\n", job.ID, job.Cmd)
+}
@@ -0,0 +1,73 @@
+//
+// jobs_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"testing"
+)
+
+func TestFindJob(t *testing.T) {
+	jobs = nil
+	defer func() {
+		jobs = nil
+	}()
+
+	_, err := findJob("")
+	if err == nil {
+		t.Errorf("findJob succeeded with empty job table")
+	}
+
+	j1 := &Job{Pgid: 10, Cmd: "a"}
+	j2 := &Job{Pgid: 20, Cmd: "b"}
+	j3 := &Job{Pgid: 30, Cmd: "c"}
+	addJob(j1)
+	addJob(j2)
+	addJob(j3)
+	addJob(j3)
+
+	if len(jobs) != 3 || j1.ID != 1 || j2.ID != 2 || j3.ID != 3 {
+		t.Fatalf("unexpected job table: %v", jobs)
+	}
+
+	tests := map[string]*Job{
+		"":   j3,
+		"%%": j3,
+		"%+": j3,
+		"%-": j2,
+		"%1": j1,
+		"2":  j2,
+	}
+	for spec, expected := range tests {
+		job, err := findJob(spec)
+		if err != nil {
+			t.Errorf("findJob(%q) failed: %s", spec, err)
+			continue
+		}
+		if job != expected {
+			t.Errorf("findJob(%q)=%d, expected %d", spec, job.ID, expected.ID)
+		}
+	}
+	for _, spec := range []string{"%4", "%x", "0"} {
+		_, err := findJob(spec)
+		if err == nil {
+			t.Errorf("findJob(%q) succeeded", spec)
+		}
+	}
+
+	removeJob(j2)
+	j4 := &Job{Pgid: 40, Cmd: "d"}
+	addJob(j4)
+	if j4.ID != 4 {
+		t.Errorf("unexpected job ID %d, expected 4", j4.ID)
+	}
+	job, err := findJob("%-")
+	if err != nil || job != j3 {
+		t.Errorf("findJob(%%-)=%v, %v, expected %v", job, err, j3)
+	}
+}
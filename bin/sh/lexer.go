@@ -0,0 +1,428 @@
+//
+// lexer.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrIncomplete is returned when the input ends inside a quoted
+// string or after an escape character.
+var ErrIncomplete = errors.New("unexpected end of input")
+
+// TokenType specifies the token types.
+type TokenType int
+
+// Token types.
+const (
+	TWord TokenType = iota
+	TOperator
+)
+
+var tokenTypeNames = map[TokenType]string{
+	TWord:     "word",
+	TOperator: "operator",
+}
+
+func (t TokenType) String() string {
+	name, ok := tokenTypeNames[t]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{TokenType %d}", t)
+}
+
+// Token defines an input token. The Start and End specify the token's
+// rune positions in the input.
+type Token struct {
+	Type   TokenType
+	Value  string
+	Quoted bool
+	Start  int
+	End    int
+}
+
+func (t Token) String() string {
+	return fmt.Sprintf("%s:%s", t.Type, t.Value)
+}
+
+// Lookup returns the value of the variable name. The value can
+// contain FieldSeparator characters which split the value into
+// separate words, also inside double quotes.
+type Lookup func(name string) string
+
+// FieldSeparator separates the words in variable values.
+const FieldSeparator = "\x00"
+
+// Lexer splits shell input into words and operators. The lexer
+// removes quotes and escapes, and expands variables and the tilde
+// prefix of words. The unquoted variable values are split into
+// words at whitespace. The newlines are returned as operator tokens.
+type Lexer struct {
+	lookup   Lookup
+	input    []rune
+	pos      int
+	expanded bool
+	emptyAt  bool
+}
+
+// Lex splits the input line into tokens. The variables are expanded
+// with the lookup function. If the line ends inside a quoted string,
+// Lex returns the tokens parsed so far and the ErrIncomplete error.
+func Lex(line string, lookup Lookup) ([]Token, error) {
+	lexer := &Lexer{
+		lookup: lookup,
+		input:  []rune(line),
+	}
+	var result []Token
+	for {
+		lexer.expanded = false
+		lexer.emptyAt = false
+		token, err := lexer.next()
+		if token != nil {
+			result = append(result, lexer.fields(token)...)
+		}
+		if err != nil {
+			return result, err
+		}
+		if token == nil {
+			return result, nil
+		}
+	}
+}
+
+// fields splits the word token at the field separators. The unquoted
+// words which expanded to empty values are removed.
+func (l *Lexer) fields(token *Token) []Token {
+	if token.Type != TWord || !l.expanded {
+		return []Token{*token}
+	}
+	parts := strings.Split(token.Value, FieldSeparator)
+	if len(parts) == 1 {
+		if len(token.Value) == 0 && (!token.Quoted || l.emptyAt) {
+			return nil
+		}
+		return []Token{*token}
+	}
+	var result []Token
+	for _, part := range parts {
+		if len(part) == 0 {
+			continue
+		}
+		t := *token
+		t.Value = part
+		result = append(result, t)
+	}
+	return result
+}
+
+func isOperator(r rune) bool {
+	switch r {
+	case '|', '&', ';', '<', '>', '(', ')', '\n':
+		return true
+	default:
+		return false
+	}
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || unicode.IsDigit(r)
+}
+
+func (l *Lexer) peek(ofs int) rune {
+	if l.pos+ofs >= len(l.input) {
+		return 0
+	}
+	return l.input[l.pos+ofs]
+}
+
+func (l *Lexer) next() (*Token, error) {
+	// Skip whitespace and line continuations.
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		if r == '\\' && l.peek(1) == '\n' {
+			l.pos += 2
+		} else if r != '\n' && unicode.IsSpace(r) {
+			l.pos++
+		} else {
+			break
+		}
+	}
+	if l.pos >= len(l.input) {
+		return nil, nil
+	}
+
+	r := l.input[l.pos]
+	if r == '#' {
+		// Comment until the end of line.
+		for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+			l.pos++
+		}
+		return l.next()
+	}
+	if isOperator(r) {
+		return l.operator(""), nil
+	}
+	if r >= '0' && r <= '9' && (l.peek(1) == '<' || l.peek(1) == '>') {
+		// I/O number of redirection.
+		l.pos++
+		return l.operator(string(r)), nil
+	}
+	return l.word()
+}
+
+func (l *Lexer) operator(ionumber string) *Token {
+	start := l.pos
+	if len(ionumber) > 0 {
+		start--
+	}
+	var op string
+
+	r := l.input[l.pos]
+	switch r {
+	case '|', '&', ';':
+		if l.peek(1) == r {
+			op = string([]rune{r, r})
+		} else {
+			op = string(r)
+		}
+
+	case '>':
+		switch l.peek(1) {
+		case '>':
+			op = ">>"
+		case '&':
+			op = ">&"
+			if d := l.peek(2); d >= '0' && d <= '9' {
+				op += string(d)
+			}
+		default:
+			op = ">"
+		}
+
+	default:
+		op = string(r)
+	}
+	l.pos += len([]rune(op))
+
+	return &Token{
+		Type:  TOperator,
+		Value: ionumber + op,
+		Start: start,
+		End:   l.pos,
+	}
+}
+
+func (l *Lexer) word() (*Token, error) {
+	var sb strings.Builder
+
+	token := &Token{
+		Type:  TWord,
+		Start: l.pos,
+	}
+
+	// Tilde prefix.
+	if l.input[l.pos] == '~' {
+		next := l.peek(1)
+		home := l.lookup("HOME")
+		if len(home) > 0 && (next == 0 || next == '/' ||
+			unicode.IsSpace(next) || isOperator(next)) {
+			sb.WriteString(home)
+			l.pos++
+		}
+	}
+
+	// The values of variable assignments are not split into words.
+	assignment := l.assignment()
+
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		if unicode.IsSpace(r) || isOperator(r) {
+			break
+		}
+		switch r {
+		case '\\':
+			if l.pos+1 >= len(l.input) {
+				l.pos++
+				token.End = l.pos
+				token.Value = sb.String()
+				return token, ErrIncomplete
+			}
+			if l.input[l.pos+1] != '\n' {
+				sb.WriteRune(l.input[l.pos+1])
+			}
+			l.pos += 2
+
+		case '\'':
+			token.Quoted = true
+			l.pos++
+			for l.pos < len(l.input) && l.input[l.pos] != '\'' {
+				sb.WriteRune(l.input[l.pos])
+				l.pos++
+			}
+			if l.pos >= len(l.input) {
+				token.End = l.pos
+				token.Value = sb.String()
+				return token, ErrIncomplete
+			}
+			l.pos++
+
+		case '"':
+			token.Quoted = true
+			l.pos++
+			if !l.doubleQuoted(&sb) {
+				token.End = l.pos
+				token.Value = sb.String()
+				return token, ErrIncomplete
+			}
+
+		case '$':
+			l.variable(&sb, assignment)
+
+		default:
+			sb.WriteRune(r)
+			l.pos++
+		}
+	}
+	token.End = l.pos
+	token.Value = sb.String()
+
+	return token, nil
+}
+
+// doubleQuoted reads a double-quoted string. The function returns
+// false if the input ends before the closing quote.
+func (l *Lexer) doubleQuoted(sb *strings.Builder) bool {
+	for l.pos < len(l.input) {
+		r := l.input[l.pos]
+		switch r {
+		case '"':
+			l.pos++
+			return true
+
+		case '\\':
+			next := l.peek(1)
+			switch next {
+			case '$', '`', '"', '\\':
+				sb.WriteRune(next)
+				l.pos += 2
+			case 0:
+				l.pos++
+				return false
+			default:
+				sb.WriteRune(r)
+				l.pos++
+			}
+
+		case '$':
+			l.variable(sb, true)
+
+		default:
+			sb.WriteRune(r)
+			l.pos++
+		}
+	}
+	return false
+}
+
+// assignment tests if the word at the current position starts with
+// a variable assignment prefix NAME=.
+func (l *Lexer) assignment() bool {
+	for i := l.pos; i < len(l.input); i++ {
+		r := l.input[i]
+		if r == '=' {
+			return i > l.pos
+		}
+		if i == l.pos && !isNameStart(r) || !isNameChar(r) {
+			return false
+		}
+	}
+	return false
+}
+
+// variable expands the variable reference starting from the current
+// position. The quoted specifies if the reference is inside double
+// quotes.
+func (l *Lexer) variable(sb *strings.Builder, quoted bool) {
+	next := l.peek(1)
+
+	switch {
+	case next == '{':
+		end := l.pos + 2
+		for end < len(l.input) && l.input[end] != '}' {
+			end++
+		}
+		if end >= len(l.input) {
+			// Unterminated reference, keep it as-is.
+			sb.WriteString(string(l.input[l.pos:]))
+			l.pos = len(l.input)
+			return
+		}
+		l.expand(sb, string(l.input[l.pos+2:end]), quoted)
+		l.pos = end + 1
+
+	case isNameStart(next):
+		end := l.pos + 1
+		for end < len(l.input) && isNameChar(l.input[end]) {
+			end++
+		}
+		l.expand(sb, string(l.input[l.pos+1:end]), quoted)
+		l.pos = end
+
+	case strings.ContainsRune("?#@$!", next) && next != 0,
+		next >= '0' && next <= '9':
+		l.expand(sb, string(next), quoted)
+		l.pos += 2
+
+	default:
+		sb.WriteRune('$')
+		l.pos++
+	}
+}
+
+// expand writes the value of the variable name. The unquoted values
+// are split into words.
+func (l *Lexer) expand(sb *strings.Builder, name string, quoted bool) {
+	value := l.lookup(name)
+	l.expanded = true
+
+	if quoted {
+		if name == "@" && len(value) == 0 {
+			l.emptyAt = true
+		}
+		sb.WriteString(value)
+		return
+	}
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return unicode.IsSpace(r) || string(r) == FieldSeparator
+	})
+	if len(fields) == 0 {
+		if len(value) > 0 {
+			sb.WriteString(FieldSeparator)
+		}
+		return
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	if unicode.IsSpace(r) {
+		sb.WriteString(FieldSeparator)
+	}
+	sb.WriteString(strings.Join(fields, FieldSeparator))
+	r, _ = utf8.DecodeLastRuneInString(value)
+	if unicode.IsSpace(r) {
+		sb.WriteString(FieldSeparator)
+	}
+}
@@ -0,0 +1,174 @@
+//
+// lexer_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var lexVars = map[string]string{
+	"HOME": "/home/user",
+	"USER": "user",
+	"?":    "1",
+	"1":    "first",
+	"LIST": " a  b ",
+	"@":    "x y" + FieldSeparator + "z",
+	"NONE": "",
+}
+
+func lexLookup(name string) string {
+	return lexVars[name]
+}
+
+var lexTests = map[string][]string{
+	"":                     nil,
+	"  ls  -l  ":           {"ls", "-l"},
+	"ls # comment":         {"ls"},
+	"echo 'a b'  c":        {"echo", "a b", "c"},
+	`echo "a  b"`:          {"echo", "a  b"},
+	`echo a\ b`:            {"echo", "a b"},
+	`echo "a\"b\\c\d"`:     {"echo", `a"b\c\d`},
+	`echo 'a\b'`:           {"echo", `a\b`},
+	"echo $USER ${USER}x":  {"echo", "user", "userx"},
+	`echo "$USER" '$USER'`: {"echo", "user", "$USER"},
+	"echo $? $1 $":         {"echo", "1", "first", "$"},
+	"echo $UNDEFINED.":     {"echo", "."},
+	"cd ~ ~/bin a~":        {"cd", "/home/user", "/home/user/bin", "a~"},
+	"a|b":                  {"a", "|", "b"},
+	"a||b&&c;d&":           {"a", "||", "b", "&&", "c", ";", "d", "&"},
+	"cat <in >out 2>err":   {"cat", "<", "in", ">", "out", "2>", "err"},
+	"cat >>out 2>&1 >&2":   {"cat", ">>", "out", "2>&1", ">&2"},
+	"echo a2>x '2'>y":      {"echo", "a2", ">", "x", "2", ">", "y"},
+	"echo \"a|b\" a\\|b":   {"echo", "a|b", "a|b"},
+	"a\nb # c\nd":          {"a", "\n", "b", "\n", "d"},
+	"a \\\nb":              {"a", "b"},
+	"a;;b":                 {"a", ";;", "b"},
+	"for x in $LIST;":      {"for", "x", "in", "a", "b", ";"},
+	"echo x$LIST\"y\"":     {"echo", "x", "a", "b", "y"},
+	`echo "$LIST"`:         {"echo", " a  b "},
+	`echo "$@" $@`:         {"echo", "x y", "z", "x", "y", "z"},
+	`echo $NONE "$NONE"`:   {"echo", ""},
+}
+
+func TestLex(t *testing.T) {
+	for input, expected := range lexTests {
+		tokens, err := Lex(input, lexLookup)
+		if err != nil {
+			t.Errorf("Lex(%q) failed: %s", input, err)
+			continue
+		}
+		var values []string
+		for _, token := range tokens {
+			values = append(values, token.Value)
+		}
+		if !reflect.DeepEqual(values, expected) {
+			t.Errorf("Lex(%q)=%q, expected %q", input, values, expected)
+		}
+	}
+}
+
+func TestLexTypes(t *testing.T) {
+	tokens, err := Lex("ls '|' | wc", lexLookup)
+	if err != nil {
+		t.Fatalf("Lex failed: %s", err)
+	}
+	types := []TokenType{TWord, TWord, TOperator, TWord}
+	if len(tokens) != len(types) {
+		t.Fatalf("unexpected tokens: %v", tokens)
+	}
+	for idx, token := range tokens {
+		if token.Type != types[idx] {
+			t.Errorf("token %d: got %s, expected %s", idx, token.Type,
+				types[idx])
+		}
+	}
+	if !tokens[1].Quoted || tokens[0].Quoted {
+		t.Errorf("unexpected Quoted flags: %v", tokens)
+	}
+}
+
+func TestLexPositions(t *testing.T) {
+	input := "cat 'ä b' x\\ y"
+	tokens, err := Lex(input, lexLookup)
+	if err != nil {
+		t.Fatalf("Lex failed: %s", err)
+	}
+	runes := []rune(input)
+	expected := []string{"cat", "'ä b'", "x\\ y"}
+	for idx, token := range tokens {
+		src := string(runes[token.Start:token.End])
+		if src != expected[idx] {
+			t.Errorf("token %d: source %q, expected %q", idx, src,
+				expected[idx])
+		}
+	}
+}
+
+var lexIncompleteTests = map[string]string{
+	"echo 'abc":   "abc",
+	`echo "a b`:   "a b",
+	`echo a\`:     "a",
+	`echo "a\`:    "a",
+	`echo "$USER`: "user",
+}
+
+func TestLexIncomplete(t *testing.T) {
+	for input, last := range lexIncompleteTests {
+		tokens, err := Lex(input, lexLookup)
+		if err != ErrIncomplete {
+			t.Errorf("Lex(%q): got error %v, expected %v", input, err,
+				ErrIncomplete)
+			continue
+		}
+		if len(tokens) != 2 || tokens[1].Value != last {
+			t.Errorf("Lex(%q)=%v, expected last token %q", input, tokens, last)
+		}
+	}
+}
+
+func TestParsePipeline(t *testing.T) {
+	tokens, err := Lex("cat <in a 2>&1 | wc -l >>out", lexLookup)
+	if err != nil {
+		t.Fatalf("Lex failed: %s", err)
+	}
+	pipeline, err := parsePipeline(tokens)
+	if err != nil {
+		t.Fatalf("parsePipeline failed: %s", err)
+	}
+	expected := Pipeline{
+		{
+			Args: CommandLine{"cat", "a"},
+			Redirects: []Redirect{
+				{FD: 0, Op: "<", Target: "in"},
+				{FD: 2, Op: ">&", DupFD: 1},
+			},
+		},
+		{
+			Args: CommandLine{"wc", "-l"},
+			Redirects: []Redirect{
+				{FD: 1, Op: ">>", Target: "out"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(pipeline, expected) {
+		t.Errorf("parsePipeline=%v, expected %v", pipeline, expected)
+	}
+
+	for _, input := range []string{"| wc", "ls |", "ls >", "ls > |", "a ; b"} {
+		tokens, err := Lex(input, lexLookup)
+		if err != nil {
+			t.Fatalf("Lex(%q) failed: %s", input, err)
+		}
+		_, err = parsePipeline(tokens)
+		if err == nil {
+			t.Errorf("parsePipeline(%q) succeeded", input)
+		}
+	}
+}
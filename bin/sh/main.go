@@ -15,28 +15,58 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/markkurossi/blackbox-os/lib/bbos"
 	"github.com/markkurossi/blackbox-os/lib/file"
 	"github.com/markkurossi/blackbox-os/lib/readline"
 )
 
-var shellPrompt = "bbos \\W $ "
+var defaultPrompt = "bbos \\W $ "
 
 type Builtin struct {
 	Name string
-	Cmd  func(args []string)
+	Cmd  func(stdio *Stdio, args []string)
 }
 
 var (
-	builtin  []Builtin
-	builtins map[string]Builtin
-	running  = true
+	builtin   []Builtin
+	builtins  map[string]Builtin
+	running   = true
+	status    int
+	shellPgrp int
 )
 
+// lookupVar returns the value of the shell variable name.
+func lookupVar(name string) string {
+	switch name {
+	case "?":
+		return strconv.Itoa(status)
+	case "#":
+		return strconv.Itoa(len(positional) - 1)
+	case "@":
+		return strings.Join(positional[1:], FieldSeparator)
+	case "*":
+		return strings.Join(positional[1:], " ")
+	case "$":
+		pid, _ := bbos.Getpid()
+		return strconv.Itoa(pid)
+	}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n >= 0 && n < len(positional) {
+			return positional[n]
+		}
+		return ""
+	}
+	value, _ := getVar(name)
+	return value
+}
+
 type CommandLine []string
 
 func (cl CommandLine) String() string {
@@ -51,19 +81,14 @@ func (cl CommandLine) String() string {
 	return result
 }
 
-func split(line string) CommandLine {
-	// XXX proper shell argument splitting
-	return strings.Split(line, " ")
-}
-
-var reCommandEscape = regexp.MustCompilePOSIX("([ \\'\"])")
+var reCommandEscape = regexp.MustCompilePOSIX("([ \t\\'\"$|&;<>()])")
 
 func CommandEscape(command string) string {
 	return reCommandEscape.ReplaceAllString(command, "\\${1}")
 }
 
-func cmd_help(args []string) {
-	fmt.Fprintf(os.Stdout, "Available commands are:\n")
+func cmd_help(stdio *Stdio, args []string) {
+	fmt.Fprintf(stdio.Stdout, "Available commands are:\n")
 
 	names := make([]string, 0, len(builtin))
 	for _, cmd := range builtin {
@@ -72,7 +97,7 @@ func cmd_help(args []string) {
 	sort.Strings(names)
 
 	for _, name := range names {
-		fmt.Fprintf(os.Stdout, "  %s\n", name)
+		fmt.Fprintf(stdio.Stdout, "  %s\n", name)
 	}
 }
 
@@ -88,12 +113,6 @@ func init() {
 		// 		js.Global().Get("alert").Invoke(strings.Join(args[1:], " "))
 		// 	},
 		// },
-		Builtin{
-			Name: "exit",
-			Cmd: func(args []string) {
-				running = false
-			},
-		},
 		Builtin{
 			Name: "help",
 			Cmd:  cmd_help,
@@ -123,62 +142,419 @@ func main() {
 	for _, bi := range builtin {
 		builtins[bi.Name] = bi
 	}
+	positional = []string{"sh"}
+
+	if len(os.Args) > 1 {
+		os.Exit(runArgs(os.Args[1:]))
+	}
+	interactive = true
+
+	// The interactive shell is not terminated by interrupts and it
+	// is not stopped by the job control signals.
+	bbos.Ignore(bbos.SIGINT, bbos.SIGTERM, bbos.SIGTSTP, bbos.SIGTTIN)
+	shellPgrp, _ = bbos.Getpgrp()
+	shellTermios, _ = bbos.Tcgetattr(0)
 
 	rl := readline.NewReadline(os.Stdin, os.Stdout, os.Stderr)
 	rl.Tab = func(line string) (string, []string) {
 		return tabCompletion(line)
 	}
+	rl.Quote = CommandEscape
+
+	// Run the login scripts.
+	sourceFile("/etc/profile")
+	if home, _ := getVar("HOME"); len(home) > 0 {
+		sourceFile(path.Join(home, ".shrc"))
+	}
+	loadHistory()
+	rl.History = history
 
+	var input string
 	for running {
-		line, err := rl.Read(prompt())
+		reapJobs(NewStdio(), true)
+
+		p := prompt()
+		if len(input) > 0 {
+			p = "> "
+		}
+		// Tab cycles through the completions if MENU_COMPLETE is set.
+		menu, _ := getVar("MENU_COMPLETE")
+		rl.MenuSelect = len(menu) > 0
+		line, err := rl.Read(p)
 		fmt.Fprintf(os.Stdout, "\n")
 		if err != nil {
 			log.Fatal(err)
 		}
-		args := split(line)
-		if len(args) == 0 || len(args[0]) == 0 {
+		expanded, err := expandHistory(line, history)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sh: %s\n", err)
+			status = 1
+			input = ""
 			continue
 		}
+		if expanded != line {
+			fmt.Fprintf(os.Stdout, "%s\n", expanded)
+		}
+		addHistory(expanded)
 
-		err = runCommand(args)
+		input += expanded
+		list, err := Parse(input)
+		if err == ErrIncomplete {
+			input += "\n"
+			continue
+		}
+		input = ""
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s: %s\n", args[0], err)
+			fmt.Fprintf(os.Stderr, "sh: %s\n", err)
+			status = 2
+			continue
 		}
+		execList(list)
+		flow = FlowNext
 	}
+	saveHistory()
 }
 
-func runCommand(args []string) error {
-	bi, ok := builtins[args[0]]
-	if ok {
-		os.Args = args
-		flag.CommandLine = flag.NewFlagSet(args[0], flag.ContinueOnError)
-		flag.CommandLine.SetOutput(os.Stdout)
-		bi.Cmd(args)
-	} else {
-		// Run as process.
-		pid, err := bbos.Spawn(args, []int{
-			int(os.Stdin.Fd()),
-			int(os.Stdout.Fd()),
-			int(os.Stderr.Fd()),
-		})
-		if err != nil {
-			return err
+// runArgs runs the shell non-interactively with the command line
+// arguments args. It returns the exit status of the shell.
+func runArgs(args []string) int {
+	if args[0] == "-c" {
+		if len(args) < 2 {
+			fmt.Fprintf(os.Stderr, "sh: -c: option requires an argument\n")
+			return 2
+		}
+		if len(args) > 2 {
+			positional = args[2:]
+		}
+		runScript("-c", args[1])
+		return status
+	}
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "sh: %s\n", err)
+		return 127
+	}
+	positional = args
+	runScript(args[0], string(data))
+	return status
+}
+
+// Command defines a command with its arguments and I/O
+// redirections.
+type Command struct {
+	Args      CommandLine
+	Redirects []Redirect
+}
+
+// name returns the command name.
+func (cmd *Command) name() string {
+	if len(cmd.Args) == 0 {
+		return ""
+	}
+	return cmd.Args[0]
+}
+
+// assignment tests if the command assigns variables.
+func (cmd *Command) assignment() bool {
+	if len(cmd.Args) == 0 || len(cmd.Redirects) > 0 {
+		return false
+	}
+	for _, arg := range cmd.Args {
+		idx := strings.IndexByte(arg, '=')
+		if idx <= 0 || !validName(arg[:idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (cmd *Command) empty() bool {
+	return len(cmd.Args) == 0 && len(cmd.Redirects) == 0
+}
+
+// external tests if the command is run as a process.
+func (cmd *Command) external() bool {
+	if len(cmd.Args) == 0 {
+		return false
+	}
+	_, ok := builtins[cmd.Args[0]]
+	return !ok
+}
+
+// Pipeline defines a sequence of commands where the standard output
+// of each command is connected to the standard input of the next
+// command.
+type Pipeline []*Command
+
+func parsePipeline(tokens []Token) (Pipeline, error) {
+	var result Pipeline
+	cmd := new(Command)
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.Type == TWord {
+			cmd.Args = append(cmd.Args, t.Value)
+			continue
+		}
+		if t.Value == "\n" {
+			continue
+		}
+		if t.Value == "|" {
+			if cmd.empty() {
+				return nil, fmt.Errorf("syntax error near unexpected token `|'")
+			}
+			result = append(result, cmd)
+			cmd = new(Command)
+			continue
+		}
+		r, ok := newRedirect(t.Value)
+		if !ok {
+			return nil, fmt.Errorf("syntax error near unexpected token `%s'",
+				t.Value)
+		}
+		if r.Op != ">&" {
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf(
+					"syntax error near unexpected token `newline'")
+			}
+			i++
+			if tokens[i].Type != TWord {
+				return nil, fmt.Errorf(
+					"syntax error near unexpected token `%s'", tokens[i].Value)
+			}
+			r.Target = tokens[i].Value
+		}
+		cmd.Redirects = append(cmd.Redirects, *r)
+	}
+	if cmd.empty() {
+		if len(result) > 0 {
+			return nil, fmt.Errorf("syntax error: unexpected end of pipeline")
+		}
+		return nil, nil
+	}
+	return append(result, cmd), nil
+}
+
+// foreground sets the process group pgrp as the foreground process
+// group of the shell's terminal. The errors are ignored since the
+// shell's standard input is not necessarily a terminal.
+func foreground(pgrp int) {
+	if !interactive {
+		return
+	}
+	bbos.Tcsetpgrp(0, pgrp)
+}
+
+// reportStatus prints the termination status of the process if it
+// did not exit normally.
+func reportStatus(pid int, ws bbos.WaitStatus, name string) {
+	if !interactive {
+		return
+	}
+	switch {
+	case ws.Signaled():
+		if ws.Signal != bbos.SIGINT {
+			fmt.Printf("%d: %s: %s\n", pid, ws.Signal, name)
+		}
+	case ws.Code != 0:
+		fmt.Printf("%d: Exit %d: %s\n", pid, ws.Code, name)
+	}
+}
+
+// pipelineBuiltin defines a builtin command of a pipeline. The
+// builtins run in the shell after the pipeline's processes are
+// started.
+type pipelineBuiltin struct {
+	stdio *Stdio
+	args  []string
+	last  bool
+}
+
+// runPipeline runs the pipeline as a job. The text is the command
+// line of the job. If background is true, the job is started in the
+// background. The exit status of the pipeline is the exit status of
+// its last command.
+func runPipeline(pipeline Pipeline, text string, background bool) error {
+	job := &Job{
+		Cmd: text,
+	}
+	var stdin *bbos.File
+	var pending []*pipelineBuiltin
+	var err error
+
+	for idx, cmd := range pipeline {
+		stdio := NewStdio()
+		if stdin != nil {
+			stdio.Stdin = stdio.Own(stdin)
+			stdin = nil
+		}
+		if idx+1 < len(pipeline) {
+			var r, w int
+			r, w, err = bbos.Pipe()
+			if err != nil {
+				stdio.Close()
+				break
+			}
+			stdin = bbos.NewFile(r, "pipe")
+			stdio.Stdout = stdio.Own(bbos.NewFile(w, "pipe"))
+		}
+
+		var pid int
+		var builtin bool
+		args := cmd.Args
+
+		if len(args) == 0 {
+			err = fmt.Errorf("syntax error: missing command")
+		}
+		if err == nil {
+			_, builtin = builtins[args[0]]
+			if builtin && background {
+				err = fmt.Errorf(
+					"%s: builtin can't be used in a background pipeline",
+					args[0])
+			} else if !builtin && functions[args[0]] != nil {
+				err = fmt.Errorf("%s: function can't be used in a pipeline",
+					args[0])
+			}
 		}
-		code, err := bbos.Wait(pid)
+		if err == nil {
+			err = stdio.Redirect(cmd.Redirects)
+		}
+		if err == nil && builtin {
+			// The builtin's files are closed when it terminates.
+			pending = append(pending, &pipelineBuiltin{
+				stdio: stdio,
+				args:  args,
+				last:  idx+1 == len(pipeline),
+			})
+			continue
+		}
+		if err == nil {
+			pid, err = bbos.Spawn(interpreter(args), &bbos.ProcAttr{
+				Env:     os.Environ(),
+				Files:   stdio.FDs(),
+				Setpgid: interactive,
+				Pgid:    job.Pgid,
+			})
+			if err != nil {
+				err = fmt.Errorf("%s: %s", args[0], err)
+			}
+		}
+		stdio.Close()
 		if err != nil {
-			return err
+			if stdin != nil {
+				stdin.Close()
+			}
+			break
+		}
+		job.add(pid, args[0])
+		if idx == 0 && !background {
+			// Give the terminal to the job before the later stages
+			// start reading it.
+			foreground(job.Pgid)
 		}
-		if code != 0 {
-			fmt.Printf("%d: Exit %d: %s\n", pid, code, args[0])
+	}
+	if err != nil {
+		// Close the pipes of the builtins so that the started
+		// processes see end of file.
+		for _, b := range pending {
+			b.stdio.Close()
 		}
+		pending = nil
 	}
+	if len(job.Procs) == 0 && len(pending) == 0 {
+		status = 127
+		return err
+	}
+
+	lastStatus = status
+	var wg sync.WaitGroup
+	var result int
+	var lastBuiltin bool
+	for _, b := range pending {
+		if b.last {
+			lastBuiltin = true
+			status = 0
+			runBuiltin(b.stdio, b.args)
+			result = status
+			b.stdio.Close()
+			continue
+		}
+		wg.Add(1)
+		go func(b *pipelineBuiltin) {
+			runBuiltin(b.stdio, b.args)
+			b.stdio.Close()
+			wg.Done()
+		}(b)
+	}
+
+	if len(job.Procs) == 0 {
+		wg.Wait()
+	} else if background {
+		addJob(job)
+		fmt.Printf("[%d] %d\n", job.ID, job.Pgid)
+		result = 0
+	} else {
+		werr := waitForeground(job)
+		if err == nil {
+			err = werr
+		}
+		if !lastBuiltin {
+			result = status
+		}
+		if !job.Stopped {
+			wg.Wait()
+		}
+	}
+	status = result
+	if err != nil {
+		status = 127
+	}
+	return err
+}
+
+// runCommand runs the builtin command cmd. If cmd has only
+// redirections, they are applied and the opened files are closed.
+func runCommand(cmd *Command) error {
+	args := cmd.Args
+
+	stdio := NewStdio()
+	defer stdio.Close()
+
+	lastStatus = status
+	status = 0
+
+	err := stdio.Redirect(cmd.Redirects)
+	if err != nil {
+		status = 1
+		return err
+	}
+	if len(args) == 0 {
+		return nil
+	}
+
+	runBuiltin(stdio, args)
+
 	return nil
 }
 
+// runBuiltin runs the builtin command args with the standard files
+// stdio.
+func runBuiltin(stdio *Stdio, args []string) {
+	os.Args = args
+	flag.CommandLine = flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flag.CommandLine.SetOutput(stdio.Stderr)
+	builtins[args[0]].Cmd(stdio, args)
+}
+
 func prompt() string {
 	var result []rune
 
-	prompt := []rune(shellPrompt)
+	ps1, ok := getVar("PS1")
+	if !ok {
+		ps1 = defaultPrompt
+	}
+	prompt := []rune(ps1)
 
 	for i := 0; i < len(prompt); i++ {
 		switch prompt[i] {
@@ -218,171 +594,3 @@ func prompt() string {
 
 	return string(result)
 }
-
-func tabCompletion(line string) (string, []string) {
-	parts := split(line)
-
-	if len(parts) == 0 {
-		return line, nil
-	}
-	last := parts[len(parts)-1]
-	if len(last) == 0 {
-		return line, nil
-	}
-
-	// 	if last[0] == '@' {
-	// 		return tabSnapshotCompletion(p, line, parts, last)
-	// 	}
-	//
-	return tabFileCompletion(line, parts, last)
-}
-
-// func tabSnapshotCompletion(p *process.Process, line string, parts CommandLine,
-// 	last string) (string, []string) {
-//
-// 	root := p.FS.Zone().HeadID
-// 	var result []string
-//
-// 	for !root.Undefined() {
-// 		element, err := tree.DeserializeID(root, p.FS.Zone())
-// 		if err != nil {
-// 			return line, nil
-// 		}
-// 		el, ok := element.(*tree.Snapshot)
-// 		if !ok {
-// 			return line, nil
-// 		}
-// 		id := fmt.Sprintf("@%s", root)
-// 		if strings.HasPrefix(id, last) {
-// 			result = append(result, id)
-// 		}
-// 		root = el.Parent
-// 	}
-//
-// 	switch len(result) {
-// 	case 0:
-// 		return line, nil
-// 	case 1:
-// 		parts[len(parts)-1] = result[0]
-// 		return parts.String(), nil
-// 	default:
-// 		parts[len(parts)-1] = commonPrefix(result)
-// 		return parts.String(), result
-// 	}
-// }
-
-func tabFileCompletion(line string, parts CommandLine, last string) (
-	string, []string) {
-
-	info, err := os.Stat(last)
-	if err == nil {
-		// An existing file.
-		if info.IsDir() {
-			if !strings.HasSuffix(last, "/") {
-				parts[len(parts)-1] = fmt.Sprintf("%s/", last)
-				return parts.String(), nil
-			}
-			files, err := ioutil.ReadDir(last)
-			if err != nil {
-				return line, nil
-			}
-			var arr []string
-			for _, i := range files {
-				name := i.Name()
-				if i.IsDir() {
-					name += "/"
-				}
-				arr = append(arr, name)
-			}
-			switch len(arr) {
-			case 0:
-				return line, nil
-			case 1:
-				parts[len(parts)-1] = makeFilename(last, arr[0])
-				return parts.String(), nil
-			default:
-				return parts.String(), arr
-			}
-		} else {
-			// Return the line unmodified.
-			return line, nil
-		}
-	}
-
-	// Check if `last' is a file name prefix.
-	path := file.PathSplit(last)
-	var dir string
-	if len(path) > 1 {
-		last = path[len(path)-1]
-		path = path[:len(path)-1]
-		dir = path.String()
-	} else {
-		path = []string{}
-		dir = "."
-	}
-	info, err = os.Stat(dir)
-	if err != nil {
-		return line, nil
-	}
-	if info.IsDir() {
-		files, err := ioutil.ReadDir(dir)
-		if err != nil {
-			return line, nil
-		}
-		var arr []string
-		for _, i := range files {
-			if strings.HasPrefix(i.Name(), last) {
-				name := i.Name()
-				if i.IsDir() {
-					name += "/"
-				}
-				arr = append(arr, name)
-			}
-		}
-		switch len(arr) {
-		case 0:
-			return line, nil
-		case 1:
-			parts[len(parts)-1] = makeFilename(path.String(), arr[0])
-			return parts.String(), nil
-		default:
-			parts[len(parts)-1] = commonPrefix(arr)
-			return parts.String(), arr
-		}
-	} else {
-		// Return the line unmodified.
-		return line, nil
-	}
-}
-
-func makeFilename(prefix, file string) string {
-	if len(prefix) == 0 {
-		return file
-	} else if prefix[len(prefix)-1] == '/' {
-		return fmt.Sprintf("%s%s", prefix, file)
-	} else {
-		return fmt.Sprintf("%s/%s", prefix, file)
-	}
-}
-
-func commonPrefix(values []string) string {
-	var prefix string
-
-	for idx, val := range values {
-		if idx == 0 {
-			prefix = val
-		}
-		var l = len(prefix)
-		if len(val) < l {
-			l = len(val)
-		}
-		var i int
-		for i = 0; i < l; i++ {
-			if prefix[i] != val[i] {
-				break
-			}
-		}
-		prefix = prefix[:i]
-	}
-	return prefix
-}
@@ -0,0 +1,567 @@
+//
+// parser.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+)
+
+// List defines a sequence of and-or lists which are separated by
+// ';', '&', or newlines.
+type List struct {
+	Items []*AndOr
+}
+
+// AndOr defines pipelines which are separated by the '&&' and '||'
+// operators.
+type AndOr struct {
+	Pipelines  []*PipelineNode
+	Ops        []string
+	Background bool
+}
+
+// PipelineNode defines a pipeline. The pipelines of simple commands
+// are kept as source text which is expanded when the pipeline is
+// run. The compound commands can't be used in pipelines.
+type PipelineNode struct {
+	Text     string
+	Compound Node
+}
+
+// Node defines compound commands.
+type Node interface {
+	node()
+}
+
+// IfNode implements the if command. The Conds and Bodies hold the
+// conditions and bodies of the if and elif branches.
+type IfNode struct {
+	Conds  []*List
+	Bodies []*List
+	Else   *List
+}
+
+// LoopNode implements the while and until loops.
+type LoopNode struct {
+	Until bool
+	Cond  *List
+	Body  *List
+}
+
+// ForNode implements the for loop. If HasIn is false, the loop
+// iterates over the positional parameters.
+type ForNode struct {
+	Name  string
+	HasIn bool
+	Words string
+	Body  *List
+}
+
+// CaseNode implements the case command.
+type CaseNode struct {
+	Word  string
+	Items []CaseItem
+}
+
+// CaseItem defines the patterns and body of a case item.
+type CaseItem struct {
+	Patterns []string
+	Body     *List
+}
+
+// GroupNode implements the { list; } command group.
+type GroupNode struct {
+	Body *List
+}
+
+// FuncNode defines a shell function.
+type FuncNode struct {
+	Name string
+	Body Node
+}
+
+func (n *IfNode) node()    {}
+func (n *LoopNode) node()  {}
+func (n *ForNode) node()   {}
+func (n *CaseNode) node()  {}
+func (n *GroupNode) node() {}
+func (n *FuncNode) node()  {}
+
+var reserved = map[string]bool{
+	"if":    true,
+	"then":  true,
+	"else":  true,
+	"elif":  true,
+	"fi":    true,
+	"while": true,
+	"until": true,
+	"do":    true,
+	"done":  true,
+	"for":   true,
+	"case":  true,
+	"esac":  true,
+	"{":     true,
+	"}":     true,
+}
+
+type parser struct {
+	input  []rune
+	tokens []Token
+	pos    int
+}
+
+// rawLookup keeps the variable references in the parsed source so
+// that they are expanded when the commands are run.
+func rawLookup(name string) string {
+	return "$" + name
+}
+
+// Parse parses the shell script. The function returns the
+// ErrIncomplete error if the script ends before all its commands are
+// complete.
+func Parse(script string) (*List, error) {
+	tokens, err := Lex(script, rawLookup)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{
+		input:  []rune(script),
+		tokens: tokens,
+	}
+	list, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t != nil {
+		return nil, p.unexpected(t)
+	}
+	return list, nil
+}
+
+func (p *parser) peek() *Token {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *parser) text(from, to int) string {
+	return string(p.input[p.tokens[from].Start:p.tokens[to].End])
+}
+
+func isOp(t *Token, value string) bool {
+	return t != nil && t.Type == TOperator && t.Value == value
+}
+
+func isKeyword(t *Token, value string) bool {
+	return t != nil && t.Type == TWord && !t.Quoted && t.Value == value
+}
+
+func isReserved(t *Token) bool {
+	return t != nil && t.Type == TWord && !t.Quoted && reserved[t.Value]
+}
+
+func (p *parser) unexpected(t *Token) error {
+	if t == nil {
+		return ErrIncomplete
+	}
+	value := t.Value
+	if value == "\n" {
+		value = "newline"
+	}
+	return fmt.Errorf("syntax error near unexpected token `%s'", value)
+}
+
+func (p *parser) skipNewlines() {
+	for isOp(p.peek(), "\n") {
+		p.pos++
+	}
+}
+
+func (p *parser) expect(keyword string) error {
+	t := p.peek()
+	if !isKeyword(t, keyword) {
+		return p.unexpected(t)
+	}
+	p.pos++
+	return nil
+}
+
+// terminator tests if the token ends the current list.
+func (p *parser) terminator(t *Token) bool {
+	if t == nil || isOp(t, ";;") || isOp(t, ")") {
+		return true
+	}
+	if !isReserved(t) {
+		return false
+	}
+	switch t.Value {
+	case "then", "else", "elif", "fi", "do", "done", "esac", "}":
+		return true
+	}
+	return false
+}
+
+func (p *parser) list() (*List, error) {
+	list := new(List)
+	for {
+		p.skipNewlines()
+		if p.terminator(p.peek()) {
+			return list, nil
+		}
+		ao, err := p.andOr()
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, ao)
+
+		t := p.peek()
+		switch {
+		case isOp(t, ";"), isOp(t, "\n"):
+			p.pos++
+		case isOp(t, "&"):
+			if len(ao.Pipelines) > 1 {
+				return nil, fmt.Errorf(
+					"background and-or lists are not supported")
+			}
+			ao.Background = true
+			p.pos++
+		case p.terminator(t):
+			return list, nil
+		default:
+			return nil, p.unexpected(t)
+		}
+	}
+}
+
+func (p *parser) andOr() (*AndOr, error) {
+	ao := new(AndOr)
+	for {
+		pipeline, err := p.pipeline()
+		if err != nil {
+			return nil, err
+		}
+		ao.Pipelines = append(ao.Pipelines, pipeline)
+
+		t := p.peek()
+		if !isOp(t, "&&") && !isOp(t, "||") {
+			return ao, nil
+		}
+		ao.Ops = append(ao.Ops, t.Value)
+		p.pos++
+		p.skipNewlines()
+	}
+}
+
+func (p *parser) pipeline() (*PipelineNode, error) {
+	start := p.pos
+	var compound Node
+	var count int
+
+	for {
+		node, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		if node != nil {
+			compound = node
+		}
+		count++
+
+		if !isOp(p.peek(), "|") {
+			break
+		}
+		p.pos++
+		p.skipNewlines()
+	}
+	if compound != nil {
+		if count > 1 {
+			return nil, fmt.Errorf(
+				"compound commands can't be used in a pipeline")
+		}
+		return &PipelineNode{
+			Compound: compound,
+		}, nil
+	}
+	return &PipelineNode{
+		Text: p.text(start, p.pos-1),
+	}, nil
+}
+
+// command parses a command. The function returns nil for simple
+// commands which are left in the token stream.
+func (p *parser) command() (Node, error) {
+	t := p.peek()
+	if isReserved(t) {
+		switch t.Value {
+		case "if":
+			return p.ifCommand()
+		case "while", "until":
+			return p.loop()
+		case "for":
+			return p.forCommand()
+		case "case":
+			return p.caseCommand()
+		case "{":
+			return p.group()
+		default:
+			return nil, p.unexpected(t)
+		}
+	}
+	if isKeyword(t, "function") {
+		p.pos++
+		t = p.peek()
+		if t == nil || t.Type != TWord {
+			return nil, p.unexpected(t)
+		}
+		p.pos++
+		if isOp(p.peek(), "(") {
+			p.pos++
+			if err := p.parens(); err != nil {
+				return nil, err
+			}
+		}
+		return p.function(t.Value)
+	}
+	if t != nil && t.Type == TWord && !t.Quoted &&
+		p.pos+1 < len(p.tokens) && isOp(&p.tokens[p.pos+1], "(") {
+		p.pos += 2
+		if err := p.parens(); err != nil {
+			return nil, err
+		}
+		return p.function(t.Value)
+	}
+	return nil, p.simple()
+}
+
+func (p *parser) parens() error {
+	t := p.peek()
+	if !isOp(t, ")") {
+		return p.unexpected(t)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) function(name string) (Node, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("`%s': not a valid identifier", name)
+	}
+	p.skipNewlines()
+	t := p.peek()
+	if !isReserved(t) {
+		return nil, p.unexpected(t)
+	}
+	body, err := p.command()
+	if err != nil {
+		return nil, err
+	}
+	return &FuncNode{
+		Name: name,
+		Body: body,
+	}, nil
+}
+
+// simple skips the tokens of a simple command.
+func (p *parser) simple() error {
+	start := p.pos
+	for ; p.pos < len(p.tokens); p.pos++ {
+		t := &p.tokens[p.pos]
+		if t.Type == TWord {
+			continue
+		}
+		r, ok := newRedirect(t.Value)
+		if !ok {
+			break
+		}
+		if r.Op == ">&" {
+			continue
+		}
+		p.pos++
+		if t = p.peek(); t == nil {
+			return fmt.Errorf("syntax error near unexpected token `newline'")
+		}
+		if t.Type != TWord {
+			return p.unexpected(t)
+		}
+	}
+	if p.pos == start {
+		return p.unexpected(p.peek())
+	}
+	return nil
+}
+
+func (p *parser) ifCommand() (Node, error) {
+	node := new(IfNode)
+	p.pos++
+	for {
+		cond, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		node.Conds = append(node.Conds, cond)
+		node.Bodies = append(node.Bodies, body)
+
+		t := p.peek()
+		if isKeyword(t, "elif") {
+			p.pos++
+			continue
+		}
+		if isKeyword(t, "else") {
+			p.pos++
+			node.Else, err = p.list()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return node, p.expect("fi")
+	}
+}
+
+func (p *parser) loop() (Node, error) {
+	node := &LoopNode{
+		Until: p.peek().Value == "until",
+	}
+	p.pos++
+
+	var err error
+	node.Cond, err = p.list()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	node.Body, err = p.list()
+	if err != nil {
+		return nil, err
+	}
+	return node, p.expect("done")
+}
+
+func (p *parser) forCommand() (Node, error) {
+	p.pos++
+	t := p.peek()
+	if t == nil || t.Type != TWord {
+		return nil, p.unexpected(t)
+	}
+	if !validName(t.Value) {
+		return nil, fmt.Errorf("`%s': not a valid identifier", t.Value)
+	}
+	node := &ForNode{
+		Name: t.Value,
+	}
+	p.pos++
+	if isOp(p.peek(), ";") {
+		p.pos++
+	}
+	p.skipNewlines()
+	if isKeyword(p.peek(), "in") {
+		p.pos++
+		node.HasIn = true
+		start := p.pos
+		for t = p.peek(); t != nil && t.Type == TWord; t = p.peek() {
+			p.pos++
+		}
+		if p.pos > start {
+			node.Words = p.text(start, p.pos-1)
+		}
+		if !isOp(t, ";") && !isOp(t, "\n") {
+			return nil, p.unexpected(t)
+		}
+		p.pos++
+		p.skipNewlines()
+	}
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	var err error
+	node.Body, err = p.list()
+	if err != nil {
+		return nil, err
+	}
+	return node, p.expect("done")
+}
+
+func (p *parser) caseCommand() (Node, error) {
+	p.pos++
+	t := p.peek()
+	if t == nil || t.Type != TWord {
+		return nil, p.unexpected(t)
+	}
+	node := &CaseNode{
+		Word: p.text(p.pos, p.pos),
+	}
+	p.pos++
+	p.skipNewlines()
+	if err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	for {
+		p.skipNewlines()
+		t = p.peek()
+		if isKeyword(t, "esac") {
+			p.pos++
+			return node, nil
+		}
+		if isOp(t, "(") {
+			p.pos++
+		}
+		var item CaseItem
+		for {
+			t = p.peek()
+			if t == nil || t.Type != TWord {
+				return nil, p.unexpected(t)
+			}
+			item.Patterns = append(item.Patterns, p.text(p.pos, p.pos))
+			p.pos++
+
+			t = p.peek()
+			if isOp(t, ")") {
+				p.pos++
+				break
+			}
+			if !isOp(t, "|") {
+				return nil, p.unexpected(t)
+			}
+			p.pos++
+		}
+		var err error
+		item.Body, err = p.list()
+		if err != nil {
+			return nil, err
+		}
+		node.Items = append(node.Items, item)
+
+		t = p.peek()
+		if isOp(t, ";;") {
+			p.pos++
+		} else if !isKeyword(t, "esac") {
+			return nil, p.unexpected(t)
+		}
+	}
+}
+
+func (p *parser) group() (Node, error) {
+	p.pos++
+	body, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	return &GroupNode{
+		Body: body,
+	}, p.expect("}")
+}
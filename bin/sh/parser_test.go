@@ -0,0 +1,119 @@
+//
+// parser_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseList(t *testing.T) {
+	list, err := Parse("a $x | b >out; c && d || e\nf &")
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if len(list.Items) != 3 {
+		t.Fatalf("got %d items, expected 3", len(list.Items))
+	}
+	if list.Items[0].Pipelines[0].Text != "a $x | b >out" {
+		t.Errorf("unexpected pipeline %q", list.Items[0].Pipelines[0].Text)
+	}
+	if !reflect.DeepEqual(list.Items[1].Ops, []string{"&&", "||"}) {
+		t.Errorf("unexpected ops %v", list.Items[1].Ops)
+	}
+	if !list.Items[2].Background || list.Items[1].Background {
+		t.Errorf("unexpected background flags")
+	}
+}
+
+func TestParseCompound(t *testing.T) {
+	list, err := Parse(`
+if test -f x; then echo if
+elif false; then
+  echo elif
+else echo done; fi
+while true; do break; done
+for i in a "$b c"; do echo $i; done
+case $x in
+  a|b) echo ab;;
+  (*) echo other
+esac
+f() {
+  echo "$@"
+}
+`)
+	if err != nil {
+		t.Fatalf("Parse failed: %s", err)
+	}
+	if len(list.Items) != 5 {
+		t.Fatalf("got %d items, expected 5", len(list.Items))
+	}
+	ifNode, ok := list.Items[0].Pipelines[0].Compound.(*IfNode)
+	if !ok || len(ifNode.Conds) != 2 || ifNode.Else == nil {
+		t.Errorf("unexpected if: %#v", list.Items[0].Pipelines[0].Compound)
+	} else if ifNode.Else.Items[0].Pipelines[0].Text != "echo done" {
+		t.Errorf("unexpected else: %q", ifNode.Else.Items[0].Pipelines[0].Text)
+	}
+	if _, ok := list.Items[1].Pipelines[0].Compound.(*LoopNode); !ok {
+		t.Errorf("expected loop")
+	}
+	forNode, ok := list.Items[2].Pipelines[0].Compound.(*ForNode)
+	if !ok || forNode.Name != "i" || forNode.Words != `a "$b c"` {
+		t.Errorf("unexpected for: %#v", list.Items[2].Pipelines[0].Compound)
+	}
+	caseNode, ok := list.Items[3].Pipelines[0].Compound.(*CaseNode)
+	if !ok || caseNode.Word != "$x" || len(caseNode.Items) != 2 ||
+		!reflect.DeepEqual(caseNode.Items[0].Patterns, []string{"a", "b"}) {
+		t.Errorf("unexpected case: %#v", list.Items[3].Pipelines[0].Compound)
+	}
+	fn, ok := list.Items[4].Pipelines[0].Compound.(*FuncNode)
+	if !ok || fn.Name != "f" {
+		t.Errorf("unexpected function: %#v",
+			list.Items[4].Pipelines[0].Compound)
+	}
+}
+
+var parseIncomplete = []string{
+	"if true; then",
+	"while true",
+	"for i in a b; do echo",
+	"case x in",
+	"f() {",
+	"a &&",
+	"a |",
+	"echo 'a",
+}
+
+func TestParseIncomplete(t *testing.T) {
+	for _, input := range parseIncomplete {
+		_, err := Parse(input)
+		if err != ErrIncomplete {
+			t.Errorf("Parse(%q): got %v, expected ErrIncomplete", input, err)
+		}
+	}
+}
+
+var parseErrors = []string{
+	"fi",
+	"a; ; b",
+	"if true; fi",
+	"for 1 in a; do b; done",
+	"while a; do b; done | c",
+	"a >",
+	"a && b &",
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range parseErrors {
+		_, err := Parse(input)
+		if err == nil || err == ErrIncomplete {
+			t.Errorf("Parse(%q): got %v, expected syntax error", input, err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+//
+// redirect.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+)
+
+// Stdio defines the standard input, output, and error files of a
+// command.
+type Stdio struct {
+	Stdin  *bbos.File
+	Stdout *bbos.File
+	Stderr *bbos.File
+	files  []*bbos.File
+}
+
+var (
+	stdin  = bbos.NewFile(int(os.Stdin.Fd()), os.Stdin.Name())
+	stdout = bbos.NewFile(int(os.Stdout.Fd()), os.Stdout.Name())
+	stderr = bbos.NewFile(int(os.Stderr.Fd()), os.Stderr.Name())
+)
+
+// NewStdio creates a new Stdio with the shell's standard files.
+func NewStdio() *Stdio {
+	return &Stdio{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	}
+}
+
+// FDs returns the file descriptors of the standard files.
+func (stdio *Stdio) FDs() []int {
+	return []int{
+		int(stdio.Stdin.Fd()),
+		int(stdio.Stdout.Fd()),
+		int(stdio.Stderr.Fd()),
+	}
+}
+
+// Own adds the file to the set of files which are closed when the
+// Stdio is closed.
+func (stdio *Stdio) Own(f *bbos.File) *bbos.File {
+	stdio.files = append(stdio.files, f)
+	return f
+}
+
+// Close closes all files opened for the Stdio.
+func (stdio *Stdio) Close() {
+	for _, f := range stdio.files {
+		f.Close()
+	}
+	stdio.files = nil
+}
+
+func (stdio *Stdio) file(fd int) (*bbos.File, error) {
+	switch fd {
+	case 0:
+		return stdio.Stdin, nil
+	case 1:
+		return stdio.Stdout, nil
+	case 2:
+		return stdio.Stderr, nil
+	default:
+		return nil, fmt.Errorf("%d: bad file descriptor", fd)
+	}
+}
+
+func (stdio *Stdio) setFile(fd int, f *bbos.File) {
+	switch fd {
+	case 0:
+		stdio.Stdin = f
+	case 1:
+		stdio.Stdout = f
+	case 2:
+		stdio.Stderr = f
+	}
+}
+
+// Redirect defines an I/O redirection of a command.
+type Redirect struct {
+	FD     int
+	Op     string
+	Target string
+	DupFD  int
+}
+
+func (r Redirect) String() string {
+	if r.Op == ">&" {
+		return fmt.Sprintf("%d>&%d", r.FD, r.DupFD)
+	}
+	return fmt.Sprintf("%d%s%s", r.FD, r.Op, r.Target)
+}
+
+// newRedirect creates a redirection for the redirection operator
+// token op. The operator has an optional file descriptor number
+// prefix. The function returns false if op is not a redirection
+// operator.
+func newRedirect(op string) (*Redirect, bool) {
+	r := &Redirect{
+		FD: -1,
+	}
+	if len(op) > 0 && op[0] >= '0' && op[0] <= '9' {
+		r.FD = int(op[0] - '0')
+		op = op[1:]
+	}
+	switch op {
+	case "<":
+		r.Op = op
+		if r.FD < 0 {
+			r.FD = 0
+		}
+
+	case ">", ">>":
+		r.Op = op
+		if r.FD < 0 {
+			r.FD = 1
+		}
+
+	default:
+		if len(op) != 3 || !strings.HasPrefix(op, ">&") {
+			return nil, false
+		}
+		r.Op = ">&"
+		r.DupFD = int(op[2] - '0')
+		if r.FD < 0 {
+			r.FD = 1
+		}
+	}
+	return r, true
+}
+
+// Redirect applies the redirections to the Stdio. The files opened
+// for the redirections are closed when the Stdio is closed.
+func (stdio *Stdio) Redirect(redirects []Redirect) error {
+	for _, r := range redirects {
+		var flag int
+		var perm os.FileMode
+
+		switch r.Op {
+		case "<":
+			flag = bbos.O_RDONLY
+
+		case ">":
+			flag = bbos.O_WRONLY | bbos.O_CREAT | bbos.O_TRUNC
+			perm = 0644
+
+		case ">>":
+			flag = bbos.O_WRONLY | bbos.O_CREAT | bbos.O_APPEND
+			perm = 0644
+
+		case ">&":
+			f, err := stdio.file(r.DupFD)
+			if err != nil {
+				return err
+			}
+			stdio.setFile(r.FD, f)
+			continue
+
+		default:
+			return fmt.Errorf("unsupported redirection %s", r)
+		}
+		fd, err := bbos.Open(r.Target, flag, perm)
+		if err != nil {
+			return fmt.Errorf("%s: %s", r.Target, err)
+		}
+		stdio.setFile(r.FD, stdio.Own(bbos.NewFile(fd, r.Target)))
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+//
+// vars.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// The shell variables are either local to the shell or exported. The
+// exported variables are kept in the process environment which is
+// passed to the child processes. The variables inherited from the
+// environment are exported.
+var (
+	locals   = make(map[string]string)
+	exported = make(map[string]bool)
+)
+
+// getVar returns the value of the shell variable name and tests if
+// the variable is set.
+func getVar(name string) (string, bool) {
+	value, ok := locals[name]
+	if ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+// setVar sets the value of the shell variable name. If the variable
+// is exported, the value is also set in the environment.
+func setVar(name, value string) error {
+	_, ok := os.LookupEnv(name)
+	if ok || exported[name] {
+		delete(locals, name)
+		return os.Setenv(name, value)
+	}
+	locals[name] = value
+	return nil
+}
+
+// exportVar exports the shell variable name. If the variable is not
+// set, it is exported when it is assigned.
+func exportVar(name string) error {
+	value, ok := locals[name]
+	if !ok {
+		exported[name] = true
+		return nil
+	}
+	delete(locals, name)
+	delete(exported, name)
+	return os.Setenv(name, value)
+}
+
+// unsetVar removes the shell variable name from the shell and from
+// the environment.
+func unsetVar(name string) error {
+	delete(locals, name)
+	delete(exported, name)
+	return os.Unsetenv(name)
+}
+
+// varNames returns the sorted names of the shell variables.
+func varNames() []string {
+	var result []string
+	for name := range locals {
+		result = append(result, name)
+	}
+	for _, env := range os.Environ() {
+		idx := strings.IndexByte(env, '=')
+		if idx > 0 {
+			result = append(result, env[:idx])
+		}
+	}
+	sort.Strings(result)
+	return result
+}
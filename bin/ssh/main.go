@@ -48,7 +48,11 @@ func main() {
 	port := matches[4]
 
 	if len(user) == 0 {
-		user = "mtr"
+		user = os.Getenv("USER")
+	}
+	if len(user) == 0 {
+		fmt.Fprintf(os.Stderr, "No user specified\n")
+		return
 	}
 
 	if len(port) == 0 {
@@ -99,11 +103,22 @@ func sshConnection(user, addr string) error {
 	if err != nil {
 		return err
 	}
-	err = session.Setenv("LANG", "en_US.UTF-8")
+	lang := os.Getenv("LANG")
+	if len(lang) > 0 {
+		err = session.Setenv("LANG", lang)
+		if err != nil {
+			return err
+		}
+	}
+	size, err := bbos.GetWinSize(int(os.Stdin.Fd()))
 	if err != nil {
-		return err
+		size = bbos.WinSize{
+			Rows: 24,
+			Cols: 80,
+		}
 	}
-	err = session.RequestPty("xterm", 24, 80, ssh.TerminalModes{})
+	err = session.RequestPty("xterm", size.Rows, size.Cols,
+		ssh.TerminalModes{})
 	if err != nil {
 		return err
 	}
@@ -127,11 +142,41 @@ func sshConnection(user, addr string) error {
 	}
 
 	// Enable raw mode for input.
-	flags, err := readline.MakeRaw(os.Stdin)
+	termios, err := readline.MakeRaw(os.Stdin)
 	if err != nil {
 		return err
 	}
-	defer readline.MakeCooked(os.Stdin, flags)
+	defer readline.MakeCooked(os.Stdin, termios)
+
+	// Forward interrupts to the remote terminal.
+	interrupts := make(chan os.Signal, 1)
+	err = bbos.Notify(interrupts, bbos.SIGINT)
+	if err != nil {
+		return err
+	}
+	defer bbos.Reset(bbos.SIGINT)
+	go func() {
+		for range interrupts {
+			stdin.Write([]byte{0x03})
+		}
+	}()
+
+	// Send the terminal size changes to the remote terminal.
+	resizes := make(chan os.Signal, 1)
+	err = bbos.Notify(resizes, bbos.SIGWINCH)
+	if err != nil {
+		return err
+	}
+	defer bbos.Reset(bbos.SIGWINCH)
+	go func() {
+		for range resizes {
+			size, err := bbos.GetWinSize(int(os.Stdin.Fd()))
+			if err != nil {
+				continue
+			}
+			session.WindowChange(size.Rows, size.Cols)
+		}
+	}()
 
 	go io.Copy(stdin, os.Stdin)
 	go io.Copy(os.Stderr, stderr)
@@ -20,6 +20,8 @@ var (
 	FSRoot      string = fmt.Sprintf("http://%s/fs", WSProxy)
 	FSZone      string = "default"
 	ShellPrompt string = "bbos \\W $ "
+	User        string = "mtr"
+	UID         int    = 1000
 )
 
 type ValueType int
@@ -55,6 +57,20 @@ func (v *Value) Set(value string) error {
 	}
 }
 
+// Get returns the value as a string.
+func (v *Value) Get() string {
+	switch v.Type {
+	case String:
+		return *v.Strp
+
+	case Int:
+		return strconv.Itoa(*v.Intp)
+
+	default:
+		return "?"
+	}
+}
+
 func (v *Value) String() string {
 	switch v.Type {
 	case String:
@@ -99,6 +115,16 @@ var Values = []*Value{
 		Type: String,
 		Strp: &ShellPrompt,
 	},
+	&Value{
+		Name: "user",
+		Type: String,
+		Strp: &User,
+	},
+	&Value{
+		Name: "user.uid",
+		Type: Int,
+		Intp: &UID,
+	},
 }
 
 func Var(name string) (*Value, error) {
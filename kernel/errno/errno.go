@@ -11,8 +11,39 @@ import (
 )
 
 var (
-	ENOENT = errors.New("ENOENT")
-	EINVAL = errors.New("EINVAL")
-	ENOSYS = errors.New("ENOSYS")
-	EBADF  = errors.New("EBADF")
+	ENOENT    = errors.New("ENOENT")
+	EINVAL    = errors.New("EINVAL")
+	ENOSYS    = errors.New("ENOSYS")
+	EBADF     = errors.New("EBADF")
+	EEXIST    = errors.New("EEXIST")
+	ENOTDIR   = errors.New("ENOTDIR")
+	EISDIR    = errors.New("EISDIR")
+	ENOTEMPTY = errors.New("ENOTEMPTY")
+	EROFS     = errors.New("EROFS")
+	EBUSY     = errors.New("EBUSY")
+	EPIPE     = errors.New("EPIPE")
+	ESRCH     = errors.New("ESRCH")
+	ENOTTY    = errors.New("ENOTTY")
+	EIO       = errors.New("EIO")
+	ECHILD    = errors.New("ECHILD")
+	EXDEV     = errors.New("EXDEV")
+	ESPIPE    = errors.New("ESPIPE")
+	EPERM     = errors.New("EPERM")
 )
+
+var errnos = []error{
+	ENOENT, EINVAL, ENOSYS, EBADF, EEXIST, ENOTDIR, EISDIR, ENOTEMPTY,
+	EROFS, EBUSY, EPIPE, ESRCH, ENOTTY, EIO,
+	ECHILD, EXDEV, ESPIPE, EPERM,
+}
+
+// From returns the errno value wrapped by err. If err does not wrap
+// any errno value, From returns EINVAL.
+func From(err error) error {
+	for _, e := range errnos {
+		if errors.Is(err, e) {
+			return e
+		}
+	}
+	return EINVAL
+}
@@ -0,0 +1,324 @@
+//
+// devfs.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+// Package devfs implements the device filesystem.
+package devfs
+
+import (
+	"crypto/rand"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+	"github.com/markkurossi/blackbox-os/kernel/fs"
+	"github.com/markkurossi/blackbox-os/kernel/kmsg"
+	"github.com/markkurossi/blackbox-os/kernel/tty"
+)
+
+var (
+	_ fs.VFS  = &FS{}
+	_ fs.File = &ttyFile{}
+	_ tty.TTY = &ttyFile{}
+)
+
+const (
+	modeChar = os.ModeDevice | os.ModeCharDevice
+)
+
+type device struct {
+	mode os.FileMode
+	open func() io.ReadWriter
+}
+
+// FS implements the device filesystem. The filesystem has a fixed
+// set of character devices.
+type FS struct {
+	created time.Time
+	devices map[string]device
+}
+
+// New creates a new device filesystem. The console specifies the
+// controlling terminal for the tty and console devices.
+func New(console tty.TTY) *FS {
+	return &FS{
+		created: time.Now(),
+		devices: map[string]device{
+			"console": {
+				mode: modeChar | 0600,
+				open: func() io.ReadWriter {
+					return console
+				},
+			},
+			"kmsg": {
+				mode: modeChar | 0644,
+				open: func() io.ReadWriter {
+					return &kmsgDevice{
+						Reader: kmsg.NewReader(),
+					}
+				},
+			},
+			"null": {
+				mode: modeChar | 0666,
+				open: func() io.ReadWriter {
+					return nullDevice{}
+				},
+			},
+			"random": {
+				mode: modeChar | 0666,
+				open: func() io.ReadWriter {
+					return randomDevice{}
+				},
+			},
+			"tty": {
+				mode: modeChar | 0666,
+				open: func() io.ReadWriter {
+					return console
+				},
+			},
+			"urandom": {
+				mode: modeChar | 0666,
+				open: func() io.ReadWriter {
+					return randomDevice{}
+				},
+			},
+			"zero": {
+				mode: modeChar | 0666,
+				open: func() io.ReadWriter {
+					return zeroDevice{}
+				},
+			},
+		},
+	}
+}
+
+// lookup finds the named device. The function returns nil for the
+// root directory.
+func (dfs *FS) lookup(name string) (*device, string, error) {
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return nil, name, nil
+	}
+	dev, ok := dfs.devices[name[1:]]
+	if !ok {
+		return nil, "", errno.ENOENT
+	}
+	return &dev, name[1:], nil
+}
+
+func (dfs *FS) info(dev *device, name string) os.FileInfo {
+	var info *fs.FileInfo
+	if dev == nil {
+		info = fs.NewFileInfo(name, 0, os.ModeDir|0755, dfs.created, nil)
+	} else {
+		info = fs.NewFileInfo(name, 0, dev.mode, dfs.created, nil)
+	}
+	info.SetInode(fs.Inode("dev:"+name), 0)
+	return info
+}
+
+// Type implements the fs.VFS.Type().
+func (dfs *FS) Type() string {
+	return "devfs"
+}
+
+// Stat implements the fs.VFS.Stat().
+func (dfs *FS) Stat(name string) (os.FileInfo, error) {
+	dev, name, err := dfs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return dfs.info(dev, name), nil
+}
+
+// Open implements the fs.VFS.Open(). The O_CREAT and O_TRUNC flags
+// are ignored for the existing devices.
+func (dfs *FS) Open(name string, flag int, perm os.FileMode) (fs.File,
+	error) {
+
+	dev, name, err := dfs.lookup(name)
+	if err != nil {
+		if err == errno.ENOENT && flag&fs.O_CREAT != 0 {
+			return nil, errno.EROFS
+		}
+		return nil, err
+	}
+	if dev == nil {
+		if flag&fs.O_ACCMODE != fs.O_RDONLY {
+			return nil, errno.EISDIR
+		}
+		return &dirFile{
+			info: dfs.info(dev, name),
+		}, nil
+	}
+	info := dfs.info(dev, name)
+	native := dev.open()
+	if t, ok := native.(tty.TTY); ok {
+		return &ttyFile{
+			TTY:  t,
+			info: info,
+		}, nil
+	}
+	return &devFile{
+		ReadWriter: native,
+		info:       info,
+	}, nil
+}
+
+// ReadDir implements the fs.VFS.ReadDir().
+func (dfs *FS) ReadDir(name string) ([]os.FileInfo, error) {
+	dev, _, err := dfs.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if dev != nil {
+		return nil, errno.ENOTDIR
+	}
+	var result []os.FileInfo
+	for name, dev := range dfs.devices {
+		d := dev
+		result = append(result, dfs.info(&d, name))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result, nil
+}
+
+// Mkdir implements the fs.VFS.Mkdir().
+func (dfs *FS) Mkdir(name string, perm os.FileMode) error {
+	return errno.EROFS
+}
+
+// Unlink implements the fs.VFS.Unlink().
+func (dfs *FS) Unlink(name string) error {
+	return errno.EROFS
+}
+
+// Rmdir implements the fs.VFS.Rmdir().
+func (dfs *FS) Rmdir(name string) error {
+	return errno.EROFS
+}
+
+// Rename implements the fs.VFS.Rename().
+func (dfs *FS) Rename(from, to string) error {
+	return errno.EROFS
+}
+
+// Truncate implements the fs.VFS.Truncate().
+func (dfs *FS) Truncate(name string, size int64) error {
+	return errno.EINVAL
+}
+
+type dirFile struct {
+	info os.FileInfo
+}
+
+func (f *dirFile) Read(p []byte) (int, error) {
+	return 0, errno.EISDIR
+}
+
+func (f *dirFile) Write(p []byte) (int, error) {
+	return 0, errno.EBADF
+}
+
+func (f *dirFile) Close() error {
+	return nil
+}
+
+func (f *dirFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// devFile implements an open device file.
+type devFile struct {
+	io.ReadWriter
+	info os.FileInfo
+}
+
+func (f *devFile) Close() error {
+	if closer, ok := f.ReadWriter.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (f *devFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// Seek implements the io.Seeker interface. The device files do not
+// have a position and the seek always returns 0.
+func (f *devFile) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
+
+// ttyFile implements an open terminal device. The file implements the
+// tty.TTY interface so the terminal operations apply to it.
+type ttyFile struct {
+	tty.TTY
+	info os.FileInfo
+}
+
+func (f *ttyFile) Close() error {
+	return nil
+}
+
+func (f *ttyFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+// nullDevice discards all writes and returns EOF for reads.
+type nullDevice struct{}
+
+func (d nullDevice) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (d nullDevice) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// zeroDevice discards all writes and returns zero bytes for reads.
+type zeroDevice struct{}
+
+func (d zeroDevice) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func (d zeroDevice) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// randomDevice returns random bytes for reads. On js/wasm, the
+// crypto/rand reader is backed by crypto.getRandomValues.
+type randomDevice struct{}
+
+func (d randomDevice) Read(p []byte) (int, error) {
+	return rand.Read(p)
+}
+
+func (d randomDevice) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+// kmsgDevice streams the kernel messages. The writes are logged as
+// kernel messages.
+type kmsgDevice struct {
+	*kmsg.Reader
+}
+
+func (d *kmsgDevice) Write(p []byte) (int, error) {
+	kmsg.Print(string(p))
+	return len(p), nil
+}
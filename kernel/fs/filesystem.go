@@ -9,12 +9,17 @@
 package fs
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/markkurossi/backup/lib/tree"
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+	"github.com/markkurossi/blackbox-os/lib/file"
 )
 
 type FileInfo struct {
@@ -22,7 +27,22 @@ type FileInfo struct {
 	size    int64
 	mode    os.FileMode
 	modTime time.Time
-	element tree.Element
+	ino     uint64
+	nlink   int
+	sys     interface{}
+}
+
+// NewFileInfo creates file information for the named file.
+func NewFileInfo(name string, size int64, mode os.FileMode,
+	modTime time.Time, sys interface{}) *FileInfo {
+
+	return &FileInfo{
+		name:    name,
+		size:    size,
+		mode:    mode,
+		modTime: modTime,
+		sys:     sys,
+	}
 }
 
 func (info *FileInfo) Name() string {
@@ -46,50 +66,95 @@ func (info *FileInfo) IsDir() bool {
 }
 
 func (info *FileInfo) Sys() interface{} {
-	return info.element
+	return info.sys
+}
+
+// Ino returns the inode number of the file.
+func (info *FileInfo) Ino() uint64 {
+	return info.ino
+}
+
+// Nlink returns the number of hard links to the file. If the link
+// count is not set, directories have 2 and other files 1 links.
+func (info *FileInfo) Nlink() int {
+	if info.nlink > 0 {
+		return info.nlink
+	}
+	if info.IsDir() {
+		return 2
+	}
+	return 1
+}
+
+// SetInode sets the inode number and link count of the file.
+func (info *FileInfo) SetInode(ino uint64, nlink int) {
+	info.ino = ino
+	info.nlink = nlink
 }
 
-func Stat(fs *FS, name string) (os.FileInfo, error) {
-	path, err := fs.ResolvePath(name)
+// Type implements the VFS.Type().
+func (v *Volume) Type() string {
+	return "zone"
+}
+
+// Stat implements the VFS.Stat().
+func (v *Volume) Stat(name string) (os.FileInfo, error) {
+	path, err := v.ResolvePath(name)
 	if err != nil {
 		return nil, err
 	}
-	element, err := tree.DeserializeID(path[len(path)-1].ID, fs.Zone())
+	last := path[len(path)-1]
+	element, err := v.store.Element(last.ID)
 	if err != nil {
 		return nil, err
 	}
+	return elementInfo(last, element)
+}
+
+// elementInfo returns the file information of the path element.
+func elementInfo(e PathElement, element tree.Element) (*FileInfo, error) {
+	info := &FileInfo{
+		name:    e.Name,
+		mode:    e.Mode,
+		modTime: time.Unix(0, e.ModTime),
+		ino:     Inode(e.ID.String()),
+		sys:     element,
+	}
 	switch el := element.(type) {
 	case *tree.Directory:
-		return &FileInfo{
-			name:    path[len(path)-1].Name,
-			mode:    os.ModeDir,
-			element: element,
-		}, nil
+		info.mode |= os.ModeDir
+		info.nlink = 2
+		for _, entry := range el.Entries {
+			if entry.Mode.IsDir() {
+				info.nlink++
+			}
+		}
 
 	case tree.File:
-		return &FileInfo{
-			name:    path[len(path)-1].Name,
-			size:    el.Size(),
-			element: element,
-		}, nil
+		info.mode &^= os.ModeDir
+		info.size = el.Size()
+		info.nlink = 1
 
 	default:
 		return nil, fmt.Errorf("Invalid element %T", element)
 	}
+	return info, nil
 }
 
-func ReadDir(fs *FS, dirname string) ([]os.FileInfo, error) {
-	info, err := Stat(fs, dirname)
+// ReadDir implements the VFS.ReadDir().
+func (v *Volume) ReadDir(dirname string) ([]os.FileInfo, error) {
+	info, err := v.Stat(dirname)
 	if err != nil {
 		return nil, err
 	}
 	dir, ok := info.Sys().(*tree.Directory)
 	if !ok {
-		return nil, fmt.Errorf("File '%s' is not a directory", dirname)
+		return nil, fmt.Errorf("File '%s' is not a directory: %w", dirname,
+			errno.ENOTDIR)
 	}
 
 	// XXX resolve path twice: here and Stat above
-	path, err := fs.ResolvePath(dirname)
+	path, err := v.ResolvePath(dirname)
 	if err != nil {
 		return nil, err
 	}
@@ -97,50 +162,544 @@ func ReadDir(fs *FS, dirname string) ([]os.FileInfo, error) {
 
 	var result []os.FileInfo
 	for _, entry := range dir.Entries {
-		i, err := Stat(fs, fmt.Sprintf("%s/%s", dirName, entry.Name))
+		i, err := v.Stat(fmt.Sprintf("%s/%s", dirName,
+			file.PathEscape(entry.Name)))
 		if err != nil {
 			return nil, err
 		}
-		ii, ok := i.(*FileInfo)
-		if ok {
-			ii.modTime = time.Unix(0, entry.ModTime)
-		}
 		result = append(result, i)
 	}
 
 	return result, nil
 }
 
-type File struct {
+// VolumeFile implements an open file of a volume. The data of a
+// writable file is loaded on the first modification and kept in
+// memory. The modifications are written into the volume when the file
+// is closed, synced, or truncated.
+type VolumeFile struct {
 	Handle tree.Element
+	volume *Volume
+	name   string
+	flag   int
+	mode   os.FileMode
+	info   *FileInfo
+	data   []byte
+	dirty  bool
+	mutex  sync.Mutex
+	offset int64
+	reader io.Reader
+	pos    int64
 }
 
-func (f *File) Reader() io.Reader {
-	switch native := f.Handle.(type) {
-	case tree.File:
-		return native.Reader()
+// Name returns the absolute name of the file in the volume.
+func (f *VolumeFile) Name() string {
+	return f.name
+}
+
+// Writable tests if the file was opened for writing.
+func (f *VolumeFile) Writable() bool {
+	return f.flag&O_ACCMODE != O_RDONLY
+}
 
+// Size returns the file size.
+func (f *VolumeFile) Size() int64 {
+	if f.data != nil {
+		return int64(len(f.data))
+	}
+	file, ok := f.Handle.(tree.File)
+	if ok {
+		return file.Size()
+	}
+	return 0
+}
+
+// Read implements the io.Reader interface.
+func (f *VolumeFile) Read(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (f *VolumeFile) ReadAt(p []byte, off int64) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	var read int
+	for read < len(p) {
+		n, err := f.readAt(p[read:], off+int64(read))
+		read += n
+		if err != nil {
+			return read, err
+		}
+	}
+	return read, nil
+}
+
+func (f *VolumeFile) readAt(p []byte, off int64) (int, error) {
+	if f.flag&O_ACCMODE == O_WRONLY {
+		return 0, errno.EBADF
+	}
+	if off < 0 {
+		return 0, errno.EINVAL
+	}
+	if f.data != nil {
+		if off >= int64(len(f.data)) {
+			return 0, io.EOF
+		}
+		return copy(p, f.data[off:]), nil
+	}
+	err := f.seekReader(off)
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.reader.Read(p)
+	f.pos += int64(n)
+	return n, err
+}
+
+// seekReader positions the file reader to the offset off. If the
+// reader implements io.Seeker, it can jump directly to the chunk
+// containing the offset. Otherwise the reader is restarted when
+// seeking backwards and the data before the offset is skipped.
+func (f *VolumeFile) seekReader(off int64) error {
+	file, ok := f.Handle.(tree.File)
+	if !ok {
+		return io.EOF
+	}
+	if f.reader == nil {
+		f.reader = file.Reader()
+		f.pos = 0
+	}
+	if off == f.pos {
+		return nil
+	}
+	if seeker, ok := f.reader.(io.Seeker); ok {
+		pos, err := seeker.Seek(off, io.SeekStart)
+		if err != nil {
+			return err
+		}
+		f.pos = pos
+		return nil
+	}
+	if off < f.pos {
+		f.reader = file.Reader()
+		f.pos = 0
+	}
+	n, err := io.CopyN(ioutil.Discard, f.reader, off-f.pos)
+	f.pos += n
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// Write implements the io.Writer interface.
+func (f *VolumeFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.flag&O_APPEND != 0 {
+		err := f.load()
+		if err != nil {
+			return 0, err
+		}
+		f.offset = int64(len(f.data))
+	}
+	n, err := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// WriteAt implements the io.WriterAt interface.
+func (f *VolumeFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.writeAt(p, off)
+}
+
+func (f *VolumeFile) writeAt(p []byte, off int64) (int, error) {
+	if !f.Writable() {
+		return 0, errno.EBADF
+	}
+	if off < 0 {
+		return 0, errno.EINVAL
+	}
+	err := f.load()
+	if err != nil {
+		return 0, err
+	}
+	end := int(off) + len(p)
+	if end > len(f.data) {
+		f.data = append(f.data, make([]byte, end-len(f.data))...)
+	}
+	copy(f.data[off:], p)
+	f.dirty = true
+	f.info.modTime = time.Now()
+
+	return len(p), nil
+}
+
+// load reads the file data into memory for modifications.
+func (f *VolumeFile) load() error {
+	if f.data != nil {
+		return nil
+	}
+	file, ok := f.Handle.(tree.File)
+	if !ok {
+		return errno.EISDIR
+	}
+	data, err := io.ReadAll(file.Reader())
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	f.data = data
+	return nil
+}
+
+// Seek implements the io.Seeker interface.
+func (f *VolumeFile) Seek(offset int64, whence int) (int64, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.Size()
 	default:
-		fmt.Fprintf(os.Stderr, "read: %T", native)
-		return f
+		return 0, errno.EINVAL
+	}
+	if offset < 0 {
+		return 0, errno.EINVAL
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Truncate changes the size of the file.
+func (f *VolumeFile) Truncate(size int64) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.Writable() {
+		return errno.EINVAL
+	}
+	if size < 0 {
+		return errno.EINVAL
+	}
+	if size == 0 {
+		f.data = []byte{}
+	} else {
+		err := f.load()
+		if err != nil {
+			return err
+		}
 	}
+	if int(size) < len(f.data) {
+		f.data = f.data[:size]
+	} else {
+		f.data = append(f.data, make([]byte, int(size)-len(f.data))...)
+	}
+	return f.commit()
+}
+
+// Sync writes the modified file data into the volume.
+func (f *VolumeFile) Sync() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if !f.dirty {
+		return nil
+	}
+	return f.commit()
+}
+
+// Close implements the io.Closer interface. Close writes the modified
+// file data into the volume.
+func (f *VolumeFile) Close() error {
+	return f.Sync()
+}
+
+// commit writes the file data into the volume. If the file was
+// removed after it was opened, the data is not written.
+func (f *VolumeFile) commit() error {
+	err := f.volume.modify(f.name,
+		func(dir *tree.Directory, base string) error {
+			idx := findEntry(dir, base)
+			if idx < 0 {
+				return nil
+			}
+			f.info.modTime = time.Now()
+			return f.volume.storeFile(dir, base, f.mode, f.data)
+		})
+	if err != nil {
+		return err
+	}
+	f.dirty = false
+	return nil
 }
 
-func (f *File) Read(p []byte) (int, error) {
-	return 0, io.EOF
+// Stat implements the File.Stat().
+func (f *VolumeFile) Stat() (os.FileInfo, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	info := *f.info
+	if !info.IsDir() {
+		info.size = f.Size()
+	}
+	return &info, nil
+}
+
+// Open implements the VFS.Open().
+func (v *Volume) Open(name string, flag int, perm os.FileMode) (File, error) {
+	path, err := v.ResolvePath(name)
+	if err != nil {
+		if !errors.Is(err, errno.ENOENT) || flag&O_CREAT == 0 {
+			return nil, err
+		}
+		return v.create(name, flag, perm)
+	}
+	if flag&(O_CREAT|O_EXCL) == O_CREAT|O_EXCL {
+		return nil, errno.EEXIST
+	}
+
+	last := path[len(path)-1]
+	element, err := v.store.Element(last.ID)
+	if err != nil {
+		return nil, err
+	}
+	info, err := elementInfo(last, element)
+	if err != nil {
+		return nil, err
+	}
+	f := &VolumeFile{
+		Handle: element,
+		volume: v,
+		name:   path.String(),
+		flag:   flag,
+		mode:   info.mode.Perm(),
+		info:   info,
+	}
+	if !f.Writable() {
+		return f, nil
+	}
+	if path.ReadOnly() {
+		return nil, errno.EROFS
+	}
+
+	file, ok := element.(tree.File)
+	if !ok {
+		return nil, errno.EISDIR
+	}
+	if flag&O_TRUNC != 0 && file.Size() > 0 {
+		f.data = []byte{}
+		err = f.commit()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
 }
 
-func Open(fs *FS, name string) (*File, error) {
-	path, err := fs.ResolvePath(name)
+// create creates a new empty file and opens it.
+func (v *Volume) create(name string, flag int, perm os.FileMode) (
+	*VolumeFile, error) {
+
+	var abs string
+
+	err := v.modify(name, func(dir *tree.Directory, base string) error {
+		if findEntry(dir, base) >= 0 {
+			return errno.EEXIST
+		}
+		return v.storeFile(dir, base, perm.Perm(), []byte{})
+	})
 	if err != nil {
 		return nil, err
 	}
+	path, err := v.ResolvePath(name)
+	if err != nil {
+		return nil, err
+	}
+	abs = path.String()
 
-	element, err := tree.DeserializeID(path[len(path)-1].ID, fs.Zone())
+	last := path[len(path)-1]
+	element, err := v.store.Element(last.ID)
 	if err != nil {
 		return nil, err
 	}
-	return &File{
+	info, err := elementInfo(last, element)
+	if err != nil {
+		return nil, err
+	}
+	return &VolumeFile{
 		Handle: element,
+		volume: v,
+		name:   abs,
+		flag:   flag,
+		mode:   perm.Perm(),
+		info:   info,
+		data:   []byte{},
 	}, nil
 }
+
+// Mkdir implements the VFS.Mkdir().
+func (v *Volume) Mkdir(name string, perm os.FileMode) error {
+	return v.modify(name, func(dir *tree.Directory, base string) error {
+		if findEntry(dir, base) >= 0 {
+			return errno.EEXIST
+		}
+		el := &tree.Directory{}
+		id, err := v.store.Add(el)
+		if err != nil {
+			return err
+		}
+		setEntry(dir, tree.DirectoryEntry{
+			Name:    base,
+			Mode:    os.ModeDir | perm.Perm(),
+			ModTime: time.Now().UnixNano(),
+			Entry:   id,
+		})
+		return nil
+	})
+}
+
+// Unlink implements the VFS.Unlink().
+func (v *Volume) Unlink(name string) error {
+	return v.remove(name, false)
+}
+
+// Rmdir implements the VFS.Rmdir().
+func (v *Volume) Rmdir(name string) error {
+	return v.remove(name, true)
+}
+
+func (v *Volume) remove(name string, isDir bool) error {
+	return v.modify(name, func(dir *tree.Directory, base string) error {
+		idx := findEntry(dir, base)
+		if idx < 0 {
+			return errno.ENOENT
+		}
+		d, err := v.directory(dir.Entries[idx].Entry)
+		if isDir {
+			if err != nil {
+				return err
+			}
+			if len(d.Entries) > 0 {
+				return errno.ENOTEMPTY
+			}
+		} else if err == nil {
+			return errno.EISDIR
+		}
+		removeEntry(dir, idx)
+		return nil
+	})
+}
+
+// Rename implements the VFS.Rename(). The source is unlinked and the
+// target is inserted as one modification: if the insert fails, the
+// volume root is restored so the source is not lost.
+func (v *Volume) Rename(from, to string) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	var entry tree.DirectoryEntry
+
+	fromPath, err := v.resolvePath(from)
+	if err != nil {
+		return err
+	}
+
+	// Check the target before modifying the source directory.
+	fromNames := fromPath.Names()
+	var toNames []string
+	path, err := v.resolvePath(to)
+	if err == nil {
+		toNames = path.Names()
+		if equalNames(fromNames, toNames) {
+			// Renaming a file to itself does nothing.
+			return nil
+		}
+		_, err = v.directory(path[len(path)-1].ID)
+		if err == nil {
+			return errno.EISDIR
+		}
+	} else if errors.Is(err, errno.ENOENT) {
+		dirname, base, err := splitName(to)
+		if err != nil {
+			return err
+		}
+		path, err = v.resolvePath(dirname)
+		if err != nil {
+			return err
+		}
+		toNames = append(path.Names(), base)
+	} else {
+		return err
+	}
+	if len(toNames) > len(fromNames) && isPrefix(fromNames, toNames) {
+		// Can't move directory into itself.
+		return errno.EINVAL
+	}
+
+	root := v.root
+	mtime := v.mtime
+
+	err = v.modifyLocked(from, func(dir *tree.Directory, base string) error {
+		idx := findEntry(dir, base)
+		if idx < 0 {
+			return errno.ENOENT
+		}
+		entry = dir.Entries[idx]
+		removeEntry(dir, idx)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = v.modifyLocked(to, func(dir *tree.Directory, base string) error {
+		entry.Name = base
+		setEntry(dir, entry)
+		return nil
+	})
+	if err != nil {
+		v.root = root
+		v.mtime = mtime
+	}
+	return err
+}
+
+// isPrefix tests if the prefix names are a prefix of names.
+func isPrefix(prefix, names []string) bool {
+	if len(prefix) > len(names) {
+		return false
+	}
+	for idx, name := range prefix {
+		if names[idx] != name {
+			return false
+		}
+	}
+	return true
+}
+
+// Truncate implements the VFS.Truncate().
+func (v *Volume) Truncate(name string, size int64) error {
+	f, err := v.Open(name, O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	return f.(*VolumeFile).Truncate(size)
+}
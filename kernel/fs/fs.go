@@ -10,180 +10,200 @@ package fs
 
 import (
 	"fmt"
+	"os"
 
-	"github.com/markkurossi/backup/lib/crypto/zone"
-	"github.com/markkurossi/backup/lib/storage"
-	"github.com/markkurossi/backup/lib/tree"
-	"github.com/markkurossi/blackbox-os/lib/file"
+	"github.com/markkurossi/blackbox-os/kernel/errno"
 )
 
-func New(z *zone.Zone) (*FS, error) {
-	fs := &FS{
-		zone: z,
+// New creates a new filesystem view to the namespace. The view has
+// its own working directory but it shares the namespace's mounts with
+// all other views.
+func New(ns *Namespace) *FS {
+	return &FS{
+		ns: ns,
 	}
-	// Find snapshot root.
-	element, err := tree.DeserializeID(z.HeadID, z)
-	if err != nil {
-		// Empty filesystem.
-		return nil, err
-	}
-	el, ok := element.(*tree.Snapshot)
-	if !ok {
-		return nil, fmt.Errorf("Invalid filesystem root directory: %T", element)
-	}
-	fs.wd = append(fs.wd, PathElement{
-		ID:   el.Root,
-		Name: "",
-	})
-
-	return fs, nil
 }
 
 type FS struct {
-	zone *zone.Zone
-	wd   Path
-}
-
-func (fs *FS) Zone() *zone.Zone {
-	return fs.zone
+	ns *Namespace
+	wd []string
 }
 
-func (fs *FS) WDPath() Path {
-	return fs.wd
+// Namespace returns the view's namespace.
+func (fs *FS) Namespace() *Namespace {
+	return fs.ns
 }
 
-func (fs *FS) WD() (str string, id storage.ID, err error) {
-	str = fs.wd.String()
-
-	if len(fs.wd) > 0 {
-		id = fs.wd[len(fs.wd)-1].ID
-		return
+// Volume returns the volume mounted at the root directory.
+func (fs *FS) Volume() (*Volume, error) {
+	m, _ := fs.ns.Lookup(nil)
+	v, ok := m.FS.(*Volume)
+	if !ok {
+		return nil, errno.ENOSYS
 	}
+	return v, nil
+}
 
-	var element tree.Element
-
-	element, err = tree.DeserializeID(fs.zone.HeadID, fs.zone)
-	if err != nil {
-		return
-	}
+// Abs returns the absolute path of the file name.
+func (fs *FS) Abs(name string) string {
+	return namesPath(resolve(fs.wd, name))
+}
 
-	el, ok := element.(*tree.Snapshot)
-	if !ok {
-		err = fmt.Errorf("Invalid root directory: %T", element)
-		return
-	}
-	id = el.Root
-	return
+func (fs *FS) WD() string {
+	return namesPath(fs.wd)
 }
 
 func (fs *FS) SetWD(path string) error {
-	wd, err := fs.ResolvePath(path)
+	info, err := Stat(fs, path)
 	if err != nil {
 		return err
 	}
-	// Check that the last element is a directory.
-	element, err := tree.DeserializeID(wd[len(wd)-1].ID, fs.zone)
-	if err != nil {
-		return err
-	}
-	_, ok := element.(*tree.Directory)
-	if !ok {
-		return fmt.Errorf("File '%s' is not a directory", path)
+	if !info.IsDir() {
+		return fmt.Errorf("File '%s' is not a directory: %w", path,
+			errno.ENOTDIR)
 	}
-	fs.wd = wd
+	fs.wd = resolve(fs.wd, path)
 	return nil
 }
 
-func (fs *FS) ResolvePath(filename string) (Path, error) {
-	var parts []string
-
-	if len(filename) > 0 {
-		parts = file.PathSplit(filename)
-	}
+// Lookup finds the filesystem containing the file name. The function
+// returns the mount and the name of the file inside the mounted
+// filesystem.
+func (fs *FS) Lookup(name string) (*Mount, string) {
+	return fs.ns.Lookup(resolve(fs.wd, name))
+}
 
-	var path Path
-	if len(parts) == 0 || len(parts[0]) > 0 {
-		// Relative path starting from the current working directory.
-		path = fs.wd.Copy()
-	} else {
-		// Absolute path starting from the root.
-		path = fs.wd[:1].Copy()
-	}
+// Stat returns the file information of the named file.
+func Stat(fs *FS, name string) (os.FileInfo, error) {
+	m, path := fs.Lookup(name)
+	return m.FS.Stat(path)
+}
 
-	for _, part := range parts {
-		switch part {
-		case ".", "":
-			// Stay at the current directory.
+// ReadDir reads the named directory. The result contains also the
+// mount points in the directory.
+func ReadDir(fs *FS, dirname string) ([]os.FileInfo, error) {
+	names := resolve(fs.wd, dirname)
+	m, path := fs.ns.Lookup(names)
 
-		case "..":
-			// Move to parent.
-			if len(path) > 1 {
-				path = path[0 : len(path)-1]
+	result, err := m.FS.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range fs.ns.children(names) {
+		info, err := child.FS.Stat("/")
+		if err != nil {
+			continue
+		}
+		name := child.names[len(child.names)-1]
+		if fi, ok := info.(*FileInfo); ok {
+			mi := *fi
+			mi.name = name
+			info = &mi
+		} else {
+			info = &mountInfo{
+				FileInfo: info,
+				name:     name,
 			}
-
-		default:
-			// Resolve child.
-			entry, err := fs.LookupChild(path, part)
-			if err != nil {
-				return nil, err
+		}
+		var found bool
+		for idx, i := range result {
+			if i.Name() == info.Name() {
+				result[idx] = info
+				found = true
+				break
 			}
-			path = append(path, *entry)
+		}
+		if !found {
+			result = append(result, info)
 		}
 	}
+	return result, nil
+}
 
-	return path, nil
+// mountInfo describes the root directory of a mounted filesystem by
+// its mount point name.
+type mountInfo struct {
+	os.FileInfo
+	name string
 }
 
-func (fs *FS) LookupChild(path Path, name string) (*PathElement, error) {
-	if len(path) == 0 {
-		return nil, fmt.Errorf("No current working directory")
-	}
-	element, err := tree.DeserializeID(path[len(path)-1].ID, fs.zone)
-	if err != nil {
-		return nil, err
-	}
-	el, ok := element.(*tree.Directory)
-	if !ok {
-		return nil, fmt.Errorf("Invalid current working directory: %T", element)
-	}
-	for _, e := range el.Entries {
-		if name == e.Name {
-			return &PathElement{
-				ID:   e.Entry,
-				Name: e.Name,
-			}, nil
-		}
-	}
+func (info *mountInfo) Name() string {
+	return info.name
+}
+
+// Flags for OpenFile. The values match the constants of the
+// wasm_fs.js filesystem interface.
+const (
+	O_RDONLY  int = 0
+	O_WRONLY  int = 01
+	O_RDWR    int = 02
+	O_ACCMODE int = 03
+	O_CREAT   int = 0100
+	O_EXCL    int = 0200
+	O_TRUNC   int = 01000
+	O_APPEND  int = 02000
+)
 
-	return nil, fmt.Errorf("No such file or directory '%s'", name)
+func Open(fs *FS, name string) (File, error) {
+	return OpenFile(fs, name, O_RDONLY, 0)
 }
 
-type Path []PathElement
+// OpenFile opens the named file with the flags flag. If the file does
+// not exist and O_CREAT flag is set, the file is created with the
+// mode perm. The O_CREAT flag is also valid for read-only opens but
+// O_TRUNC requires write access.
+func OpenFile(fs *FS, name string, flag int, perm os.FileMode) (File, error) {
+	if flag&O_ACCMODE == O_RDONLY && flag&O_TRUNC != 0 {
+		return nil, errno.EINVAL
+	}
+	m, path := fs.Lookup(name)
+	return m.FS.Open(path, flag, perm)
+}
 
-func (p Path) String() string {
-	str := "/"
-	for _, e := range p {
-		if len(e.Name) > 0 {
-			if str[len(str)-1] != '/' {
-				str += "/"
-			}
-			str += e.String()
-		}
+// Mkdir creates a new directory with the permission bits perm.
+func Mkdir(fs *FS, name string, perm os.FileMode) error {
+	m, path := fs.Lookup(name)
+	if path == "/" {
+		return errno.EEXIST
 	}
-	return str
+	return m.FS.Mkdir(path, perm)
 }
 
-func (p Path) Copy() Path {
-	result := make(Path, len(p))
-	copy(result, p)
-	return result
+// Unlink removes the named file. Unlink does not remove directories.
+func Unlink(fs *FS, name string) error {
+	m, path := fs.Lookup(name)
+	if path == "/" {
+		return errno.EISDIR
+	}
+	return m.FS.Unlink(path)
+}
+
+// Rmdir removes the named empty directory.
+func Rmdir(fs *FS, name string) error {
+	m, path := fs.Lookup(name)
+	if path == "/" {
+		return errno.EBUSY
+	}
+	return m.FS.Rmdir(path)
 }
 
-type PathElement struct {
-	ID   storage.ID
-	Name string
+// Rename renames the file from to the name to. If to exists and it
+// is not a directory, it is replaced. The files must be in the same
+// filesystem.
+func Rename(fs *FS, from, to string) error {
+	fromMount, fromPath := fs.Lookup(from)
+	toMount, toPath := fs.Lookup(to)
+	if fromMount != toMount {
+		return errno.EXDEV
+	}
+	if fromPath == "/" || toPath == "/" {
+		return errno.EBUSY
+	}
+	return fromMount.FS.Rename(fromPath, toPath)
 }
 
-func (wd PathElement) String() string {
-	return file.PathEscape(wd.Name)
+// Truncate changes the size of the named file.
+func Truncate(fs *FS, name string, size int64) error {
+	m, path := fs.Lookup(name)
+	return m.FS.Truncate(path, size)
 }
@@ -0,0 +1,145 @@
+//
+// snapshot.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package fs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/markkurossi/backup/lib/storage"
+	"github.com/markkurossi/backup/lib/tree"
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+)
+
+// Snapshot describes a snapshot of the zone.
+type Snapshot struct {
+	ID        storage.ID
+	Parent    storage.ID
+	Root      storage.ID
+	Timestamp time.Time
+	Message   string
+}
+
+// Snapshots returns the snapshot history of the volume's zone,
+// starting from the head snapshot.
+func (v *Volume) Snapshots() ([]Snapshot, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return v.snapshots()
+}
+
+func (v *Volume) snapshots() ([]Snapshot, error) {
+	var result []Snapshot
+
+	for id := v.store.Head(); !id.Undefined(); {
+		element, err := v.store.Element(id)
+		if err != nil {
+			return nil, err
+		}
+		el, ok := element.(*tree.Snapshot)
+		if !ok {
+			return nil, fmt.Errorf("Invalid snapshot %s: %T", id, element)
+		}
+		result = append(result, Snapshot{
+			ID:        id,
+			Parent:    el.Parent,
+			Root:      el.Root,
+			Timestamp: time.Unix(0, el.Timestamp),
+			Message:   el.Description,
+		})
+		id = el.Parent
+	}
+	return result, nil
+}
+
+// snapshot finds the snapshot which ID starts with the prefix. The
+// caller must hold the volume mutex.
+func (v *Volume) snapshot(prefix string) (*Snapshot, error) {
+	if len(prefix) == 0 {
+		return nil, errno.EINVAL
+	}
+	snapshots, err := v.snapshots()
+	if err != nil {
+		return nil, err
+	}
+	var result *Snapshot
+	for idx, s := range snapshots {
+		if strings.HasPrefix(s.ID.String(), prefix) {
+			if result != nil {
+				return nil, fmt.Errorf("Ambiguous snapshot ID '%s': %w",
+					prefix, errno.EINVAL)
+			}
+			result = &snapshots[idx]
+		}
+	}
+	if result == nil {
+		return nil, fmt.Errorf("Unknown snapshot '%s': %w", prefix,
+			errno.ENOENT)
+	}
+	return result, nil
+}
+
+// modified tests if the volume has changes which are not committed
+// into a snapshot. The caller must hold the volume mutex.
+func (v *Volume) modified() bool {
+	return v.root.String() != v.base.String()
+}
+
+// Commit creates a new snapshot from the current state of the volume
+// and sets it as the head of the zone. The parent of the new snapshot
+// is the snapshot which the volume was checked out from. The function
+// returns the ID of the new snapshot.
+func (v *Volume) Commit(message string) (storage.ID, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	el := &tree.Snapshot{
+		Timestamp:   time.Now().UnixNano(),
+		Description: message,
+		Root:        v.root,
+		Parent:      v.head,
+	}
+	id, err := v.store.Add(el)
+	if err != nil {
+		return id, err
+	}
+	err = v.store.SetHead(id)
+	if err != nil {
+		return id, err
+	}
+	v.head = id
+	v.base = v.root
+
+	return id, nil
+}
+
+// Checkout sets the volume's root to the root directory of the
+// snapshot. The snapshot is identified by the prefix of its ID. The
+// function fails if the volume has uncommitted changes, unless force
+// is true, in which case the changes are discarded.
+func (v *Volume) Checkout(prefix string, force bool) error {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if !force && v.modified() {
+		return fmt.Errorf("Volume has uncommitted changes: %w", errno.EBUSY)
+	}
+	s, err := v.snapshot(prefix)
+	if err != nil {
+		return err
+	}
+	v.head = s.ID
+	v.root = s.Root
+	v.base = s.Root
+	v.mtime = s.Timestamp.UnixNano()
+
+	return nil
+}
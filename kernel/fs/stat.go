@@ -8,6 +8,11 @@
 
 package fs
 
+import (
+	"hash/fnv"
+	"os"
+)
+
 const (
 	S_IFMT   int = 0170000 /* type of file */
 	S_IFIFO  int = 0010000 /* named pipe (fifo) */
@@ -19,3 +24,46 @@ const (
 	S_IFSOCK int = 0140000 /* socket */
 	S_IFWHT  int = 0160000 /* whiteout */
 )
+
+// StatInfo extends the os.FileInfo with the inode number and the
+// number of hard links of the file.
+type StatInfo interface {
+	os.FileInfo
+	Ino() uint64
+	Nlink() int
+}
+
+// Inode returns a stable inode number for the key. The number fits
+// into 53 bits so it is exact as a JavaScript number.
+func Inode(key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	ino := h.Sum64() & (1<<53 - 1)
+	if ino == 0 {
+		ino = 1
+	}
+	return ino
+}
+
+// Mode returns the stat mode bits for the file mode.
+func Mode(mode os.FileMode) int {
+	result := int(mode.Perm())
+
+	switch {
+	case mode&os.ModeDir != 0:
+		result |= S_IFDIR
+	case mode&os.ModeCharDevice != 0:
+		result |= S_IFCHR
+	case mode&os.ModeDevice != 0:
+		result |= S_IFBLK
+	case mode&os.ModeNamedPipe != 0:
+		result |= S_IFIFO
+	case mode&os.ModeSymlink != 0:
+		result |= S_IFLNK
+	case mode&os.ModeSocket != 0:
+		result |= S_IFSOCK
+	default:
+		result |= S_IFREG
+	}
+	return result
+}
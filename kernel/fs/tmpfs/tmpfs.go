@@ -0,0 +1,507 @@
+//
+// tmpfs.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+// Package tmpfs implements a filesystem which keeps its files and
+// directories in the kernel memory.
+package tmpfs
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+	"github.com/markkurossi/blackbox-os/kernel/fs"
+	"github.com/markkurossi/blackbox-os/lib/file"
+)
+
+var (
+	_ fs.VFS  = &FS{}
+	_ fs.File = &File{}
+)
+
+// FS implements the in-memory filesystem.
+type FS struct {
+	mutex sync.Mutex
+	root  *node
+}
+
+// inodes is the last allocated inode number.
+var inodes uint64
+
+type node struct {
+	ino     uint64
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte
+	entries map[string]*node
+}
+
+func newNode(mode os.FileMode) *node {
+	n := &node{
+		ino:     atomic.AddUint64(&inodes, 1),
+		mode:    mode,
+		modTime: time.Now(),
+	}
+	if mode.IsDir() {
+		n.entries = make(map[string]*node)
+	}
+	return n
+}
+
+func (n *node) info(name string) os.FileInfo {
+	info := fs.NewFileInfo(name, int64(len(n.data)), n.mode, n.modTime, nil)
+	nlink := 1
+	if n.mode.IsDir() {
+		nlink = 2
+		for _, child := range n.entries {
+			if child.mode.IsDir() {
+				nlink++
+			}
+		}
+	}
+	info.SetInode(n.ino, nlink)
+	return info
+}
+
+// truncate changes the size of the file data.
+func (n *node) truncate(size int64) error {
+	if n.mode.IsDir() {
+		return errno.EISDIR
+	}
+	if size < 0 {
+		return errno.EINVAL
+	}
+	if int(size) <= len(n.data) {
+		n.data = n.data[:size]
+	} else {
+		n.data = append(n.data, make([]byte, int(size)-len(n.data))...)
+	}
+	n.modTime = time.Now()
+	return nil
+}
+
+// New creates a new empty filesystem.
+func New() *FS {
+	return &FS{
+		root: newNode(os.ModeDir | 0777),
+	}
+}
+
+// split resolves the file name into its path names.
+func split(name string) []string {
+	var names []string
+	for _, part := range file.PathSplit(name) {
+		switch part {
+		case ".", "":
+		case "..":
+			if len(names) > 0 {
+				names = names[:len(names)-1]
+			}
+		default:
+			names = append(names, part)
+		}
+	}
+	return names
+}
+
+// lookup finds the node of the path names. The caller must hold the
+// filesystem mutex.
+func (tfs *FS) lookup(names []string) (*node, error) {
+	n := tfs.root
+	for _, name := range names {
+		if !n.mode.IsDir() {
+			return nil, errno.ENOTDIR
+		}
+		child, ok := n.entries[name]
+		if !ok {
+			return nil, errno.ENOENT
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// lookupParent finds the parent directory of the path names. The
+// function returns the directory and the base name of the file. The
+// caller must hold the filesystem mutex.
+func (tfs *FS) lookupParent(names []string) (*node, string, error) {
+	if len(names) == 0 {
+		return nil, "", errno.EINVAL
+	}
+	dir, err := tfs.lookup(names[:len(names)-1])
+	if err != nil {
+		return nil, "", err
+	}
+	if !dir.mode.IsDir() {
+		return nil, "", errno.ENOTDIR
+	}
+	return dir, names[len(names)-1], nil
+}
+
+// Type implements the fs.VFS.Type().
+func (tfs *FS) Type() string {
+	return "tmpfs"
+}
+
+// Stat implements the fs.VFS.Stat().
+func (tfs *FS) Stat(name string) (os.FileInfo, error) {
+	tfs.mutex.Lock()
+	defer tfs.mutex.Unlock()
+
+	names := split(name)
+	n, err := tfs.lookup(names)
+	if err != nil {
+		return nil, err
+	}
+	return n.info(baseName(names)), nil
+}
+
+// Open implements the fs.VFS.Open().
+func (tfs *FS) Open(name string, flag int, perm os.FileMode) (fs.File,
+	error) {
+
+	tfs.mutex.Lock()
+	defer tfs.mutex.Unlock()
+
+	names := split(name)
+	n, err := tfs.lookup(names)
+	if err != nil {
+		if err != errno.ENOENT || flag&fs.O_CREAT == 0 {
+			return nil, err
+		}
+		dir, base, err := tfs.lookupParent(names)
+		if err != nil {
+			return nil, err
+		}
+		n = newNode(perm.Perm())
+		dir.entries[base] = n
+		dir.modTime = n.modTime
+	} else if flag&(fs.O_CREAT|fs.O_EXCL) == fs.O_CREAT|fs.O_EXCL {
+		return nil, errno.EEXIST
+	}
+	f := &File{
+		fs:   tfs,
+		node: n,
+		name: baseName(names),
+		flag: flag,
+	}
+	if f.writable() {
+		if n.mode.IsDir() {
+			return nil, errno.EISDIR
+		}
+		if flag&fs.O_TRUNC != 0 {
+			n.truncate(0)
+		}
+	}
+	return f, nil
+}
+
+// ReadDir implements the fs.VFS.ReadDir().
+func (tfs *FS) ReadDir(name string) ([]os.FileInfo, error) {
+	tfs.mutex.Lock()
+	defer tfs.mutex.Unlock()
+
+	n, err := tfs.lookup(split(name))
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, errno.ENOTDIR
+	}
+	var result []os.FileInfo
+	for name, child := range n.entries {
+		result = append(result, child.info(name))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name() < result[j].Name()
+	})
+	return result, nil
+}
+
+// Mkdir implements the fs.VFS.Mkdir().
+func (tfs *FS) Mkdir(name string, perm os.FileMode) error {
+	tfs.mutex.Lock()
+	defer tfs.mutex.Unlock()
+
+	dir, base, err := tfs.lookupParent(split(name))
+	if err != nil {
+		return err
+	}
+	if _, ok := dir.entries[base]; ok {
+		return errno.EEXIST
+	}
+	n := newNode(os.ModeDir | perm.Perm())
+	dir.entries[base] = n
+	dir.modTime = n.modTime
+
+	return nil
+}
+
+// Unlink implements the fs.VFS.Unlink().
+func (tfs *FS) Unlink(name string) error {
+	return tfs.remove(name, false)
+}
+
+// Rmdir implements the fs.VFS.Rmdir().
+func (tfs *FS) Rmdir(name string) error {
+	return tfs.remove(name, true)
+}
+
+func (tfs *FS) remove(name string, isDir bool) error {
+	tfs.mutex.Lock()
+	defer tfs.mutex.Unlock()
+
+	dir, base, err := tfs.lookupParent(split(name))
+	if err != nil {
+		return err
+	}
+	n, ok := dir.entries[base]
+	if !ok {
+		return errno.ENOENT
+	}
+	if isDir {
+		if !n.mode.IsDir() {
+			return errno.ENOTDIR
+		}
+		if len(n.entries) > 0 {
+			return errno.ENOTEMPTY
+		}
+	} else if n.mode.IsDir() {
+		return errno.EISDIR
+	}
+	delete(dir.entries, base)
+	dir.modTime = time.Now()
+
+	return nil
+}
+
+// Rename implements the fs.VFS.Rename(). If the target exists, it is
+// replaced. A directory can replace only an empty directory.
+func (tfs *FS) Rename(from, to string) error {
+	tfs.mutex.Lock()
+	defer tfs.mutex.Unlock()
+
+	fromNames := split(from)
+	toNames := split(to)
+
+	fromDir, fromBase, err := tfs.lookupParent(fromNames)
+	if err != nil {
+		return err
+	}
+	n, ok := fromDir.entries[fromBase]
+	if !ok {
+		return errno.ENOENT
+	}
+	toDir, toBase, err := tfs.lookupParent(toNames)
+	if err != nil {
+		return err
+	}
+	if n.mode.IsDir() && len(toNames) > len(fromNames) &&
+		isPrefix(fromNames, toNames) {
+		// Can't move directory into itself.
+		return errno.EINVAL
+	}
+	old, ok := toDir.entries[toBase]
+	if ok {
+		if old == n {
+			return nil
+		}
+		if old.mode.IsDir() {
+			if !n.mode.IsDir() {
+				return errno.EISDIR
+			}
+			if len(old.entries) > 0 {
+				return errno.ENOTEMPTY
+			}
+		} else if n.mode.IsDir() {
+			return errno.ENOTDIR
+		}
+	}
+	now := time.Now()
+
+	delete(fromDir.entries, fromBase)
+	fromDir.modTime = now
+	toDir.entries[toBase] = n
+	toDir.modTime = now
+
+	return nil
+}
+
+// Truncate implements the fs.VFS.Truncate().
+func (tfs *FS) Truncate(name string, size int64) error {
+	tfs.mutex.Lock()
+	defer tfs.mutex.Unlock()
+
+	n, err := tfs.lookup(split(name))
+	if err != nil {
+		return err
+	}
+	return n.truncate(size)
+}
+
+func baseName(names []string) string {
+	if len(names) == 0 {
+		return "/"
+	}
+	return names[len(names)-1]
+}
+
+func isPrefix(prefix, names []string) bool {
+	for idx, name := range prefix {
+		if names[idx] != name {
+			return false
+		}
+	}
+	return true
+}
+
+// File implements an open file of the in-memory filesystem.
+type File struct {
+	fs     *FS
+	node   *node
+	name   string
+	flag   int
+	offset int64
+}
+
+func (f *File) writable() bool {
+	return f.flag&fs.O_ACCMODE != fs.O_RDONLY
+}
+
+func (f *File) readable() bool {
+	return f.flag&fs.O_ACCMODE != fs.O_WRONLY
+}
+
+// Read implements the io.Reader interface.
+func (f *File) Read(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	n, err := f.readAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	return f.readAt(p, off)
+}
+
+func (f *File) readAt(p []byte, off int64) (int, error) {
+	if !f.readable() {
+		return 0, errno.EBADF
+	}
+	if f.node.mode.IsDir() {
+		return 0, errno.EISDIR
+	}
+	if off < 0 {
+		return 0, errno.EINVAL
+	}
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Write implements the io.Writer interface.
+func (f *File) Write(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.flag&fs.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	n, err := f.writeAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+// WriteAt implements the io.WriterAt interface.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	return f.writeAt(p, off)
+}
+
+func (f *File) writeAt(p []byte, off int64) (int, error) {
+	if !f.writable() {
+		return 0, errno.EBADF
+	}
+	if off < 0 {
+		return 0, errno.EINVAL
+	}
+	end := int(off) + len(p)
+	if end > len(f.node.data) {
+		f.node.data = append(f.node.data,
+			make([]byte, end-len(f.node.data))...)
+	}
+	copy(f.node.data[off:], p)
+	f.node.modTime = time.Now()
+
+	return len(p), nil
+}
+
+// Seek implements the io.Seeker interface.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	default:
+		return 0, errno.EINVAL
+	}
+	if offset < 0 {
+		return 0, errno.EINVAL
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Truncate changes the size of the file.
+func (f *File) Truncate(size int64) error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if !f.writable() {
+		return errno.EINVAL
+	}
+	return f.node.truncate(size)
+}
+
+// Stat implements the fs.File.Stat().
+func (f *File) Stat() (os.FileInfo, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	return f.node.info(f.name), nil
+}
+
+// Close implements the io.Closer interface.
+func (f *File) Close() error {
+	return nil
+}
@@ -0,0 +1,266 @@
+//
+// tmpfs_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package tmpfs
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+	"github.com/markkurossi/blackbox-os/kernel/fs"
+)
+
+func create(t *testing.T, tfs *FS, name, data string) {
+	f, err := tfs.Open(name, fs.O_WRONLY|fs.O_CREAT|fs.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatalf("Open(%s) failed: %s", name, err)
+	}
+	_, err = f.Write([]byte(data))
+	if err != nil {
+		t.Fatalf("Write(%s) failed: %s", name, err)
+	}
+	f.Close()
+}
+
+func content(t *testing.T, tfs *FS, name string) string {
+	f, err := tfs.Open(name, fs.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("Open(%s) failed: %s", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("Read(%s) failed: %s", name, err)
+	}
+	return string(data)
+}
+
+func TestReadWrite(t *testing.T) {
+	tfs := New()
+	create(t, tfs, "/a", "hello, world")
+
+	if data := content(t, tfs, "/a"); data != "hello, world" {
+		t.Errorf("unexpected content: %q", data)
+	}
+
+	f, err := tfs.Open("/a", fs.O_WRONLY|fs.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	f.Write([]byte("!"))
+	f.Close()
+	if data := content(t, tfs, "/a"); data != "hello, world!" {
+		t.Errorf("unexpected content after append: %q", data)
+	}
+
+	_, err = tfs.Open("/a", fs.O_WRONLY|fs.O_CREAT|fs.O_EXCL, 0644)
+	if err != errno.EEXIST {
+		t.Errorf("exclusive create: got %v, expected EEXIST", err)
+	}
+	_, err = tfs.Open("/missing", fs.O_RDONLY, 0)
+	if err != errno.ENOENT {
+		t.Errorf("open missing: got %v, expected ENOENT", err)
+	}
+	_, err = tfs.Open("/missing/a", fs.O_WRONLY|fs.O_CREAT, 0644)
+	if err != errno.ENOENT {
+		t.Errorf("create in missing: got %v, expected ENOENT", err)
+	}
+
+	ro, err := tfs.Open("/a", fs.O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	_, err = ro.Write([]byte("x"))
+	if err != errno.EBADF {
+		t.Errorf("write read-only: got %v, expected EBADF", err)
+	}
+}
+
+func TestSeek(t *testing.T) {
+	tfs := New()
+	create(t, tfs, "/f", "0123456789")
+
+	f, err := tfs.Open("/f", fs.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	file := f.(*File)
+
+	pos, err := file.Seek(-3, io.SeekEnd)
+	if err != nil || pos != 7 {
+		t.Fatalf("Seek=%d,%v, expected 7", pos, err)
+	}
+	buf := make([]byte, 5)
+	n, err := file.Read(buf)
+	if err != nil || string(buf[:n]) != "789" {
+		t.Errorf("Read=%q,%v, expected \"789\"", buf[:n], err)
+	}
+	_, err = file.Read(buf)
+	if err != io.EOF {
+		t.Errorf("Read at end: got %v, expected EOF", err)
+	}
+
+	n, err = file.ReadAt(buf[:3], 2)
+	if err != nil || string(buf[:n]) != "234" {
+		t.Errorf("ReadAt=%q,%v, expected \"234\"", buf[:n], err)
+	}
+	_, err = file.WriteAt([]byte("xy"), 12)
+	if err != nil {
+		t.Fatalf("WriteAt failed: %s", err)
+	}
+	if data := content(t, tfs, "/f"); data != "0123456789\x00\x00xy" {
+		t.Errorf("unexpected content: %q", data)
+	}
+	_, err = file.Seek(-1, io.SeekStart)
+	if err != errno.EINVAL {
+		t.Errorf("negative seek: got %v, expected EINVAL", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tfs := New()
+	create(t, tfs, "/f", "0123456789")
+
+	err := tfs.Truncate("/f", 4)
+	if err != nil {
+		t.Fatalf("Truncate failed: %s", err)
+	}
+	if data := content(t, tfs, "/f"); data != "0123" {
+		t.Errorf("unexpected content: %q", data)
+	}
+	err = tfs.Truncate("/f", 6)
+	if err != nil {
+		t.Fatalf("Truncate failed: %s", err)
+	}
+	info, err := tfs.Stat("/f")
+	if err != nil {
+		t.Fatalf("Stat failed: %s", err)
+	}
+	if info.Size() != 6 {
+		t.Errorf("size %d, expected 6", info.Size())
+	}
+	if data := content(t, tfs, "/f"); data != "0123\x00\x00" {
+		t.Errorf("unexpected content: %q", data)
+	}
+
+	f, err := tfs.Open("/f", fs.O_WRONLY|fs.O_TRUNC, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	info, err = f.Stat()
+	if err != nil || info.Size() != 0 {
+		t.Errorf("size after O_TRUNC: %v, %v", info, err)
+	}
+}
+
+func TestDirectories(t *testing.T) {
+	tfs := New()
+
+	if err := tfs.Mkdir("/d", 0755); err != nil {
+		t.Fatalf("Mkdir failed: %s", err)
+	}
+	if err := tfs.Mkdir("/d", 0755); err != errno.EEXIST {
+		t.Errorf("Mkdir existing: got %v, expected EEXIST", err)
+	}
+	create(t, tfs, "/d/b", "b")
+	create(t, tfs, "/d/a", "aa")
+
+	infos, err := tfs.ReadDir("/d")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %s", err)
+	}
+	if len(infos) != 2 || infos[0].Name() != "a" || infos[1].Name() != "b" {
+		t.Fatalf("unexpected entries: %v", infos)
+	}
+	if infos[0].Size() != 2 || infos[0].Mode() != 0644 {
+		t.Errorf("unexpected info: size=%d, mode=%s", infos[0].Size(),
+			infos[0].Mode())
+	}
+
+	info, err := tfs.Stat("/d/../d/.")
+	if err != nil {
+		t.Fatalf("Stat failed: %s", err)
+	}
+	if !info.IsDir() || info.Mode() != os.ModeDir|0755 {
+		t.Errorf("unexpected mode %s", info.Mode())
+	}
+	if info.ModTime().IsZero() {
+		t.Errorf("zero modification time")
+	}
+	tfs.Mkdir("/d/e", 0755)
+	info, err = tfs.Stat("/d")
+	if err != nil {
+		t.Fatalf("Stat failed: %s", err)
+	}
+	if si := info.(fs.StatInfo); si.Nlink() != 3 || si.Ino() == 0 {
+		t.Errorf("unexpected inode: ino=%d, nlink=%d", si.Ino(), si.Nlink())
+	}
+	tfs.Rmdir("/d/e")
+
+	if err := tfs.Rmdir("/d"); err != errno.ENOTEMPTY {
+		t.Errorf("Rmdir non-empty: got %v, expected ENOTEMPTY", err)
+	}
+	if err := tfs.Unlink("/d"); err != errno.EISDIR {
+		t.Errorf("Unlink dir: got %v, expected EISDIR", err)
+	}
+	if err := tfs.Rmdir("/d/a"); err != errno.ENOTDIR {
+		t.Errorf("Rmdir file: got %v, expected ENOTDIR", err)
+	}
+	if _, err := tfs.Open("/d", fs.O_WRONLY, 0); err != errno.EISDIR {
+		t.Errorf("Open dir for writing: got %v, expected EISDIR", err)
+	}
+	for _, name := range []string{"/d/a", "/d/b"} {
+		if err := tfs.Unlink(name); err != nil {
+			t.Errorf("Unlink(%s) failed: %s", name, err)
+		}
+	}
+	if err := tfs.Rmdir("/d"); err != nil {
+		t.Errorf("Rmdir failed: %s", err)
+	}
+	if _, err := tfs.Stat("/d"); err != errno.ENOENT {
+		t.Errorf("Stat removed: got %v, expected ENOENT", err)
+	}
+}
+
+func TestRename(t *testing.T) {
+	tfs := New()
+	tfs.Mkdir("/d", 0755)
+	tfs.Mkdir("/e", 0755)
+	create(t, tfs, "/a", "a")
+	create(t, tfs, "/b", "b")
+
+	if err := tfs.Rename("/a", "/d/c"); err != nil {
+		t.Fatalf("Rename failed: %s", err)
+	}
+	if _, err := tfs.Stat("/a"); err != errno.ENOENT {
+		t.Errorf("Stat renamed: got %v, expected ENOENT", err)
+	}
+	if data := content(t, tfs, "/d/c"); data != "a" {
+		t.Errorf("unexpected content: %q", data)
+	}
+	if err := tfs.Rename("/b", "/d/c"); err != nil {
+		t.Fatalf("Rename over file failed: %s", err)
+	}
+	if data := content(t, tfs, "/d/c"); data != "b" {
+		t.Errorf("unexpected content: %q", data)
+	}
+	if err := tfs.Rename("/d/c", "/e"); err != errno.EISDIR {
+		t.Errorf("Rename over dir: got %v, expected EISDIR", err)
+	}
+	if err := tfs.Rename("/d", "/d/x"); err != errno.EINVAL {
+		t.Errorf("Rename into itself: got %v, expected EINVAL", err)
+	}
+	if err := tfs.Rename("/d", "/e"); err != nil {
+		t.Fatalf("Rename over empty dir failed: %s", err)
+	}
+	if data := content(t, tfs, "/e/c"); data != "b" {
+		t.Errorf("unexpected content: %q", data)
+	}
+}
@@ -0,0 +1,190 @@
+//
+// vfs.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package fs
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+	"github.com/markkurossi/blackbox-os/lib/file"
+)
+
+// VFS defines a filesystem which can be mounted into a
+// namespace. The file names of the operations are absolute paths
+// from the root of the filesystem.
+type VFS interface {
+	// Type returns the filesystem type name.
+	Type() string
+	Stat(name string) (os.FileInfo, error)
+	Open(name string, flag int, perm os.FileMode) (File, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	Mkdir(name string, perm os.FileMode) error
+	Unlink(name string) error
+	Rmdir(name string) error
+	Rename(from, to string) error
+	Truncate(name string, size int64) error
+}
+
+// File defines an open file of a filesystem.
+type File interface {
+	io.ReadWriteCloser
+	Stat() (os.FileInfo, error)
+}
+
+var (
+	_ VFS  = &Volume{}
+	_ File = &VolumeFile{}
+)
+
+// Mount defines a filesystem mounted into a namespace.
+type Mount struct {
+	Path  string
+	FS    VFS
+	names []string
+}
+
+// Namespace implements the mount table which maps mount points to
+// filesystems.
+type Namespace struct {
+	mutex  sync.Mutex
+	mounts []*Mount
+}
+
+// NewNamespace creates a new namespace with the root filesystem.
+func NewNamespace(root VFS) *Namespace {
+	return &Namespace{
+		mounts: []*Mount{
+			&Mount{
+				Path: "/",
+				FS:   root,
+			},
+		},
+	}
+}
+
+// Mount mounts the filesystem at the absolute path.
+func (ns *Namespace) Mount(path string, vfs VFS) error {
+	names := resolve(nil, path)
+
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+
+	for _, m := range ns.mounts {
+		if equalNames(m.names, names) {
+			return errno.EBUSY
+		}
+	}
+	ns.mounts = append(ns.mounts, &Mount{
+		Path:  namesPath(names),
+		FS:    vfs,
+		names: names,
+	})
+	sort.Slice(ns.mounts, func(i, j int) bool {
+		return ns.mounts[i].Path < ns.mounts[j].Path
+	})
+	return nil
+}
+
+// Mounts returns the mount table sorted by the mount points.
+func (ns *Namespace) Mounts() []Mount {
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+
+	var result []Mount
+	for _, m := range ns.mounts {
+		result = append(result, *m)
+	}
+	return result
+}
+
+// Lookup finds the filesystem containing the absolute path names. The
+// function returns the mount and the name of the file inside the
+// mounted filesystem.
+func (ns *Namespace) Lookup(names []string) (*Mount, string) {
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+
+	var result *Mount
+	for _, m := range ns.mounts {
+		if len(m.names) > len(names) ||
+			!equalNames(m.names, names[:len(m.names)]) {
+			continue
+		}
+		if result == nil || len(m.names) > len(result.names) {
+			result = m
+		}
+	}
+	return result, namesPath(names[len(result.names):])
+}
+
+// children returns the mount points directly below the absolute path
+// names.
+func (ns *Namespace) children(names []string) []*Mount {
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+
+	var result []*Mount
+	for _, m := range ns.mounts {
+		if len(m.names) == len(names)+1 &&
+			equalNames(m.names[:len(names)], names) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// resolve resolves the file name relative to the working directory
+// wd. The function returns the names of the absolute path. Relative
+// names starting with `@' refer to the snapshots at the root
+// directory.
+func resolve(wd []string, name string) []string {
+	var parts []string
+	if len(name) > 0 {
+		parts = file.PathSplit(name)
+	}
+	var names []string
+	if len(parts) == 0 || (len(parts[0]) > 0 && parts[0][0] != '@') {
+		names = append(names, wd...)
+	}
+	for _, part := range parts {
+		switch part {
+		case ".", "":
+			// Stay at the current directory.
+
+		case "..":
+			if len(names) > 0 {
+				names = names[:len(names)-1]
+			}
+
+		default:
+			names = append(names, part)
+		}
+	}
+	return names
+}
+
+// namesPath returns the absolute path of the names.
+func namesPath(names []string) string {
+	return file.Path(append([]string{""}, names...)).String()
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx, name := range a {
+		if name != b[idx] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,419 @@
+//
+// volume.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package fs
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/markkurossi/backup/lib/crypto/zone"
+	"github.com/markkurossi/backup/lib/storage"
+	"github.com/markkurossi/backup/lib/tree"
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+	"github.com/markkurossi/blackbox-os/lib/file"
+)
+
+// Volume implements a copy-on-write layer on top of a zone. The
+// volume starts from the zone's head snapshot. All modifications are
+// written into the zone as new tree elements and the volume's root
+// is updated to point to the new root directory. The elements of the
+// original snapshot are never modified. The head is the snapshot
+// which the volume was checked out from and the base is the root
+// directory of that snapshot.
+type Volume struct {
+	mutex sync.Mutex
+	store store
+	head  storage.ID
+	root  storage.ID
+	base  storage.ID
+	mtime int64
+}
+
+// store stores the tree elements of a volume and the head of its
+// snapshot history.
+type store interface {
+	// Element returns the tree element id.
+	Element(id storage.ID) (tree.Element, error)
+	// Add stores the tree element and returns its ID.
+	Add(el tree.Element) (storage.ID, error)
+	// Head returns the ID of the head snapshot.
+	Head() storage.ID
+	// SetHead sets the head snapshot.
+	SetHead(id storage.ID) error
+}
+
+// zoneStore stores the volume elements in a zone.
+type zoneStore struct {
+	zone *zone.Zone
+}
+
+func (z *zoneStore) Element(id storage.ID) (tree.Element, error) {
+	return tree.DeserializeID(id, z.zone)
+}
+
+func (z *zoneStore) Add(el tree.Element) (storage.ID, error) {
+	switch e := el.(type) {
+	case *tree.Directory:
+		return e.Serialize(z.zone)
+	case *tree.SimpleFile:
+		return e.Serialize(z.zone)
+	case *tree.Snapshot:
+		return e.Serialize(z.zone)
+	default:
+		return storage.ID{}, fmt.Errorf("Invalid element %T", el)
+	}
+}
+
+func (z *zoneStore) Head() storage.ID {
+	return z.zone.HeadID
+}
+
+func (z *zoneStore) SetHead(id storage.ID) error {
+	return z.zone.SetHead(id)
+}
+
+// NewVolume creates a new volume for the zone's head snapshot.
+func NewVolume(z *zone.Zone) (*Volume, error) {
+	return newVolume(&zoneStore{
+		zone: z,
+	})
+}
+
+func newVolume(st store) (*Volume, error) {
+	// Find snapshot root.
+	head := st.Head()
+	element, err := st.Element(head)
+	if err != nil {
+		// Empty filesystem.
+		return nil, err
+	}
+	el, ok := element.(*tree.Snapshot)
+	if !ok {
+		return nil, fmt.Errorf("Invalid filesystem root directory: %T", element)
+	}
+	return &Volume{
+		store: st,
+		head:  head,
+		root:  el.Root,
+		base:  el.Root,
+		mtime: el.Timestamp,
+	}, nil
+}
+
+// Root returns the ID of the volume's current root directory.
+func (v *Volume) Root() storage.ID {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	return v.root
+}
+
+// ResolvePath resolves the filename starting from the volume root.
+func (v *Volume) ResolvePath(filename string) (Path, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return v.resolvePath(filename)
+}
+
+// resolvePath resolves the filename starting from the current root
+// of the volume. The caller must hold the volume mutex.
+func (v *Volume) resolvePath(filename string) (Path, error) {
+	var parts []string
+
+	if len(filename) > 0 {
+		parts = file.PathSplit(filename)
+	}
+
+	path := Path{
+		PathElement{
+			ID:      v.root,
+			Name:    "",
+			Mode:    os.ModeDir | 0755,
+			ModTime: v.mtime,
+		},
+	}
+
+	for _, part := range parts {
+		switch part {
+		case ".", "":
+			// Stay at the current directory.
+
+		case "..":
+			// Move to parent.
+			if len(path) > 1 {
+				path = path[0 : len(path)-1]
+			}
+
+		default:
+			if len(path) == 1 && part[0] == '@' {
+				// Read-only snapshot root.
+				s, err := v.snapshot(part[1:])
+				if err != nil {
+					return nil, err
+				}
+				path = append(path, PathElement{
+					ID:       s.Root,
+					Name:     part,
+					Mode:     os.ModeDir | 0555,
+					ModTime:  s.Timestamp.UnixNano(),
+					Snapshot: true,
+				})
+				continue
+			}
+			// Resolve child.
+			entry, err := v.lookupChild(path, part)
+			if err != nil {
+				return nil, err
+			}
+			path = append(path, *entry)
+		}
+	}
+
+	return path, nil
+}
+
+func (v *Volume) lookupChild(path Path, name string) (*PathElement, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("No current working directory")
+	}
+	element, err := v.store.Element(path[len(path)-1].ID)
+	if err != nil {
+		return nil, err
+	}
+	el, ok := element.(*tree.Directory)
+	if !ok {
+		return nil, fmt.Errorf("Invalid directory %T: %w", element,
+			errno.ENOTDIR)
+	}
+	for _, e := range el.Entries {
+		if name == e.Name {
+			return &PathElement{
+				ID:      e.Entry,
+				Name:    e.Name,
+				Mode:    os.FileMode(e.Mode),
+				ModTime: e.ModTime,
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("No such file or directory '%s': %w",
+		name, errno.ENOENT)
+}
+
+type Path []PathElement
+
+func (p Path) String() string {
+	str := "/"
+	for _, e := range p {
+		if len(e.Name) > 0 {
+			if str[len(str)-1] != '/' {
+				str += "/"
+			}
+			str += e.String()
+		}
+	}
+	return str
+}
+
+func (p Path) Copy() Path {
+	result := make(Path, len(p))
+	copy(result, p)
+	return result
+}
+
+// ReadOnly tests if the path is inside a read-only snapshot.
+func (p Path) ReadOnly() bool {
+	for _, e := range p {
+		if e.Snapshot {
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the names of the path elements below the root
+// directory.
+func (p Path) Names() []string {
+	var result []string
+	for _, e := range p {
+		if len(e.Name) > 0 {
+			result = append(result, e.Name)
+		}
+	}
+	return result
+}
+
+// PathElement describes a file of the path. The Mode and ModTime are
+// from the file's entry in its parent directory.
+type PathElement struct {
+	ID       storage.ID
+	Name     string
+	Mode     os.FileMode
+	ModTime  int64
+	Snapshot bool
+}
+
+func (wd PathElement) String() string {
+	return file.PathEscape(wd.Name)
+}
+
+// directory loads a private copy of the directory element id.
+func (v *Volume) directory(id storage.ID) (*tree.Directory, error) {
+	element, err := v.store.Element(id)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := element.(*tree.Directory)
+	if !ok {
+		return nil, errno.ENOTDIR
+	}
+	return &tree.Directory{
+		Entries: append([]tree.DirectoryEntry(nil), dir.Entries...),
+	}, nil
+}
+
+// update stores the directory as the new version of the last element
+// of the path and rewrites all its parent directories up to the
+// volume root. The caller must hold the volume mutex.
+func (v *Volume) update(path Path, dir *tree.Directory) error {
+	id, err := v.store.Add(dir)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano()
+
+	for i := len(path) - 2; i >= 0; i-- {
+		parent, err := v.directory(path[i].ID)
+		if err != nil {
+			return err
+		}
+		idx := findEntry(parent, path[i+1].Name)
+		if idx < 0 {
+			return fmt.Errorf("Directory '%s' modified: %w",
+				path[:i+1], errno.ENOENT)
+		}
+		parent.Entries[idx].Entry = id
+		parent.Entries[idx].ModTime = now
+
+		id, err = v.store.Add(parent)
+		if err != nil {
+			return err
+		}
+	}
+	v.root = id
+	v.mtime = now
+
+	return nil
+}
+
+// modify resolves the parent directory of name and calls f to modify
+// it. The modified directory is written back to the volume. The
+// function f receives the base name of the file name.
+func (v *Volume) modify(name string,
+	f func(dir *tree.Directory, base string) error) error {
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	return v.modifyLocked(name, f)
+}
+
+func (v *Volume) modifyLocked(name string,
+	f func(dir *tree.Directory, base string) error) error {
+
+	dirname, base, err := splitName(name)
+	if err != nil {
+		return err
+	}
+	path, err := v.resolvePath(dirname)
+	if err != nil {
+		return err
+	}
+	if path.ReadOnly() {
+		return errno.EROFS
+	}
+	dir, err := v.directory(path[len(path)-1].ID)
+	if err != nil {
+		return err
+	}
+	err = f(dir, base)
+	if err != nil {
+		return err
+	}
+	return v.update(path, dir)
+}
+
+// splitName splits the file name into its directory and base name
+// parts.
+func splitName(name string) (string, string, error) {
+	parts := file.PathSplit(name)
+	if len(parts) == 0 {
+		return "", "", errno.ENOENT
+	}
+	base := parts[len(parts)-1]
+	switch base {
+	case "", ".", "..":
+		return "", "", errno.EINVAL
+	}
+	return parts[:len(parts)-1].String(), base, nil
+}
+
+// findEntry returns the index of the named entry in the directory,
+// or -1 if the directory does not have the entry.
+func findEntry(dir *tree.Directory, name string) int {
+	for idx, e := range dir.Entries {
+		if e.Name == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// setEntry adds the entry to the directory, replacing any existing
+// entry with the same name. The entries are kept sorted by name.
+func setEntry(dir *tree.Directory, entry tree.DirectoryEntry) {
+	idx := findEntry(dir, entry.Name)
+	if idx >= 0 {
+		dir.Entries[idx] = entry
+		return
+	}
+	dir.Entries = append(dir.Entries, entry)
+	sort.Slice(dir.Entries, func(i, j int) bool {
+		return dir.Entries[i].Name < dir.Entries[j].Name
+	})
+}
+
+// removeEntry removes the entry at index idx from the directory.
+func removeEntry(dir *tree.Directory, idx int) {
+	dir.Entries = append(dir.Entries[:idx], dir.Entries[idx+1:]...)
+}
+
+// storeFile writes the file data into the zone and sets it as the
+// named entry of the directory.
+func (v *Volume) storeFile(dir *tree.Directory, name string, mode os.FileMode,
+	data []byte) error {
+
+	el := &tree.SimpleFile{
+		Data: data,
+	}
+	id, err := v.store.Add(el)
+	if err != nil {
+		return err
+	}
+	setEntry(dir, tree.DirectoryEntry{
+		Name:    name,
+		Mode:    mode,
+		ModTime: time.Now().UnixNano(),
+		Entry:   id,
+	})
+	return nil
+}
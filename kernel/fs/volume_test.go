@@ -0,0 +1,294 @@
+//
+// volume_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/backup/lib/storage"
+	"github.com/markkurossi/backup/lib/tree"
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+)
+
+// memStore implements the volume store in memory.
+type memStore struct {
+	elements map[string]tree.Element
+	head     storage.ID
+	adds     int
+}
+
+func (m *memStore) Element(id storage.ID) (tree.Element, error) {
+	el, ok := m.elements[id.String()]
+	if !ok {
+		return nil, fmt.Errorf("Unknown element %s", id)
+	}
+	return el, nil
+}
+
+func (m *memStore) Add(el tree.Element) (storage.ID, error) {
+	// Store private copies since the volume modifies its buffers.
+	switch e := el.(type) {
+	case *tree.Directory:
+		el = &tree.Directory{
+			Entries: append([]tree.DirectoryEntry(nil), e.Entries...),
+		}
+	case *tree.SimpleFile:
+		el = &tree.SimpleFile{
+			Data: append([]byte{}, e.Data...),
+		}
+	case *tree.Snapshot:
+		c := *e
+		el = &c
+	}
+	m.adds++
+	id, err := storage.IDFromString(fmt.Sprintf("%064x", m.adds))
+	if err != nil {
+		return id, err
+	}
+	m.elements[id.String()] = el
+	return id, nil
+}
+
+func (m *memStore) Head() storage.ID {
+	return m.head
+}
+
+func (m *memStore) SetHead(id storage.ID) error {
+	m.head = id
+	return nil
+}
+
+func newTestVolume(t *testing.T) (*Volume, *memStore) {
+	st := &memStore{
+		elements: make(map[string]tree.Element),
+	}
+	root, err := st.Add(&tree.Directory{})
+	if err != nil {
+		t.Fatalf("Add failed: %s", err)
+	}
+	head, err := st.Add(&tree.Snapshot{
+		Timestamp: time.Now().UnixNano(),
+		Root:      root,
+	})
+	if err != nil {
+		t.Fatalf("Add failed: %s", err)
+	}
+	st.SetHead(head)
+
+	v, err := newVolume(st)
+	if err != nil {
+		t.Fatalf("newVolume failed: %s", err)
+	}
+	return v, st
+}
+
+func writeFile(t *testing.T, v *Volume, name, data string) {
+	f, err := v.Open(name, O_WRONLY|O_CREAT|O_TRUNC, 0644)
+	if err != nil {
+		t.Fatalf("Open(%s) failed: %s", name, err)
+	}
+	_, err = f.Write([]byte(data))
+	if err != nil {
+		t.Fatalf("Write(%s) failed: %s", name, err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("Close(%s) failed: %s", name, err)
+	}
+}
+
+func readFile(t *testing.T, v *Volume, name string) string {
+	f, err := v.Open(name, O_RDONLY, 0)
+	if err != nil {
+		t.Fatalf("Open(%s) failed: %s", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatalf("Read(%s) failed: %s", name, err)
+	}
+	return string(data)
+}
+
+func TestOpenCreate(t *testing.T) {
+	v, _ := newTestVolume(t)
+	fs := New(NewNamespace(v))
+
+	f, err := OpenFile(fs, "/a", O_RDONLY|O_CREAT, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile(O_RDONLY|O_CREAT) failed: %s", err)
+	}
+	n, err := f.Read(make([]byte, 1))
+	if n != 0 || err != io.EOF {
+		t.Errorf("Read: n=%d, err=%v, expected EOF", n, err)
+	}
+	_, err = f.Write([]byte("x"))
+	if err != errno.EBADF {
+		t.Errorf("Write: got %v, expected EBADF", err)
+	}
+	f.Close()
+
+	info, err := Stat(fs, "/a")
+	if err != nil {
+		t.Fatalf("Stat failed: %s", err)
+	}
+	if info.Size() != 0 || info.Mode().Perm() != 0644 {
+		t.Errorf("Stat: size=%d, mode=%s", info.Size(), info.Mode())
+	}
+
+	_, err = OpenFile(fs, "/a", O_RDONLY|O_TRUNC, 0)
+	if err != errno.EINVAL {
+		t.Errorf("OpenFile(O_RDONLY|O_TRUNC): got %v, expected EINVAL", err)
+	}
+}
+
+func TestWriteCommit(t *testing.T) {
+	v, st := newTestVolume(t)
+
+	f, err := v.Open("/a", O_WRONLY|O_CREAT, 0644)
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	root := v.Root()
+	adds := st.adds
+	for i := 0; i < 100; i++ {
+		_, err = f.Write([]byte("0123456789"))
+		if err != nil {
+			t.Fatalf("Write failed: %s", err)
+		}
+	}
+	if st.adds != adds || v.Root().String() != root.String() {
+		t.Errorf("writes modified volume before close")
+	}
+	if data := readFile(t, v, "/a"); data != "" {
+		t.Errorf("uncommitted content %q", data)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+	if data := readFile(t, v, "/a"); len(data) != 1000 {
+		t.Errorf("content length %d, expected 1000", len(data))
+	}
+
+	// Append without truncating the existing data.
+	f, err = v.Open("/a", O_WRONLY|O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Open failed: %s", err)
+	}
+	f.Write([]byte("!"))
+	f.Close()
+	if data := readFile(t, v, "/a"); len(data) != 1001 || data[1000] != '!' {
+		t.Errorf("append: content length %d", len(data))
+	}
+
+	writeFile(t, v, "/a", "hello")
+	if data := readFile(t, v, "/a"); data != "hello" {
+		t.Errorf("truncate: content %q", data)
+	}
+	err = v.Truncate("/a", 2)
+	if err != nil {
+		t.Fatalf("Truncate failed: %s", err)
+	}
+	if data := readFile(t, v, "/a"); data != "he" {
+		t.Errorf("Truncate: content %q", data)
+	}
+}
+
+func TestRename(t *testing.T) {
+	v, _ := newTestVolume(t)
+	writeFile(t, v, "/a", "hello")
+	err := v.Mkdir("/d", 0755)
+	if err != nil {
+		t.Fatalf("Mkdir failed: %s", err)
+	}
+
+	for _, name := range []string{"/a", "/d", "/d/../d"} {
+		root := v.Root()
+		err = v.Rename(name, name)
+		if err != nil {
+			t.Errorf("Rename(%s, %s) failed: %s", name, name, err)
+		}
+		if v.Root().String() != root.String() {
+			t.Errorf("Rename(%s, %s) modified volume", name, name)
+		}
+	}
+	if data := readFile(t, v, "/a"); data != "hello" {
+		t.Errorf("content after rename to itself: %q", data)
+	}
+
+	err = v.Rename("/d", "/d/e")
+	if !errors.Is(err, errno.EINVAL) {
+		t.Errorf("Rename(/d, /d/e): got %v, expected EINVAL", err)
+	}
+	err = v.Rename("/a", "/d/b")
+	if err != nil {
+		t.Fatalf("Rename failed: %s", err)
+	}
+	if data := readFile(t, v, "/d/b"); data != "hello" {
+		t.Errorf("content after rename: %q", data)
+	}
+	_, err = v.Stat("/a")
+	if !errors.Is(err, errno.ENOENT) {
+		t.Errorf("Stat(/a): got %v, expected ENOENT", err)
+	}
+}
+
+func TestCommitCheckout(t *testing.T) {
+	v, _ := newTestVolume(t)
+
+	writeFile(t, v, "/a", "1")
+	first, err := v.Commit("first")
+	if err != nil {
+		t.Fatalf("Commit failed: %s", err)
+	}
+	writeFile(t, v, "/a", "2")
+	_, err = v.Commit("second")
+	if err != nil {
+		t.Fatalf("Commit failed: %s", err)
+	}
+
+	writeFile(t, v, "/a", "3")
+	err = v.Checkout(first.String(), false)
+	if !errors.Is(err, errno.EBUSY) {
+		t.Errorf("Checkout with changes: got %v, expected EBUSY", err)
+	}
+	if data := readFile(t, v, "/a"); data != "3" {
+		t.Errorf("changes discarded: content %q", data)
+	}
+	err = v.Checkout(first.String(), true)
+	if err != nil {
+		t.Fatalf("Checkout failed: %s", err)
+	}
+	if data := readFile(t, v, "/a"); data != "1" {
+		t.Errorf("checkout: content %q", data)
+	}
+
+	writeFile(t, v, "/a", "4")
+	third, err := v.Commit("third")
+	if err != nil {
+		t.Fatalf("Commit failed: %s", err)
+	}
+	snapshots, err := v.Snapshots()
+	if err != nil {
+		t.Fatalf("Snapshots failed: %s", err)
+	}
+	if len(snapshots) != 3 {
+		t.Fatalf("got %d snapshots, expected 3", len(snapshots))
+	}
+	if snapshots[0].ID.String() != third.String() ||
+		snapshots[0].Parent.String() != first.String() ||
+		snapshots[1].Message != "first" {
+		t.Errorf("unexpected history: %v", snapshots)
+	}
+}
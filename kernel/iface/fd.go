@@ -8,6 +8,7 @@ package iface
 
 import (
 	"io"
+	"sync"
 
 	"github.com/markkurossi/blackbox-os/kernel/errno"
 )
@@ -16,6 +17,7 @@ type FD interface {
 	io.ReadWriteCloser
 	Dup() FD
 	Native() interface{}
+	Name() string
 }
 
 var (
@@ -23,7 +25,9 @@ var (
 )
 
 type FileDesc struct {
+	mutex    sync.Mutex
 	native   interface{}
+	name     string
 	refCount int
 }
 
@@ -43,20 +47,32 @@ func (fd *FileDesc) Write(p []byte) (n int, err error) {
 	return f.Write(p)
 }
 
+// Close decrements the descriptor's reference count. The native
+// object is closed when the last reference is closed.
 func (fd *FileDesc) Close() error {
-	f, ok := fd.native.(io.Closer)
-	if !ok {
+	fd.mutex.Lock()
+	if fd.refCount <= 0 {
+		fd.mutex.Unlock()
 		return errno.EBADF
 	}
 	fd.refCount--
-	if fd.refCount > 0 {
+	last := fd.refCount == 0
+	fd.mutex.Unlock()
+
+	if !last {
+		return nil
+	}
+	f, ok := fd.native.(io.Closer)
+	if !ok {
 		return nil
 	}
 	return f.Close()
 }
 
 func (fd *FileDesc) Dup() FD {
+	fd.mutex.Lock()
 	fd.refCount++
+	fd.mutex.Unlock()
 	return fd
 }
 
@@ -64,9 +80,26 @@ func (fd *FileDesc) Native() interface{} {
 	return fd.native
 }
 
+// Name returns the name of the file which was opened for the
+// descriptor. The name is empty for descriptors which are not opened
+// from the filesystem.
+func (fd *FileDesc) Name() string {
+	return fd.name
+}
+
 func NewFD(native interface{}) FD {
 	return &FileDesc{
 		native:   native,
 		refCount: 1,
 	}
 }
+
+// NewFileFD creates a descriptor for the file opened with the
+// absolute name.
+func NewFileFD(native interface{}, name string) FD {
+	return &FileDesc{
+		native:   native,
+		name:     name,
+		refCount: 1,
+	}
+}
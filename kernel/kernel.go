@@ -18,8 +18,11 @@ import (
 	"github.com/markkurossi/backup/lib/persistence"
 	"github.com/markkurossi/blackbox-os/kernel/control"
 	"github.com/markkurossi/blackbox-os/kernel/fs"
+	"github.com/markkurossi/blackbox-os/kernel/fs/devfs"
+	"github.com/markkurossi/blackbox-os/kernel/fs/tmpfs"
 	"github.com/markkurossi/blackbox-os/kernel/iface"
 	"github.com/markkurossi/blackbox-os/kernel/process"
+	"github.com/markkurossi/blackbox-os/kernel/signal"
 	"github.com/markkurossi/blackbox-os/kernel/tty"
 )
 
@@ -63,21 +66,55 @@ func runInit() error {
 			control.FSZone, err)
 	}
 
+	volume, err := fs.NewVolume(Zone)
+	if err != nil {
+		return fmt.Errorf("Failed to open filesystem volume: %s", err)
+	}
+	ns := fs.NewNamespace(volume)
+	err = ns.Mount(process.ProcRoot, &process.ProcFS{})
+	if err != nil {
+		return fmt.Errorf("Failed to mount %s: %s", process.ProcRoot, err)
+	}
+	err = ns.Mount(process.BinRoot, &process.BinFS{})
+	if err != nil {
+		return fmt.Errorf("Failed to mount %s: %s", process.BinRoot, err)
+	}
+	err = ns.Mount("/dev", devfs.New(console))
+	if err != nil {
+		return fmt.Errorf("Failed to mount /dev: %s", err)
+	}
+	err = ns.Mount("/tmp", tmpfs.New())
+	if err != nil {
+		return fmt.Errorf("Failed to mount /tmp: %s", err)
+	}
+
 	// Run init.
-	process, err := process.New(iface.NewFD(console), iface.NewFD(console),
-		iface.NewFD(console), Zone)
+	p, err := process.New(iface.NewFD(console), iface.NewFD(console),
+		iface.NewFD(console), ns)
 	if err != nil {
 		return fmt.Errorf("Failed to create init process: %s", err)
 	}
-	motd, err := fs.Open(process.FS, "/etc/motd")
+	p.Env = []string{
+		"HOME=/",
+		"LANG=en_US.UTF-8",
+		"PATH=/bin",
+		fmt.Sprintf("PS1=%s", control.ShellPrompt),
+		fmt.Sprintf("USER=%s", control.User),
+	}
+	console.SetSignalHandler(func(pgrp int, sig signal.Signal) {
+		process.KillGroup(pgrp, sig)
+	})
+	console.SetForeground(p.PGID)
+
+	motd, err := fs.Open(p.FS, "/etc/motd")
 	if err != nil {
 		fmt.Fprintf(console, "Black Box OS\n\n")
 	} else {
-		io.Copy(console, motd.Reader())
+		io.Copy(console, motd)
 	}
 
 	fmt.Fprintf(console, "\nType `help' for list of available commands.\n")
-	err = process.Run("sh", []string{})
+	err = p.Run("sh", []string{})
 	if err != nil {
 		return err
 	}
@@ -11,26 +11,110 @@ package kmsg
 import (
 	"fmt"
 	"io"
+	"strings"
+	"sync"
 	"syscall/js"
+
+	"github.com/markkurossi/blackbox-os/kernel/errno"
 )
 
+// BufferSize specifies how many messages are kept in the kernel
+// message buffer.
+const BufferSize = 256
+
 var (
 	console           = js.Global().Get("console")
 	Writer  io.Writer = &writer{}
+
+	mutex    sync.Mutex
+	cond     = sync.NewCond(&mutex)
+	messages []string
+	first    int
 )
 
 type writer struct {
 }
 
 func (w *writer) Write(p []byte) (n int, err error) {
-	console.Call("log", string(p))
+	Print(string(p))
 	return len(p), nil
 }
 
 func Print(msg string) {
 	console.Call("log", msg)
+	record(msg)
 }
 
 func Printf(format string, a ...interface{}) {
-	console.Call("log", fmt.Sprintf(format, a...))
+	Print(fmt.Sprintf(format, a...))
+}
+
+// record adds the message to the kernel message buffer and wakes up
+// the message readers.
+func record(msg string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !strings.HasSuffix(msg, "\n") {
+		msg += "\n"
+	}
+	messages = append(messages, msg)
+	if len(messages) > BufferSize {
+		messages = messages[1:]
+		first++
+	}
+	cond.Broadcast()
+}
+
+// Reader streams the kernel messages. It starts from the oldest
+// buffered message and blocks when all messages have been read.
+type Reader struct {
+	next    int
+	pending []byte
+	closed  bool
+}
+
+// NewReader creates a new kernel message reader.
+func NewReader() *Reader {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	return &Reader{
+		next: first,
+	}
+}
+
+// Read implements the io.Reader interface.
+func (r *Reader) Read(p []byte) (int, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	for len(r.pending) == 0 {
+		if r.closed {
+			return 0, errno.EBADF
+		}
+		if r.next < first {
+			// The messages were dropped from the buffer.
+			r.next = first
+		}
+		if r.next < first+len(messages) {
+			r.pending = []byte(messages[r.next-first])
+			r.next++
+			break
+		}
+		cond.Wait()
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Close closes the reader and wakes up its blocked Read.
+func (r *Reader) Close() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	r.closed = true
+	cond.Broadcast()
+	return nil
 }
@@ -0,0 +1,132 @@
+//
+// pipe.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package pipe
+
+import (
+	"io"
+	"sync"
+
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+)
+
+// BufferSize specifies the default pipe buffer size.
+const BufferSize = 65536
+
+// Pipe implements a unidirectional data channel with a bounded
+// buffer. Reads block until data is available or the write end is
+// closed. Writes block until there is space in the buffer or the
+// read end is closed.
+type Pipe struct {
+	mutex       sync.Mutex
+	cond        *sync.Cond
+	buf         []byte
+	size        int
+	readClosed  bool
+	writeClosed bool
+}
+
+// Reader implements the read end of the pipe.
+type Reader struct {
+	p *Pipe
+}
+
+// Writer implements the write end of the pipe.
+type Writer struct {
+	p *Pipe
+}
+
+// New creates a new pipe with the buffer size. The function returns
+// the read and write ends of the pipe.
+func New(size int) (*Reader, *Writer) {
+	if size <= 0 {
+		size = BufferSize
+	}
+	p := &Pipe{
+		size: size,
+	}
+	p.cond = sync.NewCond(&p.mutex)
+
+	return &Reader{p: p}, &Writer{p: p}
+}
+
+// Read implements the io.Reader interface.
+func (r *Reader) Read(data []byte) (int, error) {
+	p := r.p
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for len(p.buf) == 0 {
+		if p.readClosed {
+			return 0, errno.EBADF
+		}
+		if p.writeClosed {
+			return 0, io.EOF
+		}
+		p.cond.Wait()
+	}
+	n := copy(data, p.buf)
+	p.buf = p.buf[n:]
+	p.cond.Broadcast()
+
+	return n, nil
+}
+
+// Close implements the io.Closer interface.
+func (r *Reader) Close() error {
+	p := r.p
+	p.mutex.Lock()
+	p.readClosed = true
+	p.buf = nil
+	p.cond.Broadcast()
+	p.mutex.Unlock()
+
+	return nil
+}
+
+// Write implements the io.Writer interface. Write returns EPIPE if
+// the read end of the pipe is closed.
+func (w *Writer) Write(data []byte) (int, error) {
+	p := w.p
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var n int
+	for n < len(data) {
+		if p.writeClosed {
+			return n, errno.EBADF
+		}
+		if p.readClosed {
+			return n, errno.EPIPE
+		}
+		avail := p.size - len(p.buf)
+		if avail == 0 {
+			p.cond.Wait()
+			continue
+		}
+		l := len(data) - n
+		if l > avail {
+			l = avail
+		}
+		p.buf = append(p.buf, data[n:n+l]...)
+		n += l
+		p.cond.Broadcast()
+	}
+	return n, nil
+}
+
+// Close implements the io.Closer interface.
+func (w *Writer) Close() error {
+	p := w.p
+	p.mutex.Lock()
+	p.writeClosed = true
+	p.cond.Broadcast()
+	p.mutex.Unlock()
+
+	return nil
+}
@@ -0,0 +1,122 @@
+//
+// pipe_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package pipe
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+)
+
+func buffered(p *Pipe) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.buf)
+}
+
+func TestEOF(t *testing.T) {
+	r, w := New(0)
+
+	_, err := w.Write([]byte("hello"))
+	if err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	w.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %s", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("read %q, expected %q", data, "hello")
+	}
+	n, err := r.Read(make([]byte, 1))
+	if n != 0 || err != io.EOF {
+		t.Errorf("Read after EOF: n=%d, err=%v", n, err)
+	}
+	_, err = w.Write([]byte("x"))
+	if err != errno.EBADF {
+		t.Errorf("Write to closed writer: %v, expected EBADF", err)
+	}
+}
+
+func TestEPIPE(t *testing.T) {
+	r, w := New(0)
+	r.Close()
+
+	n, err := w.Write([]byte("hello"))
+	if n != 0 || err != errno.EPIPE {
+		t.Errorf("Write: n=%d, err=%v, expected EPIPE", n, err)
+	}
+	_, err = r.Read(make([]byte, 1))
+	if err != errno.EBADF {
+		t.Errorf("Read from closed reader: %v, expected EBADF", err)
+	}
+}
+
+func TestBlocking(t *testing.T) {
+	r, w := New(4)
+
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		n, err := w.Write([]byte("0123456789"))
+		done <- result{n, err}
+	}()
+
+	select {
+	case res := <-done:
+		t.Fatalf("Write did not block: n=%d, err=%v", res.n, res.err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	var data []byte
+	buf := make([]byte, 3)
+	for len(data) < 10 {
+		n, err := r.Read(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %s", err)
+		}
+		if n > 4 {
+			t.Fatalf("Read %d bytes from a 4 byte buffer", n)
+		}
+		data = append(data, buf[:n]...)
+	}
+	res := <-done
+	if res.n != 10 || res.err != nil {
+		t.Errorf("Write: n=%d, err=%v", res.n, res.err)
+	}
+	if string(data) != "0123456789" {
+		t.Errorf("read %q", data)
+	}
+
+	// Closing the reader wakes up the blocked writer.
+	go func() {
+		n, err := w.Write([]byte("0123456789"))
+		done <- result{n, err}
+	}()
+	for buffered(r.p) < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	r.Close()
+
+	select {
+	case res := <-done:
+		if res.n != 4 || res.err != errno.EPIPE {
+			t.Errorf("Write: n=%d, err=%v, expected 4, EPIPE", res.n, res.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Write not woken up by reader close")
+	}
+}
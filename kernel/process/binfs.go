@@ -0,0 +1,222 @@
+//
+// binfs.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package process
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/kernel/control"
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+	"github.com/markkurossi/blackbox-os/kernel/fs"
+	"github.com/markkurossi/blackbox-os/kernel/kmsg"
+)
+
+// BinRoot is the mount point of the program filesystem.
+const BinRoot = "/bin"
+
+// binCacheTTL specifies how long the program list is cached.
+const binCacheTTL = time.Minute
+
+var (
+	_ fs.VFS  = &BinFS{}
+	_ fs.File = &BinFile{}
+
+	reProgram = regexp.MustCompilePOSIX(`href="([^"/]+)\.wasm"`)
+)
+
+// BinFS implements a read-only filesystem which lists the programs
+// served from the control.BaseURL.
+type BinFS struct {
+	m        sync.Mutex
+	programs []string
+	fetched  time.Time
+}
+
+// Type implements the fs.VFS.Type().
+func (bfs *BinFS) Type() string {
+	return "bin"
+}
+
+// list returns the sorted program names.
+func (bfs *BinFS) list() ([]string, error) {
+	bfs.m.Lock()
+	defer bfs.m.Unlock()
+
+	if bfs.programs != nil && time.Since(bfs.fetched) < binCacheTTL {
+		return bfs.programs, nil
+	}
+	resp, err := http.Get(fmt.Sprintf("%s/bin/?__t=%d", control.BaseURL,
+		time.Now().Unix()))
+	if err != nil {
+		kmsg.Printf("binfs: %s\n", err)
+		return nil, errno.EIO
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		kmsg.Printf("binfs: %s\n", resp.Status)
+		return nil, errno.EIO
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errno.EIO
+	}
+	programs := []string{}
+	for _, m := range reProgram.FindAllStringSubmatch(string(data), -1) {
+		programs = append(programs, m[1])
+	}
+	sort.Strings(programs)
+
+	bfs.programs = programs
+	bfs.fetched = time.Now()
+
+	return programs, nil
+}
+
+func (bfs *BinFS) open(name string) (*BinFile, error) {
+	name = path.Clean("/" + name)
+	programs, err := bfs.list()
+	if err != nil {
+		return nil, err
+	}
+	if name == "/" {
+		return &BinFile{
+			name:    name,
+			dir:     true,
+			modTime: bfs.fetched,
+			entries: programs,
+		}, nil
+	}
+	base := name[1:]
+	if strings.IndexByte(base, '/') < 0 {
+		idx := sort.SearchStrings(programs, base)
+		if idx < len(programs) && programs[idx] == base {
+			return &BinFile{
+				name:    name,
+				modTime: bfs.fetched,
+			}, nil
+		}
+	}
+	return nil, errno.ENOENT
+}
+
+// Stat implements the fs.VFS.Stat().
+func (bfs *BinFS) Stat(name string) (os.FileInfo, error) {
+	f, err := bfs.open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.Stat()
+}
+
+// Open implements the fs.VFS.Open().
+func (bfs *BinFS) Open(name string, flag int, perm os.FileMode) (
+	fs.File, error) {
+
+	if flag&fs.O_ACCMODE != fs.O_RDONLY || flag&fs.O_CREAT != 0 {
+		return nil, errno.EROFS
+	}
+	return bfs.open(name)
+}
+
+// ReadDir implements the fs.VFS.ReadDir().
+func (bfs *BinFS) ReadDir(name string) ([]os.FileInfo, error) {
+	f, err := bfs.open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !f.dir {
+		return nil, errno.ENOTDIR
+	}
+	var result []os.FileInfo
+	for _, entry := range f.entries {
+		child := &BinFile{
+			name:    path.Join(f.name, entry),
+			modTime: f.modTime,
+		}
+		info, err := child.Stat()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// Mkdir implements the fs.VFS.Mkdir().
+func (bfs *BinFS) Mkdir(name string, perm os.FileMode) error {
+	return errno.EROFS
+}
+
+// Unlink implements the fs.VFS.Unlink().
+func (bfs *BinFS) Unlink(name string) error {
+	return errno.EROFS
+}
+
+// Rmdir implements the fs.VFS.Rmdir().
+func (bfs *BinFS) Rmdir(name string) error {
+	return errno.EROFS
+}
+
+// Rename implements the fs.VFS.Rename().
+func (bfs *BinFS) Rename(from, to string) error {
+	return errno.EROFS
+}
+
+// Truncate implements the fs.VFS.Truncate().
+func (bfs *BinFS) Truncate(name string, size int64) error {
+	return errno.EROFS
+}
+
+// BinFile implements an open file of the program filesystem. The
+// program files have no contents.
+type BinFile struct {
+	name    string
+	dir     bool
+	modTime time.Time
+	entries []string
+}
+
+// Read implements the io.Reader interface.
+func (f *BinFile) Read(p []byte) (int, error) {
+	if f.dir {
+		return 0, errno.EISDIR
+	}
+	return 0, io.EOF
+}
+
+// Write implements the io.Writer interface.
+func (f *BinFile) Write(p []byte) (int, error) {
+	return 0, errno.EBADF
+}
+
+// Close implements the io.Closer interface.
+func (f *BinFile) Close() error {
+	return nil
+}
+
+// Stat implements the fs.File.Stat().
+func (f *BinFile) Stat() (os.FileInfo, error) {
+	mode := os.FileMode(0555)
+	if f.dir {
+		mode |= os.ModeDir
+	}
+	info := fs.NewFileInfo(path.Base(f.name), 0, mode, f.modTime, f)
+	info.SetInode(fs.Inode("bin:"+f.name), 0)
+	return info, nil
+}
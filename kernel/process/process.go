@@ -11,18 +11,19 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"sync"
 	"syscall/js"
 	"time"
 
-	"github.com/markkurossi/backup/lib/crypto/zone"
-	"github.com/markkurossi/backup/lib/tree"
 	"github.com/markkurossi/blackbox-os/kernel/control"
 	"github.com/markkurossi/blackbox-os/kernel/errno"
 	"github.com/markkurossi/blackbox-os/kernel/fs"
 	"github.com/markkurossi/blackbox-os/kernel/iface"
 	"github.com/markkurossi/blackbox-os/kernel/kmsg"
 	"github.com/markkurossi/blackbox-os/kernel/network"
+	"github.com/markkurossi/blackbox-os/kernel/pipe"
+	"github.com/markkurossi/blackbox-os/kernel/signal"
 	"github.com/markkurossi/blackbox-os/kernel/tty"
 )
 
@@ -32,32 +33,44 @@ var (
 	uint8Array    = js.Global().Get("Uint8Array")
 )
 
-var (
-	byID   = make(map[int]*Process)
-	nextID = 0
-)
-
 type Process struct {
 	ID       int
+	PPID     int
+	PGID     int
+	SID      int
+	Argv     []string
+	Start    time.Time
 	mutex    sync.Mutex
 	cond     *sync.Cond
 	exited   bool
 	exitCode int
+	signal   signal.Signal
+	stopped  bool
+	reported bool
+	reaped   bool
+	actions  map[signal.Signal]signal.Action
+	worker   js.Value
+	done     chan error
 	FDs      map[int]iface.FD
 	FS       *fs.FS
-	nextFD   int
+	Env      []string
 }
 
-func New(stdin, stdout, stderr iface.FD, z *zone.Zone) (*Process, error) {
-	fs, err := fs.New(z)
-	if err != nil {
-		return nil, err
-	}
+func New(stdin, stdout, stderr iface.FD, ns *fs.Namespace) (*Process,
+	error) {
+
+	tableMutex.Lock()
+	defer tableMutex.Unlock()
+
 	p := &Process{
-		ID:     nextID,
-		FDs:    make(map[int]iface.FD),
-		FS:     fs,
-		nextFD: 3,
+		ID:      nextID,
+		PGID:    nextID,
+		SID:     nextID,
+		Start:   time.Now(),
+		actions: make(map[signal.Signal]signal.Action),
+		done:    make(chan error, 1),
+		FDs:     make(map[int]iface.FD),
+		FS:      fs.New(ns),
 	}
 	nextID++
 	p.cond = sync.NewCond(&p.mutex)
@@ -77,36 +90,196 @@ func New(stdin, stdout, stderr iface.FD, z *zone.Zone) (*Process, error) {
 	return p, nil
 }
 
+// Exit terminates the process with the exit code.
 func (p *Process) Exit(code int) {
+	p.exit(code, 0)
+}
+
+// exit terminates the process. The sig specifies the signal which
+// caused the termination, or 0 if the process exited normally.
+func (p *Process) exit(code int, sig signal.Signal) {
 	p.cond.L.Lock()
 
+	if p.exited {
+		p.cond.L.Unlock()
+		return
+	}
 	p.exitCode = code
+	p.signal = sig
 	p.exited = true
-	p.cond.Signal()
 
+	fds := p.FDs
+	p.FDs = make(map[int]iface.FD)
+
+	p.cond.Broadcast()
 	p.cond.L.Unlock()
+
+	for fd, f := range fds {
+		err := f.Close()
+		if err != nil {
+			kmsg.Printf("process %d: close %d: %s", p.ID, fd, err)
+		}
+	}
+	p.reapOrphans()
 }
 
-func (p *Process) Wait() int {
+// Wait options.
+const (
+	WNOHANG = 1 << iota
+	WUNTRACED
+)
+
+// WaitStatus describes the state change of a process.
+type WaitStatus struct {
+	Exited  bool
+	Stopped bool
+	Code    int
+	Signal  signal.Signal
+}
+
+// Wait waits for the process to exit. If the options contain
+// WUNTRACED, Wait also returns when the process is stopped. If the
+// options contain WNOHANG, Wait returns immediately with a zero
+// WaitStatus if the process state has not changed.
+func (p *Process) Wait(options int) WaitStatus {
 	p.cond.L.Lock()
-	for !p.exited {
+	defer p.cond.L.Unlock()
+
+	for {
+		if p.exited {
+			return WaitStatus{
+				Exited: true,
+				Code:   p.exitCode,
+				Signal: p.signal,
+			}
+		}
+		if p.stopped && !p.reported && (options&WUNTRACED) != 0 {
+			p.reported = true
+			return WaitStatus{
+				Stopped: true,
+				Signal:  p.signal,
+			}
+		}
+		if (options & WNOHANG) != 0 {
+			return WaitStatus{}
+		}
 		p.cond.Wait()
 	}
-	p.cond.L.Unlock()
-	return p.exitCode
 }
 
+// Pgrp returns the process group of the process.
+func (p *Process) Pgrp() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.PGID
+}
+
+// finish terminates the Run function with the error err.
+func (p *Process) finish(err error) {
+	select {
+	case p.done <- err:
+	default:
+	}
+}
+
+// NewFD allocates the lowest free file descriptor number for impl.
 func (p *Process) NewFD(impl iface.FD) int {
-	fd := p.nextFD
-	p.nextFD++
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.newFD(impl, 0)
+}
+
+// newFD allocates the lowest free file descriptor number, which is
+// greater than or equal to min, for impl. The caller must hold the
+// process mutex.
+func (p *Process) newFD(impl iface.FD, min int) int {
+	fd := min
+	for {
+		_, ok := p.FDs[fd]
+		if !ok {
+			break
+		}
+		fd++
+	}
 	p.FDs[fd] = impl
 	return fd
 }
 
+// GetFD returns the file descriptor fd.
+func (p *Process) GetFD(fd int) (iface.FD, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	f, ok := p.FDs[fd]
+	if !ok {
+		return nil, errno.EBADF
+	}
+	return f, nil
+}
+
+// CloseFD closes the file descriptor fd.
+func (p *Process) CloseFD(fd int) error {
+	p.mutex.Lock()
+	f, ok := p.FDs[fd]
+	if ok {
+		delete(p.FDs, fd)
+	}
+	p.mutex.Unlock()
+
+	if !ok {
+		return errno.EBADF
+	}
+	return f.Close()
+}
+
+// Dup duplicates the file descriptor fd to the lowest free file
+// descriptor number.
+func (p *Process) Dup(fd int) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	f, ok := p.FDs[fd]
+	if !ok {
+		return 0, errno.EBADF
+	}
+	return p.newFD(f.Dup(), 0), nil
+}
+
+// Dup2 duplicates the file descriptor oldfd to newfd. If newfd is
+// open, it is closed first.
+func (p *Process) Dup2(oldfd, newfd int) error {
+	if newfd < 0 {
+		return errno.EBADF
+	}
+	p.mutex.Lock()
+	f, ok := p.FDs[oldfd]
+	if !ok {
+		p.mutex.Unlock()
+		return errno.EBADF
+	}
+	if oldfd == newfd {
+		p.mutex.Unlock()
+		return nil
+	}
+	old, ok := p.FDs[newfd]
+	p.FDs[newfd] = f.Dup()
+	p.mutex.Unlock()
+
+	if ok {
+		return old.Close()
+	}
+	return nil
+}
+
 func (p *Process) Run(cmd string, args []string) error {
 	var worker js.Value
 
-	c := make(chan error)
+	p.mutex.Lock()
+	p.Argv = append([]string{cmd}, args...)
+	p.mutex.Unlock()
+
+	c := p.done
 
 	onSyscall := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		if len(args) != 1 {
@@ -124,7 +297,7 @@ func (p *Process) Run(cmd string, args []string) error {
 		} else {
 			message = args[0].String()
 		}
-		c <- fmt.Errorf("onerror: %s", message)
+		p.finish(fmt.Errorf("onerror: %s", message))
 		return nil
 	})
 
@@ -144,14 +317,27 @@ func (p *Process) Run(cmd string, args []string) error {
 	code := uint8Array.New(len(data))
 	js.CopyBytesToJS(code, data)
 
+	var env []interface{}
+	for _, e := range p.Env {
+		env = append(env, e)
+	}
+
 	argv := []interface{}{
-		onSyscall, onError, p.ID, code, cmd,
+		onSyscall, onError, p.ID, p.PPID, code, env, cmd,
 	}
 	for _, arg := range args {
 		argv = append(argv, arg)
 	}
 
+	p.mutex.Lock()
+	if p.exited {
+		// Killed while loading.
+		p.mutex.Unlock()
+		return nil
+	}
 	worker = syscallSpawn.Invoke(argv...)
+	p.worker = worker
+	p.mutex.Unlock()
 
 	return <-c
 }
@@ -163,6 +349,9 @@ func (p *Process) syscall(c chan error, worker, event js.Value) {
 		return
 	}
 	id := idVal.Int()
+	if !p.waitRunning() {
+		return
+	}
 	err := p.syscallHandler(c, id, worker, event)
 	if err != nil {
 		syscallResult.Invoke(worker, id, err.Error())
@@ -178,14 +367,106 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		if err != nil {
 			return err
 		}
-		f, err := fs.Open(p.FS, filename)
+		flags, err := getInt(event, "flags")
+		if err != nil {
+			return err
+		}
+		mode, err := getInt(event, "mode")
+		if err != nil {
+			return err
+		}
+		filename = p.path(filename)
+		f, err := fs.OpenFile(p.FS, filename, flags, os.FileMode(mode))
 		if err != nil {
 			kmsg.Printf("syscall: open: %s", err)
-			return errno.EINVAL
+			return errno.From(err)
 		}
-		fd := p.NewFD(iface.NewFD(f.Reader()))
+		fd := p.NewFD(iface.NewFileFD(f, filename))
 		syscallResult.Invoke(worker, id, nil, fd)
 
+	case "mkdir":
+		path, err := getString(event, "path")
+		if err != nil {
+			return err
+		}
+		perm, err := getInt(event, "perm")
+		if err != nil {
+			return err
+		}
+		err = fs.Mkdir(p.FS, p.path(path), os.FileMode(perm))
+		if err != nil {
+			kmsg.Printf("syscall: mkdir: %s", err)
+			return errno.From(err)
+		}
+		syscallResult.Invoke(worker, id, nil, 0)
+
+	case "unlink", "rmdir":
+		path, err := getString(event, "path")
+		if err != nil {
+			return err
+		}
+		if event.Get("cmd").String() == "unlink" {
+			err = fs.Unlink(p.FS, p.path(path))
+		} else {
+			err = fs.Rmdir(p.FS, p.path(path))
+		}
+		if err != nil {
+			kmsg.Printf("syscall: %s: %s", event.Get("cmd").String(), err)
+			return errno.From(err)
+		}
+		syscallResult.Invoke(worker, id, nil, 0)
+
+	case "rename":
+		from, err := getString(event, "from")
+		if err != nil {
+			return err
+		}
+		to, err := getString(event, "to")
+		if err != nil {
+			return err
+		}
+		err = fs.Rename(p.FS, p.path(from), p.path(to))
+		if err != nil {
+			kmsg.Printf("syscall: rename: %s", err)
+			return errno.From(err)
+		}
+		syscallResult.Invoke(worker, id, nil, 0)
+
+	case "truncate":
+		path, err := getString(event, "path")
+		if err != nil {
+			return err
+		}
+		length, err := getInt(event, "length")
+		if err != nil {
+			return err
+		}
+		err = fs.Truncate(p.FS, p.path(path), int64(length))
+		if err != nil {
+			kmsg.Printf("syscall: truncate: %s", err)
+			return errno.From(err)
+		}
+		syscallResult.Invoke(worker, id, nil, 0)
+
+	case "ftruncate":
+		f, err := p.getFD(event)
+		if err != nil {
+			return err
+		}
+		length, err := getInt(event, "length")
+		if err != nil {
+			return err
+		}
+		file, ok := f.Native().(interface{ Truncate(size int64) error })
+		if !ok {
+			return errno.EINVAL
+		}
+		err = file.Truncate(int64(length))
+		if err != nil {
+			return errno.From(err)
+		}
+		syscallResult.Invoke(worker, id, nil, 0)
+
 	case "dial":
 		_, err := getString(event, "network")
 		if err != nil {
@@ -230,7 +511,16 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 			return errno.EINVAL
 		}
 
-		n, err := f.Write(data[offset : offset+length])
+		var n int
+		if position, ok := getPosition(event); ok {
+			w, ok := f.Native().(io.WriterAt)
+			if !ok {
+				return errno.ESPIPE
+			}
+			n, err = w.WriteAt(data[offset:offset+length], position)
+		} else {
+			n, err = f.Write(data[offset : offset+length])
+		}
 		if err != nil {
 			return err
 		}
@@ -248,7 +538,21 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		}
 
 		data := make([]byte, length)
-		n, err := f.Read(data)
+		var n int
+		if position, ok := getPosition(event); ok {
+			r, ok := f.Native().(io.ReaderAt)
+			if !ok {
+				return errno.ESPIPE
+			}
+			n, err = r.ReadAt(data, position)
+			if err == io.EOF && n > 0 {
+				err = nil
+			}
+		} else if t, ok := f.Native().(tty.TTY); ok {
+			n, err = p.readTTY(t, data)
+		} else {
+			n, err = f.Read(data)
+		}
 		if err != nil {
 			if err == io.EOF {
 				syscallResult.Invoke(worker, id, nil, 0)
@@ -261,6 +565,73 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		js.CopyBytesToJS(buf, data[:n])
 		syscallResult.Invoke(worker, id, nil, n, buf)
 
+	case "lseek":
+		f, err := p.getFD(event)
+		if err != nil {
+			return err
+		}
+		offset, err := getInt(event, "offset")
+		if err != nil {
+			return err
+		}
+		whence, err := getInt(event, "whence")
+		if err != nil {
+			return err
+		}
+		seeker, ok := f.Native().(io.Seeker)
+		if !ok {
+			return errno.ESPIPE
+		}
+		pos, err := seeker.Seek(int64(offset), whence)
+		if err != nil {
+			return errno.From(err)
+		}
+		syscallResult.Invoke(worker, id, nil, pos)
+
+	case "close":
+		fd, err := getInt(event, "fd")
+		if err != nil {
+			return err
+		}
+		err = p.CloseFD(fd)
+		if err != nil {
+			return errno.From(err)
+		}
+		syscallResult.Invoke(worker, id, nil, 0)
+
+	case "dup":
+		fd, err := getInt(event, "fd")
+		if err != nil {
+			return err
+		}
+		newfd, err := p.Dup(fd)
+		if err != nil {
+			return err
+		}
+		syscallResult.Invoke(worker, id, nil, newfd)
+
+	case "dup2":
+		oldfd, err := getInt(event, "oldfd")
+		if err != nil {
+			return err
+		}
+		newfd, err := getInt(event, "newfd")
+		if err != nil {
+			return err
+		}
+		err = p.Dup2(oldfd, newfd)
+		if err != nil {
+			return errno.From(err)
+		}
+		syscallResult.Invoke(worker, id, nil, newfd)
+
+	case "pipe":
+		r, w := pipe.New(pipe.BufferSize)
+		rfd := p.NewFD(iface.NewFD(r))
+		wfd := p.NewFD(iface.NewFD(w))
+		syscallResult.Invoke(worker, id, nil, 0, nil,
+			js.ValueOf([]interface{}{rfd, wfd}))
+
 	case "fstat":
 		f, err := p.getFD(event)
 		if err != nil {
@@ -277,7 +648,7 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		if err != nil {
 			return err
 		}
-		info, err := p.stat(path)
+		info, err := p.stat(p.path(path))
 		if err != nil {
 			return err
 		}
@@ -292,7 +663,7 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		case "GetFlags":
 			var flags int
 			switch native := f.Native().(type) {
-			case *tty.Console:
+			case tty.TTY:
 				flags = int(native.Flags())
 
 			default:
@@ -307,7 +678,7 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 			}
 
 			switch native := f.Native().(type) {
-			case *tty.Console:
+			case tty.TTY:
 				native.SetFlags(tty.TTYFlags(flags))
 
 			default:
@@ -315,6 +686,87 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 			}
 			syscallResult.Invoke(worker, id, nil, 0)
 
+		case "GetTermios":
+			t, ok := f.Native().(tty.TTY)
+			if !ok {
+				return errno.ENOTTY
+			}
+			termios := t.Termios()
+			var cc []interface{}
+			for _, ch := range termios.Cc {
+				cc = append(cc, int(ch))
+			}
+			syscallResult.Invoke(worker, id, nil, 0, nil,
+				js.ValueOf(map[string]interface{}{
+					"iflag": int(termios.Iflag),
+					"oflag": int(termios.Oflag),
+					"lflag": int(termios.Lflag),
+					"cc":    cc,
+				}))
+
+		case "SetTermios":
+			t, ok := f.Native().(tty.TTY)
+			if !ok {
+				return errno.ENOTTY
+			}
+			iflag, err := getInt(event, "iflag")
+			if err != nil {
+				return err
+			}
+			oflag, err := getInt(event, "oflag")
+			if err != nil {
+				return err
+			}
+			lflag, err := getInt(event, "lflag")
+			if err != nil {
+				return err
+			}
+			cc, err := getIntArray(event, "cc")
+			if err != nil {
+				return err
+			}
+			termios := tty.Termios{
+				Iflag: tty.InputFlags(iflag),
+				Oflag: tty.OutputFlags(oflag),
+				Lflag: tty.TTYFlags(lflag),
+			}
+			for i := 0; i < len(cc) && i < len(termios.Cc); i++ {
+				termios.Cc[i] = byte(cc[i])
+			}
+			t.SetTermios(termios)
+			syscallResult.Invoke(worker, id, nil, 0)
+
+		case "GetPgrp":
+			t, ok := f.Native().(tty.TTY)
+			if !ok {
+				return errno.ENOTTY
+			}
+			syscallResult.Invoke(worker, id, nil, t.Foreground())
+
+		case "GetWinSize":
+			t, ok := f.Native().(tty.TTY)
+			if !ok {
+				return errno.ENOTTY
+			}
+			ch, _ := t.Size()
+			syscallResult.Invoke(worker, id, nil, 0, nil,
+				js.ValueOf(map[string]interface{}{
+					"rows": ch.Y,
+					"cols": ch.X,
+				}))
+
+		case "SetPgrp":
+			pgrp, err := getInt(event, "value")
+			if err != nil {
+				return err
+			}
+			t, ok := f.Native().(tty.TTY)
+			if !ok {
+				return errno.ENOTTY
+			}
+			t.SetForeground(pgrp)
+			syscallResult.Invoke(worker, id, nil, 0)
+
 		default:
 			kmsg.Printf("syscall ioctl: %s not implemented yet\n",
 				event.Get("request").String())
@@ -326,18 +778,14 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		if err != nil {
 			return err
 		}
-		err = p.FS.SetWD(string(path))
+		err = p.FS.SetWD(p.path(string(path)))
 		if err != nil {
 			return err
 		}
 		fallthrough
 
 	case "getwd":
-		wd, _, err := p.FS.WD()
-		if err != nil {
-			return err
-		}
-		data := []byte(wd)
+		data := []byte(p.FS.WD())
 
 		buf := uint8Array.New(len(data))
 		js.CopyBytesToJS(buf, data)
@@ -348,10 +796,10 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		if err != nil {
 			return err
 		}
-		info, err := fs.ReadDir(p.FS, path)
+		info, err := fs.ReadDir(p.FS, p.path(path))
 		if err != nil {
 			kmsg.Printf("syscall: readdir: %s", err)
-			return errno.EINVAL
+			return errno.From(err)
 		}
 		var names []interface{}
 		for _, fi := range info {
@@ -359,6 +807,76 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		}
 		syscallResult.Invoke(worker, id, nil, 0, nil, js.ValueOf(names))
 
+	case "mounts":
+		var result []interface{}
+		for _, m := range p.FS.Namespace().Mounts() {
+			result = append(result, map[string]interface{}{
+				"path": m.Path,
+				"type": m.FS.Type(),
+			})
+		}
+		syscallResult.Invoke(worker, id, nil, len(result), nil,
+			js.ValueOf(result))
+
+	case "snapshots":
+		volume, err := p.FS.Volume()
+		if err != nil {
+			return err
+		}
+		snapshots, err := volume.Snapshots()
+		if err != nil {
+			kmsg.Printf("syscall: snapshots: %s", err)
+			return errno.From(err)
+		}
+		var result []interface{}
+		for _, s := range snapshots {
+			result = append(result, map[string]interface{}{
+				"id":        s.ID.String(),
+				"parent":    s.Parent.String(),
+				"timestamp": s.Timestamp.UnixNano() / int64(time.Millisecond),
+				"message":   s.Message,
+			})
+		}
+		syscallResult.Invoke(worker, id, nil, len(result), nil,
+			js.ValueOf(result))
+
+	case "commit":
+		message, err := getString(event, "message")
+		if err != nil {
+			return err
+		}
+		volume, err := p.FS.Volume()
+		if err != nil {
+			return err
+		}
+		sid, err := volume.Commit(message)
+		if err != nil {
+			kmsg.Printf("syscall: commit: %s", err)
+			return errno.From(err)
+		}
+		data := []byte(sid.String())
+
+		buf := uint8Array.New(len(data))
+		js.CopyBytesToJS(buf, data)
+		syscallResult.Invoke(worker, id, nil, len(data), buf)
+
+	case "checkout":
+		sid, err := getString(event, "id")
+		if err != nil {
+			return err
+		}
+		force := event.Get("force").Truthy()
+		volume, err := p.FS.Volume()
+		if err != nil {
+			return err
+		}
+		err = volume.Checkout(sid, force)
+		if err != nil {
+			kmsg.Printf("syscall: checkout: %s", err)
+			return errno.From(err)
+		}
+		syscallResult.Invoke(worker, id, nil, 0)
+
 	case "spawn":
 		argv, err := getStringArray(event, "argv")
 		if err != nil {
@@ -371,14 +889,37 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		if err != nil {
 			return errno.EINVAL
 		}
-		process, err := New(nil, nil, nil, p.FS.Zone())
+		var env []string
+		if v := event.Get("env"); v.IsUndefined() || v.IsNull() {
+			env = append(env, p.Env...)
+		} else {
+			env, err = getStringArray(event, "env")
+			if err != nil {
+				return err
+			}
+		}
+		process, err := New(nil, nil, nil, p.FS.Namespace())
 		if err != nil {
 			return errno.EINVAL
 		}
+		process.Env = env
+		process.PPID = p.ID
+		process.SID = p.SID
+
+		if v := event.Get("pgid"); v.IsUndefined() || v.IsNull() {
+			process.PGID = p.Pgrp()
+		} else if v.Int() > 0 {
+			if !groupInSession(v.Int(), p.SID) {
+				process.Exit(1)
+				return errno.EPERM
+			}
+			process.PGID = v.Int()
+		}
 
 		for idx, fd := range fds {
-			f, ok := p.FDs[fd]
-			if !ok {
+			f, err := p.GetFD(fd)
+			if err != nil {
+				process.Exit(1)
 				return errno.EINVAL
 			}
 			process.FDs[idx] = f.Dup()
@@ -398,12 +939,125 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		if err != nil {
 			return err
 		}
-		process, ok := byID[pid]
-		if !ok {
-			return errno.ENOENT
+		process, ok := Lookup(pid)
+		if !ok || process.PPID != p.ID {
+			return errno.ECHILD
+		}
+		options, err := getInt(event, "options")
+		if err != nil {
+			options = 0
+		}
+		ws := process.Wait(options)
+		if ws.Exited {
+			process.Reap()
+		}
+		syscallResult.Invoke(worker, id, nil, ws.Code, nil,
+			js.ValueOf(map[string]interface{}{
+				"exited":  ws.Exited,
+				"stopped": ws.Stopped,
+				"signal":  int(ws.Signal),
+			}))
+
+	case "kill":
+		pid, err := getInt(event, "pid")
+		if err != nil {
+			return err
+		}
+		sig, err := getInt(event, "signal")
+		if err != nil {
+			return err
+		}
+		s := signal.Signal(sig)
+		if s != 0 && !s.Valid() {
+			return errno.EINVAL
+		}
+		switch {
+		case pid > 0:
+			process, ok := Lookup(pid)
+			if !ok {
+				return errno.ESRCH
+			}
+			err = process.Kill(s)
+		case pid == 0:
+			err = KillGroup(p.Pgrp(), s)
+		default:
+			err = KillGroup(-pid, s)
+		}
+		if err != nil {
+			return err
+		}
+		syscallResult.Invoke(worker, id, nil, 0)
+
+	case "sigaction":
+		sig, err := getInt(event, "signal")
+		if err != nil {
+			return err
+		}
+		name, err := getString(event, "action")
+		if err != nil {
+			return err
+		}
+		action, err := signal.ParseAction(name)
+		if err != nil {
+			return errno.EINVAL
 		}
-		code := process.Wait()
-		syscallResult.Invoke(worker, id, nil, code)
+		s := signal.Signal(sig)
+		if !s.Valid() || s == signal.SIGKILL || s == signal.SIGSTOP {
+			return errno.EINVAL
+		}
+		p.mutex.Lock()
+		p.actions[s] = action
+		p.mutex.Unlock()
+		syscallResult.Invoke(worker, id, nil, 0)
+
+	case "getpid":
+		syscallResult.Invoke(worker, id, nil, p.ID)
+
+	case "getppid":
+		syscallResult.Invoke(worker, id, nil, p.PPID)
+
+	case "getpgrp":
+		syscallResult.Invoke(worker, id, nil, p.Pgrp())
+
+	case "setpgid":
+		pid, err := getInt(event, "pid")
+		if err != nil {
+			return err
+		}
+		pgid, err := getInt(event, "pgid")
+		if err != nil {
+			return err
+		}
+		// The process can move itself or its children to a new
+		// or to an existing process group in its session.
+		process := p
+		if pid != 0 && pid != p.ID {
+			var ok bool
+			process, ok = Lookup(pid)
+			if !ok || process.PPID != p.ID {
+				return errno.ESRCH
+			}
+			if process.SID != p.SID {
+				return errno.EPERM
+			}
+		}
+		if pgid < 0 {
+			return errno.EINVAL
+		}
+		if pgid == 0 {
+			pgid = process.ID
+		}
+		if process.ID == process.SID {
+			// Session leaders can't change their process group.
+			return errno.EPERM
+		}
+		if pgid != process.ID && !groupInSession(pgid, p.SID) {
+			return errno.EPERM
+		}
+		process.mutex.Lock()
+		process.PGID = pgid
+		process.mutex.Unlock()
+		syscallResult.Invoke(worker, id, nil, 0)
 
 	case "exit":
 		code, err := getInt(event, "code")
@@ -412,7 +1066,7 @@ func (p *Process) syscallHandler(c chan error, id int, worker,
 		}
 		p.Exit(code)
 		syscallResult.Invoke(worker, id, nil, 0)
-		c <- nil
+		p.finish(nil)
 
 	default:
 		kmsg.Printf("syscall: %s: not implemented\n",
@@ -428,11 +1082,7 @@ func (p *Process) getFD(event js.Value) (iface.FD, error) {
 	if err != nil {
 		return nil, err
 	}
-	f, ok := p.FDs[fd]
-	if !ok {
-		return nil, errno.EBADF
-	}
-	return f, nil
+	return p.GetFD(fd)
 }
 
 func getInt(event js.Value, name string) (int, error) {
@@ -446,6 +1096,16 @@ func getInt(event js.Value, name string) (int, error) {
 	}
 }
 
+// getPosition returns the optional file position argument of the read
+// and write calls.
+func getPosition(event js.Value) (int64, bool) {
+	val := event.Get("position")
+	if val.Type() != js.TypeNumber {
+		return 0, false
+	}
+	return int64(val.Float()), true
+}
+
 func getData(event js.Value, name string) ([]byte, error) {
 	val := event.Get(name)
 	if val.IsNull() || val.IsUndefined() {
@@ -507,6 +1167,9 @@ func getIntArray(event js.Value, name string) ([]int, error) {
 	}
 }
 
+// blockSize specifies the preferred I/O block size of the files.
+const blockSize = 4096
+
 func (p *Process) stat(native interface{}) (map[string]interface{}, error) {
 	result := map[string]interface{}{
 		"dev":     0,
@@ -523,41 +1186,63 @@ func (p *Process) stat(native interface{}) (map[string]interface{}, error) {
 		"mtimeMs": 0,
 		"ctimeMs": 0,
 	}
-	_ = result
 
 	switch handle := native.(type) {
-	case *fs.File:
-		switch h := handle.Handle.(type) {
-		case *tree.Directory:
-			result["mode"] = fs.S_IFDIR
-			return result, nil
-
-		default:
-			kmsg.Printf("stat: invalid file: %T", h)
-			return nil, errno.EINVAL
+	case fs.File:
+		info, err := handle.Stat()
+		if err != nil {
+			return nil, errno.From(err)
 		}
+		return statInfo(result, info), nil
 
-	case *tree.SimpleReader:
-		result["size"] = int(handle.Size())
-		result["mode"] = fs.S_IFREG
+	case *pipe.Reader, *pipe.Writer:
+		result["mode"] = fs.S_IFIFO | 0600
+		result["nlink"] = 1
+		result["blksize"] = blockSize
+		return result, nil
+
+	case tty.TTY:
+		result["mode"] = fs.S_IFCHR | 0620
+		result["nlink"] = 1
+		result["uid"] = control.UID
+		result["gid"] = control.UID
+		result["blksize"] = blockSize
 		return result, nil
 
 	case string:
 		info, err := fs.Stat(p.FS, handle)
 		if err != nil {
 			kmsg.Printf("stat: %s: %s", handle, err)
-			return nil, errno.ENOENT
-		}
-		if info.IsDir() {
-			result["mode"] = fs.S_IFDIR
-		} else {
-			result["mode"] = fs.S_IFREG
+			return nil, errno.From(err)
 		}
-		result["size"] = int(info.Size())
-		return result, nil
+		return statInfo(result, info), nil
 
 	default:
 		kmsg.Printf("stat: invalid handle: %T", handle)
 		return nil, errno.EINVAL
 	}
 }
+
+// statInfo fills the stat result from the file information.
+func statInfo(result map[string]interface{},
+	info os.FileInfo) map[string]interface{} {
+
+	mtime := int(info.ModTime().UnixNano() / int64(time.Millisecond))
+
+	result["mode"] = fs.Mode(info.Mode())
+	result["nlink"] = 1
+	result["uid"] = control.UID
+	result["gid"] = control.UID
+	result["size"] = int(info.Size())
+	result["blksize"] = blockSize
+	result["blocks"] = int((info.Size() + 511) / 512)
+	result["atimeMs"] = mtime
+	result["mtimeMs"] = mtime
+	result["ctimeMs"] = mtime
+
+	if si, ok := info.(fs.StatInfo); ok {
+		result["ino"] = int(si.Ino())
+		result["nlink"] = si.Nlink()
+	}
+	return result
+}
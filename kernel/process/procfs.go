@@ -0,0 +1,400 @@
+//
+// procfs.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markkurossi/blackbox-os/kernel/control"
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+	"github.com/markkurossi/blackbox-os/kernel/fs"
+	"github.com/markkurossi/blackbox-os/kernel/iface"
+	"github.com/markkurossi/blackbox-os/kernel/pipe"
+	"github.com/markkurossi/blackbox-os/kernel/tty"
+)
+
+// ProcRoot is the mount point of the synthetic process filesystem.
+const ProcRoot = "/proc"
+
+var (
+	_ fs.VFS  = &ProcFS{}
+	_ fs.File = &ProcFile{}
+)
+
+// ProcFS implements the synthetic process filesystem. The filesystem
+// is read-only.
+type ProcFS struct {
+}
+
+// Type implements the fs.VFS.Type().
+func (pfs *ProcFS) Type() string {
+	return "proc"
+}
+
+// Stat implements the fs.VFS.Stat().
+func (pfs *ProcFS) Stat(name string) (os.FileInfo, error) {
+	f, err := procOpen(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.Stat()
+}
+
+// Open implements the fs.VFS.Open().
+func (pfs *ProcFS) Open(name string, flag int, perm os.FileMode) (
+	fs.File, error) {
+
+	if flag&fs.O_ACCMODE != fs.O_RDONLY || flag&fs.O_CREAT != 0 {
+		return nil, errno.EROFS
+	}
+	return procOpen(name)
+}
+
+// ReadDir implements the fs.VFS.ReadDir().
+func (pfs *ProcFS) ReadDir(name string) ([]os.FileInfo, error) {
+	f, err := procOpen(name)
+	if err != nil {
+		return nil, err
+	}
+	if !f.dir {
+		return nil, errno.ENOTDIR
+	}
+	var result []os.FileInfo
+	for _, entry := range f.entries {
+		child, err := procOpen(path.Join(f.name, entry))
+		if err != nil {
+			// The process exited after the directory was read.
+			continue
+		}
+		info, err := child.Stat()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// Mkdir implements the fs.VFS.Mkdir().
+func (pfs *ProcFS) Mkdir(name string, perm os.FileMode) error {
+	return errno.EROFS
+}
+
+// Unlink implements the fs.VFS.Unlink().
+func (pfs *ProcFS) Unlink(name string) error {
+	return errno.EROFS
+}
+
+// Rmdir implements the fs.VFS.Rmdir().
+func (pfs *ProcFS) Rmdir(name string) error {
+	return errno.EROFS
+}
+
+// Rename implements the fs.VFS.Rename().
+func (pfs *ProcFS) Rename(from, to string) error {
+	return errno.EROFS
+}
+
+// Truncate implements the fs.VFS.Truncate().
+func (pfs *ProcFS) Truncate(name string, size int64) error {
+	return errno.EROFS
+}
+
+// ProcFile implements an open file of the process filesystem.
+type ProcFile struct {
+	name    string
+	dir     bool
+	modTime time.Time
+	entries []string
+	data    []byte
+	reader  *bytes.Reader
+}
+
+// Name returns the file name.
+func (f *ProcFile) Name() string {
+	return f.name
+}
+
+// IsDir tests if the file is a directory.
+func (f *ProcFile) IsDir() bool {
+	return f.dir
+}
+
+// Size returns the file size.
+func (f *ProcFile) Size() int64 {
+	return int64(len(f.data))
+}
+
+// Entries returns the directory entries.
+func (f *ProcFile) Entries() []string {
+	return f.entries
+}
+
+// Read implements the io.Reader interface.
+func (f *ProcFile) Read(p []byte) (int, error) {
+	if f.dir {
+		return 0, errno.EISDIR
+	}
+	return f.reader.Read(p)
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (f *ProcFile) ReadAt(p []byte, off int64) (int, error) {
+	if f.dir {
+		return 0, errno.EISDIR
+	}
+	return f.reader.ReadAt(p, off)
+}
+
+// Seek implements the io.Seeker interface.
+func (f *ProcFile) Seek(offset int64, whence int) (int64, error) {
+	if f.dir {
+		return 0, errno.EISDIR
+	}
+	return f.reader.Seek(offset, whence)
+}
+
+// Write implements the io.Writer interface.
+func (f *ProcFile) Write(p []byte) (int, error) {
+	return 0, errno.EBADF
+}
+
+// Close implements the io.Closer interface.
+func (f *ProcFile) Close() error {
+	return nil
+}
+
+// Stat implements the fs.File.Stat().
+func (f *ProcFile) Stat() (os.FileInfo, error) {
+	mode := os.FileMode(0444)
+	if f.dir {
+		mode = os.ModeDir | 0555
+	}
+	info := fs.NewFileInfo(path.Base(f.name), f.Size(), mode, f.modTime, f)
+	info.SetInode(fs.Inode("proc:"+f.name), 0)
+	return info, nil
+}
+
+// path returns the absolute path of the file name. The `self' entry
+// of the process filesystem is replaced with the process ID.
+func (p *Process) path(name string) string {
+	abs := p.FS.Abs(name)
+	self := ProcRoot + "/self"
+	if abs == self || strings.HasPrefix(abs, self+"/") {
+		return fmt.Sprintf("%s/%d%s", ProcRoot, p.ID, abs[len(self):])
+	}
+	return abs
+}
+
+// procOpen opens the named process filesystem file.
+func procOpen(name string) (*ProcFile, error) {
+	f := &ProcFile{
+		name:    path.Clean("/" + name),
+		modTime: time.Now(),
+	}
+	var parts []string
+	if f.name != "/" {
+		parts = strings.Split(f.name[1:], "/")
+	}
+	if len(parts) == 0 {
+		f.dir = true
+		f.entries = append(f.entries, "self", "sys")
+		for _, proc := range processes() {
+			f.entries = append(f.entries, strconv.Itoa(proc.ID))
+		}
+		sortEntries(f.entries)
+		return f, nil
+	}
+	if parts[0] == "self" {
+		// The self entry is resolved by the process before the
+		// lookup. Here it is visible only in the directory listing.
+		if len(parts) > 1 {
+			return nil, errno.ENOENT
+		}
+		f.dir = true
+		f.entries = []string{"cmdline", "fd", "status"}
+		return f, nil
+	}
+	if parts[0] == "sys" {
+		return sysOpen(f, parts[1:])
+	}
+
+	pid, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, errno.ENOENT
+	}
+	proc, ok := Lookup(pid)
+	if !ok {
+		return nil, errno.ENOENT
+	}
+	f.modTime = proc.Start
+
+	switch len(parts) {
+	case 1:
+		f.dir = true
+		f.entries = []string{"cmdline", "fd", "status"}
+		return f, nil
+
+	case 2:
+		switch parts[1] {
+		case "cmdline":
+			f.data = proc.procCmdline()
+		case "status":
+			f.data = proc.procStatus()
+		case "fd":
+			f.dir = true
+			for fd := range proc.fdTable() {
+				f.entries = append(f.entries, strconv.Itoa(fd))
+			}
+			sortEntries(f.entries)
+			return f, nil
+		default:
+			return nil, errno.ENOENT
+		}
+
+	case 3:
+		if parts[1] != "fd" {
+			return nil, errno.ENOTDIR
+		}
+		fd, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, errno.ENOENT
+		}
+		impl, ok := proc.fdTable()[fd]
+		if !ok {
+			return nil, errno.ENOENT
+		}
+		f.data = []byte(describeFD(impl) + "\n")
+
+	default:
+		return nil, errno.ENOENT
+	}
+	f.reader = bytes.NewReader(f.data)
+
+	return f, nil
+}
+
+// sysOpen opens the kernel control value files of the sys
+// directory. Each control value is a file containing its value.
+func sysOpen(f *ProcFile, parts []string) (*ProcFile, error) {
+	switch len(parts) {
+	case 0:
+		f.dir = true
+		for _, v := range control.Values {
+			f.entries = append(f.entries, v.Name)
+		}
+		sort.Strings(f.entries)
+		return f, nil
+
+	case 1:
+		v, err := control.Var(parts[0])
+		if err != nil {
+			return nil, errno.ENOENT
+		}
+		f.data = []byte(v.Get() + "\n")
+		f.reader = bytes.NewReader(f.data)
+		return f, nil
+
+	default:
+		return nil, errno.ENOTDIR
+	}
+}
+
+// sortEntries sorts the directory entries so that the numeric
+// entries are in numeric order after the named entries.
+func sortEntries(entries []string) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, erra := strconv.Atoi(entries[i])
+		b, errb := strconv.Atoi(entries[j])
+		if erra == nil && errb == nil {
+			return a < b
+		}
+		if erra != nil && errb != nil {
+			return entries[i] < entries[j]
+		}
+		return erra != nil
+	})
+}
+
+func (p *Process) args() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.Argv
+}
+
+func (p *Process) procCmdline() []byte {
+	var buf bytes.Buffer
+	for _, arg := range p.args() {
+		buf.WriteString(arg)
+		buf.WriteByte(0)
+	}
+	return buf.Bytes()
+}
+
+func (p *Process) procStatus() []byte {
+	var buf bytes.Buffer
+
+	var name string
+	if argv := p.args(); len(argv) > 0 {
+		name = argv[0]
+	}
+	state, code := p.State()
+
+	fmt.Fprintf(&buf, "Name:\t%s\n", name)
+	fmt.Fprintf(&buf, "State:\t%s (%s)\n", state.Code(), state)
+	fmt.Fprintf(&buf, "Pid:\t%d\n", p.ID)
+	fmt.Fprintf(&buf, "PPid:\t%d\n", p.PPID)
+	fmt.Fprintf(&buf, "PGid:\t%d\n", p.Pgrp())
+	fmt.Fprintf(&buf, "Sid:\t%d\n", p.SID)
+	fmt.Fprintf(&buf, "Start:\t%s\n", p.Start.Format(time.RFC3339))
+	if state == Zombie || state == Exited {
+		fmt.Fprintf(&buf, "ExitCode:\t%d\n", code)
+	}
+	return buf.Bytes()
+}
+
+// fdTable returns a copy of the process file descriptor table.
+func (p *Process) fdTable() map[int]iface.FD {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	result := make(map[int]iface.FD)
+	for fd, impl := range p.FDs {
+		result[fd] = impl
+	}
+	return result
+}
+
+// describeFD returns a description of the file descriptor.
+func describeFD(fd iface.FD) string {
+	if name := fd.Name(); len(name) > 0 {
+		return name
+	}
+	switch n := fd.Native().(type) {
+	case tty.TTY:
+		return "console"
+	case *pipe.Reader, *pipe.Writer:
+		return "pipe"
+	case net.Conn:
+		return fmt.Sprintf("socket:%s", n.RemoteAddr())
+	case io.Reader, io.Writer:
+		return fmt.Sprintf("%T", n)
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,146 @@
+//
+// signal.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package process
+
+import (
+	"github.com/markkurossi/blackbox-os/kernel/errno"
+	"github.com/markkurossi/blackbox-os/kernel/signal"
+	"github.com/markkurossi/blackbox-os/kernel/tty"
+)
+
+// Kill sends the signal sig to the process. The signal 0 only checks
+// that the process exists.
+//
+// The processes run in Web Workers which can't be suspended. Because
+// of this, the processes are stopped cooperatively: the system calls
+// of a stopped process block until the process is continued.
+func (p *Process) Kill(sig signal.Signal) error {
+	p.mutex.Lock()
+	if p.exited {
+		p.mutex.Unlock()
+		return errno.ESRCH
+	}
+	action := p.actions[sig]
+	worker := p.worker
+	p.mutex.Unlock()
+
+	if sig == 0 {
+		return nil
+	}
+	if sig == signal.SIGCONT {
+		// SIGCONT always continues the process.
+		p.resume()
+	}
+	if sig != signal.SIGKILL && sig != signal.SIGSTOP {
+		switch action {
+		case signal.Ignore:
+			return nil
+
+		case signal.Catch:
+			if !worker.IsUndefined() {
+				worker.Call("postMessage", map[string]interface{}{
+					"cmd":    "signal",
+					"signal": int(sig),
+				})
+				return nil
+			}
+		}
+	}
+
+	switch sig {
+	case signal.SIGCONT, signal.SIGWINCH:
+		return nil
+
+	case signal.SIGSTOP, signal.SIGTSTP, signal.SIGTTIN:
+		p.stop(sig)
+		return nil
+	}
+
+	// The default action terminates the process.
+	if !worker.IsUndefined() {
+		worker.Call("terminate")
+	}
+	p.exit(128+int(sig), sig)
+	p.finish(nil)
+
+	return nil
+}
+
+// KillGroup sends the signal sig to all processes of the process
+// group pgrp.
+func KillGroup(pgrp int, sig signal.Signal) error {
+	var group []*Process
+	for _, p := range processes() {
+		if p.Pgrp() == pgrp {
+			group = append(group, p)
+		}
+	}
+	err := errno.ESRCH
+	for _, p := range group {
+		if p.Kill(sig) == nil {
+			err = nil
+		}
+	}
+	return err
+}
+
+func (p *Process) stop(sig signal.Signal) {
+	p.cond.L.Lock()
+	if !p.stopped && !p.exited {
+		p.stopped = true
+		p.reported = false
+		p.signal = sig
+		p.cond.Broadcast()
+	}
+	p.cond.L.Unlock()
+}
+
+func (p *Process) resume() {
+	p.cond.L.Lock()
+	if p.stopped {
+		p.stopped = false
+		p.signal = 0
+		p.cond.Broadcast()
+	}
+	p.cond.L.Unlock()
+}
+
+// waitRunning waits while the process is stopped. The function
+// returns false if the process exited.
+func (p *Process) waitRunning() bool {
+	p.cond.L.Lock()
+	defer p.cond.L.Unlock()
+
+	for p.stopped && !p.exited {
+		p.cond.Wait()
+	}
+	return !p.exited
+}
+
+// readTTY reads from the terminal t. If the process is not in the
+// terminal's foreground process group, the process group is stopped
+// with SIGTTIN and the read is retried when the process continues.
+func (p *Process) readTTY(t tty.TTY, data []byte) (int, error) {
+	for {
+		n, err := t.ReadGroup(data, p.Pgrp())
+		if err != tty.ErrBackground {
+			return n, err
+		}
+		KillGroup(p.Pgrp(), signal.SIGTTIN)
+
+		p.mutex.Lock()
+		stopped := p.stopped
+		p.mutex.Unlock()
+
+		if !stopped || !p.waitRunning() {
+			// SIGTTIN was ignored or caught, or the process exited.
+			return 0, errno.EIO
+		}
+	}
+}
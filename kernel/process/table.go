@@ -0,0 +1,162 @@
+//
+// table.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package process
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	tableMutex sync.Mutex
+	byID       = make(map[int]*Process)
+	nextID     = 1
+)
+
+// State defines process states.
+type State int
+
+// Process states.
+const (
+	Running State = iota
+	Stopped
+	Zombie
+	Exited
+)
+
+var stateNames = map[State]string{
+	Running: "running",
+	Stopped: "stopped",
+	Zombie:  "zombie",
+	Exited:  "exited",
+}
+
+func (s State) String() string {
+	name, ok := stateNames[s]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{State %d}", s)
+}
+
+// Code returns the single letter code of the state.
+func (s State) Code() string {
+	switch s {
+	case Running:
+		return "R"
+	case Stopped:
+		return "T"
+	case Zombie:
+		return "Z"
+	default:
+		return "X"
+	}
+}
+
+// State returns the process state and its exit code. The exit code
+// is valid only for the Zombie and Exited states.
+func (p *Process) State() (State, int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	switch {
+	case p.reaped:
+		return Exited, p.exitCode
+	case p.exited:
+		return Zombie, p.exitCode
+	case p.stopped:
+		return Stopped, 0
+	default:
+		return Running, 0
+	}
+}
+
+// Lookup finds the process by its process ID.
+func Lookup(pid int) (*Process, bool) {
+	tableMutex.Lock()
+	defer tableMutex.Unlock()
+
+	p, ok := byID[pid]
+	return p, ok
+}
+
+// Processes returns all processes in the process table, sorted by
+// their process IDs.
+func Processes() []*Process {
+	result := processes()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+func processes() []*Process {
+	tableMutex.Lock()
+	defer tableMutex.Unlock()
+
+	result := make([]*Process, 0, len(byID))
+	for _, p := range byID {
+		result = append(result, p)
+	}
+	return result
+}
+
+// groupInSession tests if the process group pgrp has processes in
+// the session sid.
+func groupInSession(pgrp, sid int) bool {
+	for _, p := range processes() {
+		if p.SID == sid && p.Pgrp() == pgrp {
+			return true
+		}
+	}
+	return false
+}
+
+// Reap removes the exited process from the process table.
+func (p *Process) Reap() {
+	tableMutex.Lock()
+	defer tableMutex.Unlock()
+
+	p.reap()
+}
+
+// reap removes the process from the process table. The caller must
+// hold the table mutex.
+func (p *Process) reap() {
+	p.mutex.Lock()
+	p.reaped = true
+	p.mutex.Unlock()
+
+	delete(byID, p.ID)
+}
+
+func (p *Process) isExited() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.exited
+}
+
+// reapOrphans is called when the process exits. It reaps the process
+// if its parent has already exited since there is no one to wait for
+// it. It also reaps all exited children of the process.
+func (p *Process) reapOrphans() {
+	tableMutex.Lock()
+	defer tableMutex.Unlock()
+
+	parent, ok := byID[p.PPID]
+	if !ok || parent.isExited() {
+		p.reap()
+	}
+	for _, child := range byID {
+		if child.PPID == p.ID && child.isExited() {
+			child.reap()
+		}
+	}
+}
@@ -0,0 +1,89 @@
+//
+// signal.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package signal
+
+import (
+	"fmt"
+)
+
+// Signal defines process signals.
+type Signal int
+
+// Signals.
+const (
+	SIGHUP   Signal = 1
+	SIGINT   Signal = 2
+	SIGKILL  Signal = 9
+	SIGTERM  Signal = 15
+	SIGCONT  Signal = 18
+	SIGSTOP  Signal = 19
+	SIGTSTP  Signal = 20
+	SIGTTIN  Signal = 21
+	SIGWINCH Signal = 28
+)
+
+var signalNames = map[Signal]string{
+	SIGHUP:   "SIGHUP",
+	SIGINT:   "SIGINT",
+	SIGKILL:  "SIGKILL",
+	SIGTERM:  "SIGTERM",
+	SIGCONT:  "SIGCONT",
+	SIGSTOP:  "SIGSTOP",
+	SIGTSTP:  "SIGTSTP",
+	SIGTTIN:  "SIGTTIN",
+	SIGWINCH: "SIGWINCH",
+}
+
+func (s Signal) String() string {
+	name, ok := signalNames[s]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{Signal %d}", s)
+}
+
+// Valid tests if the signal is a known signal.
+func (s Signal) Valid() bool {
+	_, ok := signalNames[s]
+	return ok
+}
+
+// Action defines how a process handles a signal.
+type Action int
+
+// Signal actions.
+const (
+	Default Action = iota
+	Ignore
+	Catch
+)
+
+var actionNames = map[Action]string{
+	Default: "default",
+	Ignore:  "ignore",
+	Catch:   "catch",
+}
+
+func (a Action) String() string {
+	name, ok := actionNames[a]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{Action %d}", a)
+}
+
+// ParseAction parses the signal action name.
+func ParseAction(name string) (Action, error) {
+	for a, n := range actionNames {
+		if n == name {
+			return a, nil
+		}
+	}
+	return Default, fmt.Errorf("unknown signal action '%s'", name)
+}
@@ -15,16 +15,19 @@ import (
 	"image/color"
 	"sync"
 	"syscall/js"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
 	"github.com/markkurossi/blackbox-os/kernel/control"
 	"github.com/markkurossi/blackbox-os/kernel/kmsg"
+	"github.com/markkurossi/blackbox-os/kernel/signal"
 	"github.com/markkurossi/vt100"
 )
 
 var (
 	initKeyboard = js.Global().Get("initKeyboard")
+	initResize   = js.Global().Get("initResize")
 	display      = js.Global().Get("display")
 	lineNew      = js.Global().Get("Line")
 	debug        = js.Global().Get("debug")
@@ -38,7 +41,6 @@ type KeyType int
 
 var keyTypeNames = map[KeyType]string{
 	KeyCode:        "Code",
-	KeyEnter:       "Enter",
 	KeyCursorUp:    "CursorUp",
 	KeyCursorDown:  "CursorDown",
 	KeyCursorLeft:  "CursorLeft",
@@ -47,6 +49,7 @@ var keyTypeNames = map[KeyType]string{
 	KeyPageDown:    "PageDown",
 	KeyHome:        "Home",
 	KeyEnd:         "End",
+	KeyDelete:      "Delete",
 }
 
 func (t KeyType) String() string {
@@ -59,7 +62,6 @@ func (t KeyType) String() string {
 
 const (
 	KeyCode KeyType = iota
-	KeyEnter
 	KeyCursorUp
 	KeyCursorDown
 	KeyCursorLeft
@@ -68,17 +70,19 @@ const (
 	KeyPageDown
 	KeyHome
 	KeyEnd
+	KeyDelete
 )
 
 type Console struct {
-	flags       TTYFlags
+	termios     Termios
 	qCanon      *Canonical
 	qNonCanon   []byte
 	cond        *sync.Cond
 	encodingBuf []byte
-	lastRune    rune
 	emulator    *vt100.Emulator
 	display     *vt100.Display
+	foreground  int
+	onSignal    SignalHandler
 }
 
 // Canonical provides canonical input mode with Emacs-like line
@@ -88,12 +92,82 @@ type Canonical struct {
 	cursor int
 	tail   int
 	avail  []byte
+	eof    bool
 }
 
+// input processes the input key. The erase, kill, and end-of-file
+// characters are defined by the console's termios control
+// characters. The Emacs-like editing keys are enabled with the
+// IEXTEN local mode. The function returns true if input is available
+// for reading.
 func (in *Canonical) input(c *Console, kt KeyType, code rune) bool {
+	t := &c.termios
+	extended := t.Lflag&IEXTEN != 0
+
+	if kt == KeyCode {
+		switch {
+		case code == '\n':
+			in.newline()
+			return true
+
+		case t.isControl(VEOF, code):
+			if in.tail == 0 {
+				// End-of-file.
+				in.eof = true
+				return true
+			}
+			if extended && in.cursor < in.tail {
+				// Delete character at cursor.
+				c.Echo([]int{0x1b, '[', 'P'})
+				in.cursor++
+				in.delete()
+				return false
+			}
+			// Send the line without the newline.
+			in.flush()
+			return true
+
+		case t.isControl(VERASE, code):
+			if in.cursor == 0 {
+				return false
+			}
+			if t.Lflag&ECHOE != 0 {
+				c.Echo([]int{0x08}) // Backspace
+				if in.cursor == in.tail {
+					c.Echo([]int{0x1b, '[', 'K'}) // Erase line from cursor
+				} else {
+					c.Echo([]int{0x1b, '[', 'P'}) // Delete character
+				}
+			}
+			in.delete()
+			return false
+
+		case t.isControl(VKILL, code):
+			if t.Lflag&ECHOE != 0 {
+				for ; in.cursor > 0; in.cursor-- {
+					c.Echo([]int{0x08})
+				}
+				c.Echo([]int{0x1b, '[', 'K'})
+			} else {
+				c.Echo(controlEcho(code))
+				if t.Lflag&ECHOK != 0 {
+					c.Echo([]int{'\r', '\n'})
+				}
+			}
+			in.cursor = 0
+			in.tail = 0
+			return false
+		}
+	} else if !extended {
+		return false
+	}
 
 	switch kt {
 	case KeyCode:
+		if !extended && !unicode.IsPrint(code) {
+			kmsg.Printf("console: skipping non-printable 0x%x\n", code)
+			break
+		}
 		switch code {
 		case 0x01: // C-a
 			for in.cursor > 0 {
@@ -104,14 +178,6 @@ func (in *Canonical) input(c *Console, kt KeyType, code rune) bool {
 		case 0x02: // C-b
 			in.cursorLeft(c)
 
-		case 0x04: // C-d
-			if in.cursor == in.tail {
-				break
-			}
-			c.Echo([]int{0x1b, '[', 'P'})
-			in.cursor++
-			in.delete()
-
 		case 0x05: // C-e
 			for in.cursor < in.tail {
 				c.Echo([]int{0x1b, '[', 'C'})
@@ -128,25 +194,7 @@ func (in *Canonical) input(c *Console, kt KeyType, code rune) bool {
 		case 0x0c: // C-l
 			c.Echo([]int{0x1b, '[', 'J'})
 
-		case 0x7f: // Delete
-			if in.cursor == 0 {
-				break
-			}
-
-			c.Echo([]int{0x08}) // Backspace
-			if in.cursor == in.tail {
-				c.Echo([]int{0x1b, '[', 'K'}) // Erase line from cursor
-			} else {
-				c.Echo([]int{0x1b, '[', 'P'}) // Delete character
-			}
-
-			in.delete()
-
 		default:
-			if code == '\n' {
-				in.newline()
-				return true
-			}
 			if unicode.IsPrint(rune(code)) {
 				if in.insert(code) {
 					// Print line.
@@ -163,10 +211,6 @@ func (in *Canonical) input(c *Console, kt KeyType, code rune) bool {
 			}
 		}
 
-	case KeyEnter:
-		in.newline()
-		return true
-
 	case KeyCursorLeft:
 		in.cursorLeft(c)
 
@@ -177,12 +221,25 @@ func (in *Canonical) input(c *Console, kt KeyType, code rune) bool {
 }
 
 func (in *Canonical) newline() {
-	in.avail = append(in.avail, []byte(string(in.buf[:in.tail]))...)
+	in.flush()
 	in.avail = append(in.avail, '\n')
+}
+
+// flush makes the line available for reading.
+func (in *Canonical) flush() {
+	in.avail = append(in.avail, []byte(string(in.buf[:in.tail]))...)
 	in.cursor = 0
 	in.tail = 0
 }
 
+// reset discards the line and all pending input.
+func (in *Canonical) reset() {
+	in.cursor = 0
+	in.tail = 0
+	in.avail = nil
+	in.eof = false
+}
+
 func (in *Canonical) cursorLeft(c *Console) {
 	if in.cursor > 0 {
 		c.Echo([]int{0x08})
@@ -203,7 +260,7 @@ func (in *Canonical) insert(ch rune) bool {
 	}
 
 	if in.cursor < in.tail {
-		for i := in.tail + 1; i > in.cursor; i-- {
+		for i := in.tail; i > in.cursor; i-- {
 			in.buf[i] = in.buf[i-1]
 		}
 	}
@@ -221,7 +278,8 @@ func (in *Canonical) delete() {
 		in.tail--
 	} else {
 		in.cursor--
-		in.buf = append(in.buf[0:in.cursor], in.buf[in.cursor+1:]...)
+		copy(in.buf[in.cursor:], in.buf[in.cursor+1:in.tail])
+		in.tail--
 	}
 }
 
@@ -231,23 +289,89 @@ func NewCanonical() *Canonical {
 	}
 }
 
+// Flags returns the console's local modes.
 func (c *Console) Flags() TTYFlags {
-	return c.flags
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+	return c.termios.Lflag
 }
 
+// SetFlags sets the console's local modes.
 func (c *Console) SetFlags(flags TTYFlags) {
-	c.flags = flags
+	c.cond.L.Lock()
+	c.termios.Lflag = flags
+	c.cond.Broadcast()
+	c.cond.L.Unlock()
+}
+
+// Termios returns the console's terminal settings.
+func (c *Console) Termios() Termios {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+	return c.termios
+}
+
+// SetTermios sets the console's terminal settings.
+func (c *Console) SetTermios(t Termios) {
+	c.cond.L.Lock()
+	c.termios = t
+	c.cond.Broadcast()
+	c.cond.L.Unlock()
+}
+
+// Foreground returns the foreground process group of the console.
+func (c *Console) Foreground() int {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+	return c.foreground
+}
+
+// SetForeground sets the foreground process group of the console.
+func (c *Console) SetForeground(pgrp int) {
+	c.cond.L.Lock()
+	c.foreground = pgrp
+	c.cond.Broadcast()
+	c.cond.L.Unlock()
+}
+
+// SetSignalHandler sets the handler which delivers the signals
+// generated by the console.
+func (c *Console) SetSignalHandler(h SignalHandler) {
+	c.cond.L.Lock()
+	c.onSignal = h
+	c.cond.L.Unlock()
+}
+
+// signal discards all pending input and sends the signal to the
+// foreground process group. The caller must hold the console lock.
+func (c *Console) signal(sig signal.Signal, echo []int) {
+	if (c.termios.Lflag & ICANON) != 0 {
+		c.qCanon.reset()
+		c.Echo(echo)
+		c.Echo([]int{'\r', '\n'})
+	}
+	c.qNonCanon = nil
+
+	if c.onSignal != nil && c.foreground > 0 {
+		go c.onSignal(c.foreground, sig)
+	}
 }
 
 func (c *Console) Cursor() vt100.Point {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
 	return c.emulator.Cursor
 }
 
 func (c *Console) Size() (vt100.Point, vt100.Point) {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
 	return c.emulator.Size, c.emulator.Size
 }
 
 func (c *Console) String() string {
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
 	return fmt.Sprintf("Console (%s)", c.emulator.Size)
 }
 
@@ -255,6 +379,47 @@ func (c *Console) DisplaySize() (int, int) {
 	return display.Get("width").Int(), display.Get("height").Int()
 }
 
+// Resize resizes the console to width columns and height rows. The
+// console contents are kept so that the cursor row remains visible.
+// The foreground process group is notified with SIGWINCH.
+func (c *Console) Resize(width, height int) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
+
+	size := c.emulator.Size
+	if size.X == width && size.Y == height {
+		return
+	}
+	lines := c.display.Lines
+	cursor := c.emulator.Cursor
+
+	var shift int
+	if cursor.Y >= height {
+		shift = cursor.Y - height + 1
+	}
+
+	*c.display = *vt100.NewDisplay(width, height)
+	c.emulator.Reset()
+	for row := 0; row < height && row+shift < size.Y; row++ {
+		copy(c.display.Lines[row], lines[row+shift])
+	}
+	cursor.Y -= shift
+	if cursor.X >= width {
+		cursor.X = width - 1
+	}
+	c.emulator.Cursor = cursor
+	c.Flush()
+
+	kmsg.Printf("console: resized to %dx%d\n", width, height)
+
+	if c.onSignal != nil && c.foreground > 0 {
+		go c.onSignal(c.foreground, signal.SIGWINCH)
+	}
+}
+
 func (c *Console) Flush() error {
 	display.Call("clear")
 
@@ -286,34 +451,99 @@ func nrgbaToInt(c color.NRGBA) int {
 
 // Read implements the io.Reader interface.
 func (c *Console) Read(p []byte) (int, error) {
+	return c.read(p, 0)
+}
+
+// ReadGroup reads input for a process in the process group pgrp. The
+// function returns ErrBackground if pgrp is not the console's
+// foreground process group.
+func (c *Console) ReadGroup(p []byte, pgrp int) (int, error) {
+	return c.read(p, pgrp)
+}
+
+// read reads input. In the canonical mode, the read returns when a
+// line is available, or 0 bytes for end-of-file. In the non-canonical
+// mode, the read follows the VMIN and VTIME control characters: the
+// read returns when VMIN bytes are available, or when the VTIME
+// timer, in tenths of seconds, expires. If VTIME is set and VMIN is
+// 0, the timer starts when the read is called, otherwise it is an
+// inter-byte timer which restarts whenever a byte is received.
+func (c *Console) read(p []byte, pgrp int) (int, error) {
 	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
 
-	var n int
+	var timer *time.Timer
+	var timerGen int
+	var timerAvail int
+	var expired bool
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
 
-	if (c.flags & ICANON) != 0 {
-		for len(c.qCanon.avail) == 0 {
-			c.cond.Wait()
+	for {
+		if pgrp != 0 && pgrp != c.foreground {
+			return 0, ErrBackground
 		}
-		n = copy(p, c.qCanon.avail)
-		c.qCanon.avail = c.qCanon.avail[n:]
-	} else {
-		for len(c.qNonCanon) == 0 {
-			c.cond.Wait()
+		if (c.termios.Lflag & ICANON) != 0 {
+			if len(c.qCanon.avail) > 0 {
+				n := copy(p, c.qCanon.avail)
+				c.qCanon.avail = c.qCanon.avail[n:]
+				return n, nil
+			}
+			if c.qCanon.eof {
+				c.qCanon.eof = false
+				return 0, nil
+			}
+		} else {
+			min := int(c.termios.Cc[VMIN])
+			if min > len(p) {
+				min = len(p)
+			}
+			timeout := time.Duration(c.termios.Cc[VTIME]) *
+				100 * time.Millisecond
+			avail := len(c.qNonCanon)
+
+			if (min > 0 && avail >= min) || (min == 0 && avail > 0) ||
+				(min == 0 && timeout == 0) || expired {
+				n := copy(p, c.qNonCanon)
+				c.qNonCanon = c.qNonCanon[n:]
+				return n, nil
+			}
+			if timeout > 0 && (min == 0 || avail > 0) &&
+				(timer == nil || (min > 0 && avail != timerAvail)) {
+				if timer != nil {
+					timer.Stop()
+				}
+				// The generation ignores the stopped timers which
+				// fired before they were stopped.
+				timerGen++
+				gen := timerGen
+				timerAvail = avail
+				timer = time.AfterFunc(timeout, func() {
+					c.cond.L.Lock()
+					if gen == timerGen {
+						expired = true
+						c.cond.Broadcast()
+					}
+					c.cond.L.Unlock()
+				})
+			}
 		}
-		n = copy(p, c.qNonCanon)
-		c.qNonCanon = c.qNonCanon[n:]
+		c.cond.Wait()
 	}
-
-	c.cond.L.Unlock()
-
-	return n, nil
 }
 
-// Write implements the io.Writer interface.
+// Write implements the io.Writer interface. The output modes and
+// the emulator are shared with the input processing and Resize so the
+// output is processed holding the console lock.
 func (c *Console) Write(p []byte) (int, error) {
 	if false {
 		kmsg.Printf("Console.Write:\n%s", hex.Dump(p))
 	}
+	c.cond.L.Lock()
+	defer c.cond.L.Unlock()
 
 	c.encodingBuf = append(c.encodingBuf, p...)
 
@@ -323,11 +553,15 @@ func (c *Console) Write(p []byte) (int, error) {
 		if r == utf8.RuneError {
 			break
 		}
-		if r == '\n' && c.lastRune != '\r' {
-			c.emulator.Input('\r')
+		if c.termios.Oflag&OPOST != 0 {
+			switch {
+			case r == '\n' && c.termios.Oflag&ONLCR != 0:
+				c.emulator.Input('\r')
+			case r == '\r' && c.termios.Oflag&OCRNL != 0:
+				r = '\n'
+			}
 		}
 		c.emulator.Input(int(r))
-		c.lastRune = r
 	}
 
 	c.Flush()
@@ -335,13 +569,14 @@ func (c *Console) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
-func (c *Console) OnKeyEvent(evType, key string, keyCode int, ctrl bool) {
+func (c *Console) OnKeyEvent(evType, key string, keyCode int,
+	ctrl, alt bool) {
 	if evType != "keydown" {
 		return
 	}
 	if false {
-		kmsg.Printf("%s: key=%s, keyCode=%d, ctrlKey=%v\n",
-			evType, key, keyCode, ctrl)
+		kmsg.Printf("%s: key=%s, keyCode=%d, ctrlKey=%v, altKey=%v\n",
+			evType, key, keyCode, ctrl, alt)
 	}
 
 	runes := []rune(key)
@@ -357,13 +592,22 @@ func (c *Console) OnKeyEvent(evType, key string, keyCode int, ctrl bool) {
 				code = 0x00
 			}
 		}
+		if alt {
+			// Meta keys are sent with the ESC prefix.
+			c.onKey(KeyCode, rune(0x1b))
+		}
 		c.onKey(KeyCode, code)
 	} else {
 		switch key {
 		case "Enter":
-			c.onKey(KeyEnter, 0)
+			c.onKey(KeyCode, '\r')
 		case "Backspace":
+			if alt {
+				c.onKey(KeyCode, rune(0x1b))
+			}
 			c.onKey(KeyCode, rune(0x7f))
+		case "Delete":
+			c.onKey(KeyDelete, 0)
 		case "Tab":
 			c.onKey(KeyCode, rune(0x09))
 		case "Escape":
@@ -395,11 +639,43 @@ func (c *Console) OnKeyEvent(evType, key string, keyCode int, ctrl bool) {
 func (c *Console) onKey(kt KeyType, code rune) {
 	c.cond.L.Lock()
 	defer c.cond.L.Unlock()
+	c.input(kt, code)
+}
 
-	if (c.flags & ICANON) != 0 {
+// input processes the input key. The caller must hold the console
+// lock.
+func (c *Console) input(kt KeyType, code rune) {
+	t := &c.termios
+
+	if kt == KeyCode {
+		if t.Lflag&ISIG != 0 {
+			switch {
+			case t.isControl(VINTR, code):
+				c.signal(signal.SIGINT, controlEcho(code))
+				return
+
+			case t.isControl(VSUSP, code):
+				c.signal(signal.SIGTSTP, controlEcho(code))
+				return
+			}
+		}
+		switch {
+		case code == '\r' && t.Iflag&IGNCR != 0:
+			return
+		case code == '\r' && t.Iflag&ICRNL != 0:
+			code = '\n'
+		case code == '\n' && t.Iflag&INLCR != 0:
+			code = '\r'
+		}
+	}
+
+	if (t.Lflag & ICANON) != 0 {
 		if c.qCanon.input(c, kt, code) {
-			c.emulator.Input('\r')
-			c.emulator.Input('\n')
+			if code == '\n' && t.Lflag&(ECHO|ECHONL) != 0 {
+				c.emulator.Input('\r')
+				c.emulator.Input('\n')
+				c.Flush()
+			}
 			c.cond.Broadcast()
 		}
 	} else {
@@ -409,9 +685,6 @@ func (c *Console) onKey(kt KeyType, code rune) {
 		case KeyCode:
 			input.Write([]byte(string(code)))
 
-		case KeyEnter:
-			input.Write([]byte{'\r'})
-
 		case KeyCursorUp:
 			vt100.CursorUp(input)
 
@@ -430,17 +703,24 @@ func (c *Console) onKey(kt KeyType, code rune) {
 		case KeyPageDown:
 			vt100.ScrollDown(input)
 
-		case KeyHome, KeyEnd:
-			kmsg.Printf("onKey: %s not supported", kt)
-			return
+		case KeyHome:
+			input.WriteString("\x1b[1~")
+
+		case KeyEnd:
+			input.WriteString("\x1b[4~")
+
+		case KeyDelete:
+			input.WriteString("\x1b[3~")
 		}
 		c.qNonCanon = append(c.qNonCanon, input.Bytes()...)
 		c.cond.Broadcast()
 	}
 }
 
+// Echo echoes the codes if the ECHO local mode is set. The caller
+// must hold the console lock.
 func (c *Console) Echo(code []int) {
-	if (c.flags & ECHO) != 0 {
+	if (c.termios.Lflag & ECHO) != 0 {
 		for _, co := range code {
 			c.emulator.Input(co)
 		}
@@ -452,19 +732,20 @@ type inputWriter struct {
 	c *Console
 }
 
-// Write implements the io.Writer interface.
+// Write implements the io.Writer interface. The emulator writes its
+// responses while the console lock is held.
 func (iw *inputWriter) Write(p []byte) (int, error) {
 	for _, r := range string(p) {
-		iw.c.onKey(KeyCode, r)
+		iw.c.input(KeyCode, r)
 	}
 	return len(p), nil
 }
 
 func NewConsole() TTY {
 	c := &Console{
-		flags:  ICANON | ECHO,
-		qCanon: NewCanonical(),
-		cond:   sync.NewCond(new(sync.Mutex)),
+		termios: DefaultTermios(),
+		qCanon:  NewCanonical(),
+		cond:    sync.NewCond(new(sync.Mutex)),
 	}
 	c.display = vt100.NewDisplay(c.DisplaySize())
 	c.emulator = vt100.NewEmulator(&inputWriter{
@@ -481,7 +762,8 @@ func NewConsole() TTY {
 		key := event.Get("key").String()
 		keyCode := event.Get("keyCode").Int()
 		ctrlKey := event.Get("ctrlKey").Bool()
-		c.OnKeyEvent(evType, key, keyCode, ctrlKey)
+		altKey := event.Get("altKey").Bool()
+		c.OnKeyEvent(evType, key, keyCode, ctrlKey, altKey)
 
 		event.Call("stopPropagation")
 		event.Call("preventDefault")
@@ -491,5 +773,16 @@ func NewConsole() TTY {
 
 	initKeyboard.Invoke(onKeyboard)
 
+	onResize := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) < 2 {
+			kmsg.Printf("Invalid resize arguments: %v\n", args)
+			return nil
+		}
+		c.Resize(args[0].Int(), args[1].Int())
+		return nil
+	})
+
+	initResize.Invoke(onResize)
+
 	return c
 }
@@ -0,0 +1,82 @@
+//
+// termios.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package tty
+
+// InputFlags define the terminal input modes.
+type InputFlags uint
+
+// Input modes.
+const (
+	ICRNL InputFlags = 1 << iota
+	INLCR
+	IGNCR
+)
+
+// OutputFlags define the terminal output modes.
+type OutputFlags uint
+
+// Output modes.
+const (
+	OPOST OutputFlags = 1 << iota
+	ONLCR
+	OCRNL
+)
+
+// Indices of the control characters.
+const (
+	VINTR = iota
+	VERASE
+	VKILL
+	VEOF
+	VSUSP
+	VMIN
+	VTIME
+	NCCS
+)
+
+// Termios defines the terminal settings. The Lflag contains the
+// local modes which are also available with the TTY.Flags() and
+// TTY.SetFlags() functions. The control character value 0 disables
+// the control character.
+type Termios struct {
+	Iflag InputFlags
+	Oflag OutputFlags
+	Lflag TTYFlags
+	Cc    [NCCS]byte
+}
+
+// DefaultTermios returns the default terminal settings: canonical
+// input with echo, signals, and the output newline translation.
+func DefaultTermios() Termios {
+	t := Termios{
+		Iflag: ICRNL,
+		Oflag: OPOST | ONLCR,
+		Lflag: ICANON | ECHO | ECHOE | ECHOK | ISIG | IEXTEN,
+	}
+	t.Cc[VINTR] = 0x03 // C-c
+	t.Cc[VERASE] = 0x7f
+	t.Cc[VKILL] = 0x15 // C-u
+	t.Cc[VEOF] = 0x04  // C-d
+	t.Cc[VSUSP] = 0x1a // C-z
+	t.Cc[VMIN] = 1
+	t.Cc[VTIME] = 0
+
+	return t
+}
+
+// isControl tests if the code is the control character idx.
+func (t *Termios) isControl(idx int, code rune) bool {
+	return t.Cc[idx] != 0 && rune(t.Cc[idx]) == code
+}
+
+// controlEcho returns the echo of the control character code in the
+// caret notation, e.g. ^C.
+func controlEcho(code rune) []int {
+	return []int{'^', int(code ^ 0x40)}
+}
@@ -9,22 +9,45 @@
 package tty
 
 import (
+	"errors"
+
+	"github.com/markkurossi/blackbox-os/kernel/signal"
 	"github.com/markkurossi/vt100"
 )
 
+// TTYFlags define the terminal local modes.
 type TTYFlags uint
 
+// Local modes.
 const (
 	ICANON TTYFlags = 1 << iota
 	ECHO
+	ECHOE
+	ECHOK
+	ECHONL
+	ISIG
+	IEXTEN
 )
 
+// ErrBackground is returned when a process which is not in the
+// terminal's foreground process group reads from the terminal.
+var ErrBackground = errors.New("background read")
+
+// SignalHandler sends the signal to the process group pgrp.
+type SignalHandler func(pgrp int, sig signal.Signal)
+
 type TTY interface {
 	Flags() TTYFlags
 	SetFlags(flags TTYFlags)
+	Termios() Termios
+	SetTermios(t Termios)
 	Read(p []byte) (n int, err error)
+	ReadGroup(p []byte, pgrp int) (n int, err error)
 	Cursor() vt100.Point
 	Size() (ch, px vt100.Point)
 	Write(p []byte) (n int, err error)
 	Flush() error
+	Foreground() int
+	SetForeground(pgrp int)
+	SetSignalHandler(h SignalHandler)
 }
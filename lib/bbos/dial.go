@@ -76,7 +76,7 @@ func (c *Conn) Write(b []byte) (n int, err error) {
 }
 
 func (c *Conn) Close() error {
-	return fmt.Errorf("conn.Close: not implemented yet")
+	return Close(c.fd)
 }
 
 func (c *Conn) LocalAddr() net.Addr {
@@ -0,0 +1,44 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package bbos
+
+import (
+	"errors"
+	sys "syscall"
+)
+
+// errnos maps the kernel error codes to the system error numbers.
+var errnos = map[string]sys.Errno{
+	"ENOENT":    sys.ENOENT,
+	"EINVAL":    sys.EINVAL,
+	"ENOSYS":    sys.ENOSYS,
+	"EBADF":     sys.EBADF,
+	"EEXIST":    sys.EEXIST,
+	"ENOTDIR":   sys.ENOTDIR,
+	"EISDIR":    sys.EISDIR,
+	"ENOTEMPTY": sys.ENOTEMPTY,
+	"EROFS":     sys.EROFS,
+	"EBUSY":     sys.EBUSY,
+	"EPIPE":     sys.EPIPE,
+	"ESRCH":     sys.ESRCH,
+	"ENOTTY":    sys.ENOTTY,
+	"EIO":       sys.EIO,
+	"ECHILD":    sys.ECHILD,
+	"EXDEV":     sys.EXDEV,
+	"ESPIPE":    sys.ESPIPE,
+	"EPERM":     sys.EPERM,
+}
+
+// codeError returns the error for the kernel error code. The known
+// codes are returned as syscall.Errno values which can be tested
+// with errors.Is against the os package errors.
+func codeError(code string) error {
+	if e, ok := errnos[code]; ok {
+		return e
+	}
+	return errors.New(code)
+}
@@ -0,0 +1,63 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package bbos
+
+// File implements an open file descriptor. Unlike os.File, File can
+// wrap any kernel file descriptor, including the file descriptors
+// returned by Open and Pipe.
+type File struct {
+	fd   int
+	name string
+}
+
+// NewFile returns a new File with the file descriptor and name.
+func NewFile(fd int, name string) *File {
+	return &File{
+		fd:   fd,
+		name: name,
+	}
+}
+
+// Fd returns the file descriptor of the file.
+func (f *File) Fd() uintptr {
+	return uintptr(f.fd)
+}
+
+// Name returns the name of the file.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Read implements the io.Reader interface.
+func (f *File) Read(p []byte) (int, error) {
+	return Read(f.fd, p)
+}
+
+// Write implements the io.Writer interface.
+func (f *File) Write(p []byte) (int, error) {
+	return Write(f.fd, p)
+}
+
+// ReadAt implements the io.ReaderAt interface.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	return Pread(f.fd, p, off)
+}
+
+// WriteAt implements the io.WriterAt interface.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	return Pwrite(f.fd, p, off)
+}
+
+// Seek implements the io.Seeker interface.
+func (f *File) Seek(offset int64, whence int) (int64, error) {
+	return Seek(f.fd, offset, whence)
+}
+
+// Close implements the io.Closer interface.
+func (f *File) Close() error {
+	return Close(f.fd)
+}
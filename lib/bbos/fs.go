@@ -0,0 +1,245 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package bbos
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+)
+
+// File type bits of the stat mode.
+const (
+	S_IFMT   int = 0170000
+	S_IFIFO  int = 0010000
+	S_IFCHR  int = 0020000
+	S_IFDIR  int = 0040000
+	S_IFBLK  int = 0060000
+	S_IFREG  int = 0100000
+	S_IFLNK  int = 0120000
+	S_IFSOCK int = 0140000
+)
+
+// FileInfo implements os.FileInfo for the stat system call results.
+type FileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	stat    map[string]interface{}
+}
+
+func (info *FileInfo) Name() string {
+	return info.name
+}
+
+func (info *FileInfo) Size() int64 {
+	return info.size
+}
+
+func (info *FileInfo) Mode() os.FileMode {
+	return info.mode
+}
+
+func (info *FileInfo) ModTime() time.Time {
+	return info.modTime
+}
+
+func (info *FileInfo) IsDir() bool {
+	return info.mode.IsDir()
+}
+
+// Sys returns the stat system call result as a map from the stat
+// field names to their values.
+func (info *FileInfo) Sys() interface{} {
+	return info.stat
+}
+
+// FileMode converts the stat mode into os.FileMode.
+func FileMode(mode int) os.FileMode {
+	result := os.FileMode(mode & 0777)
+
+	switch mode & S_IFMT {
+	case S_IFDIR:
+		result |= os.ModeDir
+	case S_IFCHR:
+		result |= os.ModeDevice | os.ModeCharDevice
+	case S_IFBLK:
+		result |= os.ModeDevice
+	case S_IFIFO:
+		result |= os.ModeNamedPipe
+	case S_IFLNK:
+		result |= os.ModeSymlink
+	case S_IFSOCK:
+		result |= os.ModeSocket
+	}
+	return result
+}
+
+func fileInfo(name string, data map[string]interface{}) (*FileInfo, error) {
+	stat, ok := data["obj"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Stat: invalid response")
+	}
+	info := &FileInfo{
+		name: path.Base(name),
+		stat: stat,
+	}
+	if v, ok := stat["mode"].(int); ok {
+		info.mode = FileMode(v)
+	}
+	if v, ok := stat["size"].(int); ok {
+		info.size = int64(v)
+	}
+	if v, ok := stat["mtimeMs"].(int); ok {
+		info.modTime = time.Unix(0, int64(v)*int64(time.Millisecond))
+	}
+	return info, nil
+}
+
+// Stat returns the file information of the named file.
+func Stat(name string) (os.FileInfo, error) {
+	data, err := Syscall("stat", map[string]interface{}{
+		"path": name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo(name, data)
+}
+
+// Fstat returns the file information of the file descriptor fd.
+func Fstat(fd int, name string) (os.FileInfo, error) {
+	data, err := Syscall("fstat", map[string]interface{}{
+		"fd": fd,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo(name, data)
+}
+
+// ReadDir returns the sorted names of the directory entries.
+func ReadDir(name string) ([]string, error) {
+	data, err := Syscall("readdir", map[string]interface{}{
+		"path": name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	entries, _ := data["obj"].([]interface{})
+	for _, e := range entries {
+		n, ok := e.(string)
+		if !ok {
+			return nil, fmt.Errorf("ReadDir: invalid response")
+		}
+		result = append(result, n)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// Mkdir creates a new directory with the permission bits perm.
+func Mkdir(name string, perm os.FileMode) error {
+	_, err := Syscall("mkdir", map[string]interface{}{
+		"path": name,
+		"perm": int(perm.Perm()),
+	})
+	return err
+}
+
+// Unlink removes the named file.
+func Unlink(name string) error {
+	_, err := Syscall("unlink", map[string]interface{}{
+		"path": name,
+	})
+	return err
+}
+
+// Rmdir removes the named empty directory.
+func Rmdir(name string) error {
+	_, err := Syscall("rmdir", map[string]interface{}{
+		"path": name,
+	})
+	return err
+}
+
+// Rename renames the file from to the name to.
+func Rename(from, to string) error {
+	_, err := Syscall("rename", map[string]interface{}{
+		"from": from,
+		"to":   to,
+	})
+	return err
+}
+
+// Truncate changes the size of the named file.
+func Truncate(name string, size int64) error {
+	_, err := Syscall("truncate", map[string]interface{}{
+		"path":   name,
+		"length": int(size),
+	})
+	return err
+}
+
+// FS implements the file operations with the system calls.
+type FS struct{}
+
+// Open opens the named file.
+func (fs FS) Open(name string, flag int, perm os.FileMode) (
+	io.ReadWriteCloser, error) {
+
+	fd, err := Open(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return NewFile(fd, name), nil
+}
+
+// Stat returns the file information of the named file.
+func (fs FS) Stat(name string) (os.FileInfo, error) {
+	return Stat(name)
+}
+
+// ReadDir returns the sorted names of the directory entries.
+func (fs FS) ReadDir(name string) ([]string, error) {
+	return ReadDir(name)
+}
+
+// Mkdir creates a new directory.
+func (fs FS) Mkdir(name string, perm os.FileMode) error {
+	return Mkdir(name, perm)
+}
+
+// Unlink removes the named file.
+func (fs FS) Unlink(name string) error {
+	return Unlink(name)
+}
+
+// Rmdir removes the named empty directory.
+func (fs FS) Rmdir(name string) error {
+	return Rmdir(name)
+}
+
+// Rename renames the file from to the name to.
+func (fs FS) Rename(from, to string) error {
+	return Rename(from, to)
+}
+
+// Getwd returns the current working directory.
+func (fs FS) Getwd() (string, error) {
+	return Getwd()
+}
+
+// Truncate changes the size of the named file.
+func (fs FS) Truncate(name string, size int64) error {
+	return Truncate(name, size)
+}
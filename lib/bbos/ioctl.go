@@ -37,3 +37,65 @@ func SetFlags(fd, flags int) error {
 	})
 	return err
 }
+
+// Tcgetpgrp returns the foreground process group of the terminal fd.
+func Tcgetpgrp(fd int) (int, error) {
+	data, err := Syscall("ioctl", map[string]interface{}{
+		"fd":      fd,
+		"request": "GetPgrp",
+	})
+	if err != nil {
+		return 0, err
+	}
+	pgrp, ok := data["ret"].(int)
+	if !ok {
+		return 0, fmt.Errorf("Tcgetpgrp: invalid response")
+	}
+	return pgrp, nil
+}
+
+// Tcsetpgrp sets the foreground process group of the terminal fd.
+func Tcsetpgrp(fd, pgrp int) error {
+	_, err := Syscall("ioctl", map[string]interface{}{
+		"fd":      fd,
+		"request": "SetPgrp",
+		"value":   pgrp,
+	})
+	return err
+}
+
+// WinSize defines the terminal window size in characters.
+type WinSize struct {
+	Rows int
+	Cols int
+}
+
+// GetWinSize returns the window size of the terminal fd.
+func GetWinSize(fd int) (WinSize, error) {
+	data, err := Syscall("ioctl", map[string]interface{}{
+		"fd":      fd,
+		"request": "GetWinSize",
+	})
+	if err != nil {
+		return WinSize{}, err
+	}
+	obj, ok := data["obj"].(map[string]interface{})
+	if !ok {
+		return WinSize{}, fmt.Errorf("GetWinSize: invalid response")
+	}
+	rows, ok1 := obj["rows"].(int)
+	cols, ok2 := obj["cols"].(int)
+	if !ok1 || !ok2 {
+		return WinSize{}, fmt.Errorf("GetWinSize: invalid response")
+	}
+	return WinSize{
+		Rows: rows,
+		Cols: cols,
+	}, nil
+}
+
+// IsATTY tests if the file descriptor fd refers to a terminal.
+func IsATTY(fd int) bool {
+	_, err := GetFlags(fd)
+	return err == nil
+}
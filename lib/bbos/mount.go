@@ -0,0 +1,44 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package bbos
+
+import (
+	"fmt"
+)
+
+// Mount describes a mounted filesystem.
+type Mount struct {
+	Path string
+	Type string
+}
+
+// Mounts returns the filesystem mount table.
+func Mounts() ([]Mount, error) {
+	data, err := Syscall("mounts", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := data["obj"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	var result []Mount
+	for _, item := range arr {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Mounts: invalid response")
+		}
+		path, _ := m["path"].(string)
+		typ, _ := m["type"].(string)
+
+		result = append(result, Mount{
+			Path: path,
+			Type: typ,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,176 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package bbos
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	_ os.Signal = SIGINT
+)
+
+// Signal defines process signals. The Signal implements the
+// os.Signal interface.
+type Signal int
+
+// Signals.
+const (
+	SIGHUP   Signal = 1
+	SIGINT   Signal = 2
+	SIGKILL  Signal = 9
+	SIGTERM  Signal = 15
+	SIGCONT  Signal = 18
+	SIGSTOP  Signal = 19
+	SIGTSTP  Signal = 20
+	SIGTTIN  Signal = 21
+	SIGWINCH Signal = 28
+)
+
+var signalNames = map[Signal]string{
+	SIGHUP:   "SIGHUP",
+	SIGINT:   "SIGINT",
+	SIGKILL:  "SIGKILL",
+	SIGTERM:  "SIGTERM",
+	SIGCONT:  "SIGCONT",
+	SIGSTOP:  "SIGSTOP",
+	SIGTSTP:  "SIGTSTP",
+	SIGTTIN:  "SIGTTIN",
+	SIGWINCH: "SIGWINCH",
+}
+
+// Signals returns all known signals in numeric order.
+func Signals() []Signal {
+	return []Signal{
+		SIGHUP, SIGINT, SIGKILL, SIGTERM, SIGCONT, SIGSTOP, SIGTSTP, SIGTTIN,
+		SIGWINCH,
+	}
+}
+
+func (s Signal) String() string {
+	name, ok := signalNames[s]
+	if ok {
+		return name
+	}
+	return fmt.Sprintf("{Signal %d}", s)
+}
+
+// Signal implements the os.Signal interface.
+func (s Signal) Signal() {}
+
+// ParseSignal parses the signal name or number. The name can be
+// given with or without the SIG prefix.
+func ParseSignal(name string) (Signal, error) {
+	n, err := strconv.Atoi(name)
+	if err == nil {
+		if n == 0 {
+			return 0, nil
+		}
+		_, ok := signalNames[Signal(n)]
+		if ok {
+			return Signal(n), nil
+		}
+		return 0, fmt.Errorf("invalid signal number %d", n)
+	}
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	for sig, n := range signalNames {
+		if n == name {
+			return sig, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid signal '%s'", name)
+}
+
+// Kill sends the signal to the process pid. If pid is 0, the signal
+// is sent to all processes in the process group of the calling
+// process. If pid is negative, the signal is sent to all processes
+// in the process group -pid.
+func Kill(pid int, sig Signal) error {
+	_, err := Syscall("kill", map[string]interface{}{
+		"pid":    pid,
+		"signal": int(sig),
+	})
+	return err
+}
+
+var (
+	signalMutex    sync.Mutex
+	signalChannels = make(map[Signal][]chan<- os.Signal)
+	signalOnce     sync.Once
+)
+
+func sigaction(sig Signal, action string) error {
+	_, err := Syscall("sigaction", map[string]interface{}{
+		"signal": int(sig),
+		"action": action,
+	})
+	return err
+}
+
+// Notify causes the signals to be relayed to the channel c. Like
+// with os/signal.Notify, the signals are sent without blocking and
+// the caller must use a buffered channel.
+func Notify(c chan<- os.Signal, sig ...Signal) error {
+	signalOnce.Do(func() {
+		setSignalHandler(dispatchSignal)
+	})
+
+	signalMutex.Lock()
+	defer signalMutex.Unlock()
+
+	for _, s := range sig {
+		err := sigaction(s, "catch")
+		if err != nil {
+			return err
+		}
+		signalChannels[s] = append(signalChannels[s], c)
+	}
+	return nil
+}
+
+func dispatchSignal(sig Signal) {
+	signalMutex.Lock()
+	defer signalMutex.Unlock()
+
+	for _, c := range signalChannels[sig] {
+		select {
+		case c <- sig:
+		default:
+		}
+	}
+}
+
+// Ignore causes the signals to be ignored.
+func Ignore(sig ...Signal) error {
+	return setAction("ignore", sig)
+}
+
+// Reset restores the default actions of the signals.
+func Reset(sig ...Signal) error {
+	return setAction("default", sig)
+}
+
+func setAction(action string, sig []Signal) error {
+	signalMutex.Lock()
+	defer signalMutex.Unlock()
+
+	for _, s := range sig {
+		err := sigaction(s, action)
+		if err != nil {
+			return err
+		}
+		delete(signalChannels, s)
+	}
+	return nil
+}
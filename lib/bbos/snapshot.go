@@ -0,0 +1,83 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package bbos
+
+import (
+	"fmt"
+	"time"
+)
+
+// Snapshot describes a filesystem snapshot.
+type Snapshot struct {
+	ID        string
+	Parent    string
+	Timestamp time.Time
+	Message   string
+}
+
+// Snapshots returns the filesystem snapshot history, starting from
+// the latest snapshot.
+func Snapshots() ([]Snapshot, error) {
+	data, err := Syscall("snapshots", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := data["obj"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	var result []Snapshot
+	for _, item := range arr {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Snapshots: invalid response")
+		}
+		id, _ := m["id"].(string)
+		parent, _ := m["parent"].(string)
+		timestamp, _ := m["timestamp"].(int)
+		message, _ := m["message"].(string)
+
+		result = append(result, Snapshot{
+			ID:        id,
+			Parent:    parent,
+			Timestamp: time.Unix(0, int64(timestamp)*int64(time.Millisecond)),
+			Message:   message,
+		})
+	}
+	return result, nil
+}
+
+// Commit creates a new snapshot from the current filesystem state
+// and returns its ID.
+func Commit(message string) (string, error) {
+	data, err := Syscall("commit", map[string]interface{}{
+		"message": message,
+	})
+	if err != nil {
+		return "", err
+	}
+	val, ok := data["buf"]
+	if !ok {
+		return "", fmt.Errorf("Commit: invalid response")
+	}
+	buf, ok := val.([]byte)
+	if !ok {
+		return "", fmt.Errorf("Commit: invalid response")
+	}
+	return string(buf), nil
+}
+
+// Checkout sets the filesystem state to the snapshot. The snapshot is
+// identified by the prefix of its ID. If force is true, all
+// uncommitted changes are discarded.
+func Checkout(id string, force bool) error {
+	_, err := Syscall("checkout", map[string]interface{}{
+		"id":    id,
+		"force": force,
+	})
+	return err
+}
@@ -10,21 +10,47 @@ import (
 	"fmt"
 )
 
-func Spawn(argv []string, fds []int) (int, error) {
+// ProcAttr holds the attributes of a new process.
+type ProcAttr struct {
+	// Env specifies the environment of the new process. If Env is
+	// nil, the process inherits the environment of its parent.
+	Env []string
+	// Files specifies the standard files of the new process.
+	Files []int
+	// Setpgid specifies if the process is put into the process group
+	// Pgid. If Pgid is 0, the process is put into a new process
+	// group with the same ID as the process ID.
+	Setpgid bool
+	Pgid    int
+}
+
+// Spawn creates a new process running the command argv.
+func Spawn(argv []string, attr *ProcAttr) (int, error) {
 	var iargv []interface{}
 	for _, arg := range argv {
 		iargv = append(iargv, arg)
 	}
 
 	var ifds []interface{}
-	for _, fd := range fds {
+	for _, fd := range attr.Files {
 		ifds = append(ifds, fd)
 	}
 
-	data, err := Syscall("spawn", map[string]interface{}{
+	params := map[string]interface{}{
 		"argv": iargv,
 		"fds":  ifds,
-	})
+	}
+	if attr.Env != nil {
+		var ienv []interface{}
+		for _, e := range attr.Env {
+			ienv = append(ienv, e)
+		}
+		params["env"] = ienv
+	}
+	if attr.Setpgid {
+		params["pgid"] = attr.Pgid
+	}
+	data, err := Syscall("spawn", params)
 	if err != nil {
 		return 0, err
 	}
@@ -39,20 +65,127 @@ func Spawn(argv []string, fds []int) (int, error) {
 	return ipid, nil
 }
 
-func Wait(pid int) (int, error) {
+// Wait options.
+const (
+	WNOHANG = 1 << iota
+	WUNTRACED
+)
+
+// WaitStatus describes the state change of a process.
+type WaitStatus struct {
+	Code    int
+	Signal  Signal
+	Exited  bool
+	Stopped bool
+}
+
+// Signaled tests if the process was terminated by a signal.
+func (ws WaitStatus) Signaled() bool {
+	return ws.Exited && ws.Signal != 0
+}
+
+// Running tests if the process state has not changed. This is
+// returned by WaitPid with the WNOHANG option.
+func (ws WaitStatus) Running() bool {
+	return !ws.Exited && !ws.Stopped
+}
+
+// ExitStatus returns the exit status of the process. If the process
+// was terminated or stopped by a signal, the status is 128 + signal
+// number.
+func (ws WaitStatus) ExitStatus() int {
+	if ws.Signal != 0 {
+		return 128 + int(ws.Signal)
+	}
+	return ws.Code
+}
+
+// Wait waits for the process pid to terminate.
+func Wait(pid int) (WaitStatus, error) {
+	return WaitPid(pid, 0)
+}
+
+// WaitPid waits for the process pid to change state. If options
+// contain WUNTRACED, WaitPid also returns when the process is
+// stopped. If options contain WNOHANG, WaitPid returns immediately
+// if the process state has not changed.
+func WaitPid(pid, options int) (WaitStatus, error) {
+	var ws WaitStatus
+
 	data, err := Syscall("wait", map[string]interface{}{
-		"pid": pid,
+		"pid":     pid,
+		"options": options,
 	})
 	if err != nil {
-		return 0, err
+		return ws, err
 	}
 	code, ok := data["ret"]
 	if !ok {
-		return 0, fmt.Errorf("Wait: invalid response")
+		return ws, fmt.Errorf("Wait: invalid response")
+	}
+	ws.Code, ok = code.(int)
+	if !ok {
+		return ws, fmt.Errorf("Wait: invalid response")
+	}
+	obj, ok := data["obj"].(map[string]interface{})
+	if !ok {
+		return ws, fmt.Errorf("Wait: invalid response")
+	}
+	sig, _ := obj["signal"].(int)
+	ws.Signal = Signal(sig)
+	ws.Exited, _ = obj["exited"].(bool)
+	ws.Stopped, _ = obj["stopped"].(bool)
+
+	return ws, nil
+}
+
+// Getpgrp returns the process group ID of the calling process.
+func Getpgrp() (int, error) {
+	data, err := Syscall("getpgrp", map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
+	pgrp, ok := data["ret"].(int)
+	if !ok {
+		return 0, fmt.Errorf("Getpgrp: invalid response")
+	}
+	return pgrp, nil
+}
+
+// Setpgid sets the process group ID of the process pid to pgid. If
+// pid is 0, the calling process is used. If pgid is 0, the process
+// ID of the process is used as the process group ID.
+func Setpgid(pid, pgid int) error {
+	_, err := Syscall("setpgid", map[string]interface{}{
+		"pid":  pid,
+		"pgid": pgid,
+	})
+	return err
+}
+
+// Getpid returns the process ID of the calling process.
+func Getpid() (int, error) {
+	data, err := Syscall("getpid", map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
+	pid, ok := data["ret"].(int)
+	if !ok {
+		return 0, fmt.Errorf("Getpid: invalid response")
+	}
+	return pid, nil
+}
+
+// Getppid returns the process ID of the parent of the calling
+// process.
+func Getppid() (int, error) {
+	data, err := Syscall("getppid", map[string]interface{}{})
+	if err != nil {
+		return 0, err
 	}
-	icode, ok := code.(int)
+	pid, ok := data["ret"].(int)
 	if !ok {
-		return 0, fmt.Errorf("Wait: invalid response")
+		return 0, fmt.Errorf("Getppid: invalid response")
 	}
-	return icode, nil
+	return pid, nil
 }
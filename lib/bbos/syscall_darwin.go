@@ -22,3 +22,6 @@ func Syscall(call string, params map[string]interface{}) (
 
 func SyscallSetWD(cwd string) {
 }
+
+func setSignalHandler(handler func(sig Signal)) {
+}
@@ -7,7 +7,7 @@
 package bbos
 
 import (
-	"errors"
+	"math"
 	"syscall/js"
 )
 
@@ -15,6 +15,8 @@ var (
 	syscall      = js.Global().Get("syscall")
 	syscallSetWD = js.Global().Get("syscallSetWD")
 	uint8Array   = js.Global().Get("Uint8Array")
+	array        = js.Global().Get("Array")
+	object       = js.Global().Get("Object")
 )
 
 func JSByteArray(data []byte) js.Value {
@@ -42,7 +44,7 @@ func Syscall(call string, params map[string]interface{}) (
 	result := <-c
 
 	if !result[0].IsNull() {
-		return nil, errors.New(result[0].Get("code").String())
+		return nil, codeError(result[0].Get("code").String())
 	}
 
 	values := map[string]interface{}{
@@ -53,10 +55,64 @@ func Syscall(call string, params map[string]interface{}) (
 		js.CopyBytesToGo(buf, result[2])
 		values["buf"] = buf
 	}
+	if len(result) > 3 && !result[3].IsUndefined() && !result[3].IsNull() {
+		values["obj"] = goValue(result[3])
+	}
 
 	return values, nil
 }
 
+// goValue converts the JavaScript value into the corresponding Go
+// value. Arrays are converted into []interface{} and objects into
+// map[string]interface{}.
+func goValue(v js.Value) interface{} {
+	switch v.Type() {
+	case js.TypeBoolean:
+		return v.Bool()
+
+	case js.TypeNumber:
+		f := v.Float()
+		if f == math.Trunc(f) {
+			return int(f)
+		}
+		return f
+
+	case js.TypeString:
+		return v.String()
+
+	case js.TypeObject:
+		if v.InstanceOf(array) {
+			result := make([]interface{}, v.Length())
+			for i := 0; i < len(result); i++ {
+				result[i] = goValue(v.Index(i))
+			}
+			return result
+		}
+		result := make(map[string]interface{})
+		keys := object.Call("keys", v)
+		for i := 0; i < keys.Length(); i++ {
+			key := keys.Index(i).String()
+			result[key] = goValue(v.Get(key))
+		}
+		return result
+
+	default:
+		return nil
+	}
+}
+
 func SyscallSetWD(cwd string) {
 	syscallSetWD.Invoke(js.ValueOf(cwd))
 }
+
+// setSignalHandler sets the function which receives the signals
+// delivered to the process.
+func setSignalHandler(handler func(sig Signal)) {
+	js.Global().Set("signalHandler", js.FuncOf(
+		func(this js.Value, args []js.Value) interface{} {
+			if len(args) == 1 && args[0].Type() == js.TypeNumber {
+				go handler(Signal(args[0].Int()))
+			}
+			return nil
+		}))
+}
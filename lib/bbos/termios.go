@@ -0,0 +1,119 @@
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package bbos
+
+import (
+	"fmt"
+)
+
+// Input modes.
+const (
+	ICRNL = 1 << iota
+	INLCR
+	IGNCR
+)
+
+// Output modes.
+const (
+	OPOST = 1 << iota
+	ONLCR
+	OCRNL
+)
+
+// Local modes.
+const (
+	ICANON = 1 << iota
+	ECHO
+	ECHOE
+	ECHOK
+	ECHONL
+	ISIG
+	IEXTEN
+)
+
+// Indices of the control characters.
+const (
+	VINTR = iota
+	VERASE
+	VKILL
+	VEOF
+	VSUSP
+	VMIN
+	VTIME
+	NCCS
+)
+
+// Termios defines the terminal settings.
+type Termios struct {
+	Iflag int
+	Oflag int
+	Lflag int
+	Cc    [NCCS]byte
+}
+
+// Tcgetattr returns the settings of the terminal fd.
+func Tcgetattr(fd int) (*Termios, error) {
+	data, err := Syscall("ioctl", map[string]interface{}{
+		"fd":      fd,
+		"request": "GetTermios",
+	})
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := data["obj"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Tcgetattr: invalid response")
+	}
+	iflag, ok1 := obj["iflag"].(int)
+	oflag, ok2 := obj["oflag"].(int)
+	lflag, ok3 := obj["lflag"].(int)
+	cc, ok4 := obj["cc"].([]interface{})
+	if !ok1 || !ok2 || !ok3 || !ok4 {
+		return nil, fmt.Errorf("Tcgetattr: invalid response")
+	}
+	t := &Termios{
+		Iflag: iflag,
+		Oflag: oflag,
+		Lflag: lflag,
+	}
+	for i := 0; i < len(cc) && i < len(t.Cc); i++ {
+		ch, ok := cc[i].(int)
+		if !ok {
+			return nil, fmt.Errorf("Tcgetattr: invalid response")
+		}
+		t.Cc[i] = byte(ch)
+	}
+	return t, nil
+}
+
+// Tcsetattr sets the settings of the terminal fd.
+func Tcsetattr(fd int, t *Termios) error {
+	var cc []interface{}
+	for _, ch := range t.Cc {
+		cc = append(cc, int(ch))
+	}
+	_, err := Syscall("ioctl", map[string]interface{}{
+		"fd":      fd,
+		"request": "SetTermios",
+		"iflag":   t.Iflag,
+		"oflag":   t.Oflag,
+		"lflag":   t.Lflag,
+		"cc":      cc,
+	})
+	return err
+}
+
+// MakeRaw sets the terminal t into the raw input mode: the input is
+// available byte by byte without echo, line editing, or input
+// translations. The signal generating characters and the output
+// processing remain enabled.
+func (t *Termios) MakeRaw() {
+	t.Iflag &^= ICRNL | INLCR | IGNCR
+	t.Lflag &^= ICANON | ECHO | ECHONL | IEXTEN
+	t.Cc[VMIN] = 1
+	t.Cc[VTIME] = 0
+}
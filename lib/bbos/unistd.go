@@ -9,8 +9,39 @@ package bbos
 import (
 	"fmt"
 	"io"
+	"os"
 )
 
+// Flags for Open.
+const (
+	O_RDONLY int = 0
+	O_WRONLY int = 01
+	O_RDWR   int = 02
+	O_CREAT  int = 0100
+	O_EXCL   int = 0200
+	O_TRUNC  int = 01000
+	O_APPEND int = 02000
+)
+
+// Open opens the named file with the flags flag. If the file is
+// created, it is created with the mode perm. The function returns the
+// file descriptor of the opened file.
+func Open(path string, flag int, perm os.FileMode) (int, error) {
+	data, err := Syscall("open", map[string]interface{}{
+		"path":  path,
+		"flags": flag,
+		"mode":  int(perm),
+	})
+	if err != nil {
+		return 0, err
+	}
+	fd, ok := data["ret"].(int)
+	if !ok {
+		return 0, fmt.Errorf("Open: invalid response")
+	}
+	return fd, nil
+}
+
 func Read(fd int, buf []byte) (int, error) {
 	data, err := Syscall("read", map[string]interface{}{
 		"fd":     fd,
@@ -58,6 +89,123 @@ func Write(fd int, buf []byte) (int, error) {
 	return n, nil
 }
 
+// Pread reads from the file descriptor fd at the offset off. The
+// file offset of fd is not changed.
+func Pread(fd int, buf []byte, off int64) (int, error) {
+	data, err := Syscall("read", map[string]interface{}{
+		"fd":       fd,
+		"length":   len(buf),
+		"position": off,
+	})
+	if err != nil {
+		return 0, err
+	}
+	bval, ok := data["buf"].([]byte)
+	if !ok || len(bval) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(buf, bval)
+	if n < len(buf) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Pwrite writes to the file descriptor fd at the offset off. The
+// file offset of fd is not changed.
+func Pwrite(fd int, buf []byte, off int64) (int, error) {
+	data, err := Syscall("write", map[string]interface{}{
+		"fd":       fd,
+		"data":     JSByteArray(buf),
+		"offset":   0,
+		"length":   len(buf),
+		"position": off,
+	})
+	if err != nil {
+		return 0, err
+	}
+	n, ok := data["ret"].(int)
+	if !ok {
+		return 0, fmt.Errorf("Pwrite: invalid response")
+	}
+	return n, nil
+}
+
+// Seek sets the file offset of the file descriptor fd. The whence
+// values are the io.Seek constants. The function returns the new
+// offset.
+func Seek(fd int, offset int64, whence int) (int64, error) {
+	data, err := Syscall("lseek", map[string]interface{}{
+		"fd":     fd,
+		"offset": offset,
+		"whence": whence,
+	})
+	if err != nil {
+		return 0, err
+	}
+	pos, ok := data["ret"].(int)
+	if !ok {
+		return 0, fmt.Errorf("Seek: invalid response")
+	}
+	return int64(pos), nil
+}
+
+// Close closes the file descriptor fd.
+func Close(fd int) error {
+	_, err := Syscall("close", map[string]interface{}{
+		"fd": fd,
+	})
+	return err
+}
+
+// Dup duplicates the file descriptor fd. The new file descriptor is
+// the lowest free file descriptor number.
+func Dup(fd int) (int, error) {
+	data, err := Syscall("dup", map[string]interface{}{
+		"fd": fd,
+	})
+	if err != nil {
+		return 0, err
+	}
+	newfd, ok := data["ret"].(int)
+	if !ok {
+		return 0, fmt.Errorf("Dup: invalid response")
+	}
+	return newfd, nil
+}
+
+// Dup2 duplicates the file descriptor oldfd to newfd. If newfd is
+// open, it is closed first.
+func Dup2(oldfd, newfd int) error {
+	_, err := Syscall("dup2", map[string]interface{}{
+		"oldfd": oldfd,
+		"newfd": newfd,
+	})
+	return err
+}
+
+// Pipe creates a pipe and returns its read and write file
+// descriptors.
+func Pipe() (r, w int, err error) {
+	data, err := Syscall("pipe", map[string]interface{}{})
+	if err != nil {
+		return 0, 0, err
+	}
+	fds, ok := data["obj"].([]interface{})
+	if !ok || len(fds) != 2 {
+		return 0, 0, fmt.Errorf("Pipe: invalid response")
+	}
+	r, ok = fds[0].(int)
+	if !ok {
+		return 0, 0, fmt.Errorf("Pipe: invalid response")
+	}
+	w, ok = fds[1].(int)
+	if !ok {
+		return 0, 0, fmt.Errorf("Pipe: invalid response")
+	}
+	return r, w, nil
+}
+
 func Chdir(dir string) error {
 	// XXX send path as string.
 	data, err := Syscall("chdir", map[string]interface{}{
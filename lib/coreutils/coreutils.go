@@ -0,0 +1,121 @@
+//
+// coreutils.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+// Package coreutils implements the core file utilities. The commands
+// access files through the FS interface so they can run against the
+// kernel system calls as well as against an in-memory filesystem.
+package coreutils
+
+//go:generate go run mkmain.go cp grep head mkdir mv rm rmdir sed tail tee touch wc
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// FS defines the file operations the commands use.
+type FS interface {
+	Open(name string, flag int, perm os.FileMode) (io.ReadWriteCloser, error)
+	Stat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]string, error)
+	Mkdir(name string, perm os.FileMode) error
+	Unlink(name string) error
+	Rmdir(name string) error
+	Rename(from, to string) error
+	Truncate(name string, size int64) error
+	Getwd() (string, error)
+}
+
+// Env defines the environment of a command. The Exec function runs
+// the command argv and returns its exit status. It can be nil if the
+// environment can't run commands.
+type Env struct {
+	FS     FS
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Exec   func(argv []string) (int, error)
+}
+
+// errorf prints the error message of the command and returns the
+// failure exit status.
+func (env *Env) errorf(cmd, format string, a ...interface{}) int {
+	fmt.Fprintf(env.Stderr, "%s: %s\n", cmd, fmt.Sprintf(format, a...))
+	return 1
+}
+
+// abs returns the cleaned absolute path of the file name. Relative
+// names are resolved against the working directory.
+func (env *Env) abs(name string) string {
+	if !path.IsAbs(name) {
+		if wd, err := env.FS.Getwd(); err == nil {
+			name = path.Join(wd, name)
+		}
+	}
+	return path.Clean(name)
+}
+
+// open opens the named file for reading. The name "-" opens the
+// standard input.
+func (env *Env) open(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(env.Stdin), nil
+	}
+	return env.FS.Open(name, os.O_RDONLY, 0)
+}
+
+// getopt parses the command line options of args. The spec lists the
+// option letters. An option letter followed by ':' takes a
+// value. The function returns the options, the operands, and a
+// boolean success status. The options can be combined as in -rf and
+// the options and operands can be mixed until "--".
+func (env *Env) getopt(args []string, spec string) (
+	map[byte]string, []string, bool) {
+
+	cmd := args[0]
+	opts := make(map[byte]string)
+	var operands []string
+
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			operands = append(operands, args[i+1:]...)
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			operands = append(operands, arg)
+			continue
+		}
+		for j := 1; j < len(arg); j++ {
+			idx := strings.IndexByte(spec, arg[j])
+			if idx < 0 || arg[j] == ':' {
+				env.errorf(cmd, "invalid option -- '%c'", arg[j])
+				return nil, nil, false
+			}
+			if idx+1 >= len(spec) || spec[idx+1] != ':' {
+				opts[arg[j]] = ""
+				continue
+			}
+			if j+1 < len(arg) {
+				opts[arg[j]] = arg[j+1:]
+			} else if i+1 < len(args) {
+				i++
+				opts[arg[j]] = args[i]
+			} else {
+				env.errorf(cmd, "option requires an argument -- '%c'",
+					arg[j])
+				return nil, nil, false
+			}
+			break
+		}
+	}
+	return opts, operands, true
+}
@@ -0,0 +1,332 @@
+//
+// coreutils_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type testEnv struct {
+	*Env
+	t      *testing.T
+	stdout *bytes.Buffer
+	stderr *bytes.Buffer
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{
+		t:      t,
+		stdout: new(bytes.Buffer),
+		stderr: new(bytes.Buffer),
+	}
+	env.Env = &Env{
+		FS:     newMemFS(),
+		Stdin:  strings.NewReader(""),
+		Stdout: env.stdout,
+		Stderr: env.stderr,
+	}
+	return env
+}
+
+// run runs the command and returns its standard output.
+func (env *testEnv) run(cmd func(*Env, []string) int, args ...string) string {
+	env.t.Helper()
+	env.stdout.Reset()
+	env.stderr.Reset()
+	if status := cmd(env.Env, args); status != 0 {
+		env.t.Fatalf("%v failed: %d: %s", args, status, env.stderr.String())
+	}
+	return env.stdout.String()
+}
+
+// fail runs the command and checks that it fails.
+func (env *testEnv) fail(cmd func(*Env, []string) int, args ...string) {
+	env.t.Helper()
+	env.stderr.Reset()
+	if status := cmd(env.Env, args); status == 0 {
+		env.t.Errorf("%v succeeded", args)
+	}
+}
+
+func (env *testEnv) write(name, data string) {
+	env.t.Helper()
+	f, err := env.FS.Open(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		env.t.Fatalf("create %s: %s", name, err)
+	}
+	f.Write([]byte(data))
+	f.Close()
+}
+
+func (env *testEnv) read(name string) string {
+	env.t.Helper()
+	f, err := env.FS.Open(name, os.O_RDONLY, 0)
+	if err != nil {
+		env.t.Fatalf("open %s: %s", name, err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		env.t.Fatalf("read %s: %s", name, err)
+	}
+	return string(data)
+}
+
+func (env *testEnv) exists(name string) bool {
+	_, err := env.FS.Stat(name)
+	return err == nil
+}
+
+func TestGetopt(t *testing.T) {
+	env := newTestEnv(t)
+	opts, operands, ok := env.getopt([]string{
+		"cmd", "-rf", "a", "-n5", "-c", "7", "--", "-x",
+	}, "rfn:c:")
+	if !ok {
+		t.Fatalf("getopt failed: %s", env.stderr.String())
+	}
+	for opt, val := range map[byte]string{'r': "", 'f': "", 'n': "5",
+		'c': "7"} {
+		if v, ok := opts[opt]; !ok || v != val {
+			t.Errorf("option -%c=%q, expected %q", opt, v, val)
+		}
+	}
+	if strings.Join(operands, ",") != "a,-x" {
+		t.Errorf("unexpected operands: %v", operands)
+	}
+	if _, _, ok := env.getopt([]string{"cmd", "-z"}, "rf"); ok {
+		t.Errorf("getopt accepted invalid option")
+	}
+	if _, _, ok := env.getopt([]string{"cmd", "-n"}, "n:"); ok {
+		t.Errorf("getopt accepted missing value")
+	}
+}
+
+func TestCp(t *testing.T) {
+	env := newTestEnv(t)
+	env.write("/a", "hello")
+	env.run(Cp, "cp", "/a", "/b")
+	if data := env.read("/b"); data != "hello" {
+		t.Errorf("cp: unexpected content: %q", data)
+	}
+
+	env.run(Mkdir, "mkdir", "-p", "/d/e")
+	env.run(Cp, "cp", "/a", "/b", "/d")
+	if !env.exists("/d/a") || !env.exists("/d/b") {
+		t.Errorf("cp: files not copied into directory")
+	}
+	env.fail(Cp, "cp", "/a", "/b", "/c")
+	env.fail(Cp, "cp", "/d", "/x")
+
+	env.run(Cp, "cp", "-r", "/d", "/x")
+	if data := env.read("/x/a"); data != "hello" {
+		t.Errorf("cp -r: unexpected content: %q", data)
+	}
+	if info, err := env.FS.Stat("/x/e"); err != nil || !info.IsDir() {
+		t.Errorf("cp -r: subdirectory not copied: %v", err)
+	}
+	env.fail(Cp, "cp", "-r", "/d", "/d/e")
+	env.fail(Cp, "cp", "-r", ".", "sub")
+
+	// Copying a file to itself must not truncate it.
+	env.fail(Cp, "cp", "/d/a", "/d/a")
+	env.fail(Cp, "cp", "/d/a", "/d")
+	env.fail(Cp, "cp", "/d/a", "/d/e/../a")
+	if data := env.read("/d/a"); data != "hello" {
+		t.Errorf("cp: source truncated: %q", data)
+	}
+}
+
+func TestMv(t *testing.T) {
+	env := newTestEnv(t)
+	env.write("/a", "a")
+	env.run(Mkdir, "mkdir", "/d")
+	env.run(Mv, "mv", "/a", "/b")
+	if env.exists("/a") || env.read("/b") != "a" {
+		t.Errorf("mv: file not renamed")
+	}
+	env.run(Mv, "mv", "/b", "/d")
+	if env.exists("/b") || env.read("/d/b") != "a" {
+		t.Errorf("mv: file not moved into directory")
+	}
+	env.fail(Mv, "mv", "/missing", "/d")
+}
+
+func TestMvCrossDevice(t *testing.T) {
+	env := newTestEnv(t)
+	env.FS = &xdevFS{env.FS}
+	env.run(Mkdir, "mkdir", "-p", "/d/e")
+	env.write("/d/e/f", "data")
+	env.run(Mv, "mv", "/d", "/x")
+	if env.exists("/d") {
+		t.Errorf("mv: source not removed")
+	}
+	if data := env.read("/x/e/f"); data != "data" {
+		t.Errorf("mv: unexpected content: %q", data)
+	}
+}
+
+// xdevFS fails all renames with EXDEV.
+type xdevFS struct {
+	FS
+}
+
+func (x *xdevFS) Rename(from, to string) error {
+	return syscall.EXDEV
+}
+
+func TestRm(t *testing.T) {
+	env := newTestEnv(t)
+	env.write("/a", "a")
+	env.run(Mkdir, "mkdir", "-p", "/d/e")
+	env.write("/d/e/f", "f")
+
+	env.run(Rm, "rm", "/a")
+	if env.exists("/a") {
+		t.Errorf("rm: file not removed")
+	}
+	env.fail(Rm, "rm", "/a")
+	env.run(Rm, "rm", "-f", "/a")
+	env.fail(Rm, "rm", "/d")
+	env.fail(Rm, "rm", "-r", "/d/..")
+	env.run(Rm, "rm", "-rf", "/d")
+	if env.exists("/d") {
+		t.Errorf("rm -r: directory not removed")
+	}
+}
+
+func TestMkdirRmdir(t *testing.T) {
+	env := newTestEnv(t)
+	env.fail(Mkdir, "mkdir", "/a/b")
+	env.run(Mkdir, "mkdir", "-p", "/a/b/c")
+	env.run(Mkdir, "mkdir", "-p", "/a/b")
+	env.fail(Mkdir, "mkdir", "/a")
+	env.write("/f", "")
+	env.fail(Mkdir, "mkdir", "-p", "/f/g")
+
+	env.fail(Rmdir, "rmdir", "/a")
+	env.run(Rmdir, "rmdir", "-p", "/a/b/c")
+	if env.exists("/a") {
+		t.Errorf("rmdir -p: parents not removed")
+	}
+}
+
+func TestTouch(t *testing.T) {
+	env := newTestEnv(t)
+	env.run(Touch, "touch", "-c", "/a")
+	if env.exists("/a") {
+		t.Errorf("touch -c: file created")
+	}
+	env.run(Touch, "touch", "/a")
+	if !env.exists("/a") {
+		t.Fatalf("touch: file not created")
+	}
+	env.write("/b", "data")
+	n, err := env.FS.(*memFS).lookup("/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	n.modTime = old
+	env.run(Touch, "touch", "/b")
+	if data := env.read("/b"); data != "data" {
+		t.Errorf("touch: content modified: %q", data)
+	}
+	info, err := env.FS.Stat("/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().After(old) {
+		t.Errorf("touch: mtime not updated: %s", info.ModTime())
+	}
+}
+
+const lines = "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+
+func TestHead(t *testing.T) {
+	env := newTestEnv(t)
+	env.write("/f", lines)
+	if out := env.run(Head, "head", "/f"); out != lines[:21] {
+		t.Errorf("head: unexpected output: %q", out)
+	}
+	if out := env.run(Head, "head", "-n", "2", "/f"); out != "1\n2\n" {
+		t.Errorf("head -n: unexpected output: %q", out)
+	}
+	if out := env.run(Head, "head", "-c3", "/f"); out != "1\n2" {
+		t.Errorf("head -c: unexpected output: %q", out)
+	}
+	env.Stdin = strings.NewReader("a\nb")
+	if out := env.run(Head, "head", "-n1"); out != "a\n" {
+		t.Errorf("head stdin: unexpected output: %q", out)
+	}
+	env.write("/g", "g")
+	out := env.run(Head, "head", "-n1", "/f", "/g")
+	if out != "==> /f <==\n1\n\n==> /g <==\ng" {
+		t.Errorf("head files: unexpected output: %q", out)
+	}
+	env.fail(Head, "head", "-n", "x", "/f")
+}
+
+func TestTail(t *testing.T) {
+	env := newTestEnv(t)
+	env.write("/f", lines)
+	if out := env.run(Tail, "tail", "/f"); out != lines[4:] {
+		t.Errorf("tail: unexpected output: %q", out)
+	}
+	if out := env.run(Tail, "tail", "-n2", "/f"); out != "11\n12\n" {
+		t.Errorf("tail -n: unexpected output: %q", out)
+	}
+	if out := env.run(Tail, "tail", "-c", "3", "/f"); out != "12\n" {
+		t.Errorf("tail -c: unexpected output: %q", out)
+	}
+	env.Stdin = strings.NewReader("a\nb")
+	if out := env.run(Tail, "tail", "-n1"); out != "b" {
+		t.Errorf("tail stdin: unexpected output: %q", out)
+	}
+	env.fail(Tail, "tail", "/missing")
+}
+
+func TestWc(t *testing.T) {
+	env := newTestEnv(t)
+	env.write("/f", "hello world\nfoo\n")
+	env.write("/g", "äö\n")
+	if out := env.run(Wc, "wc", "/f"); out != "       2       3      16 /f\n" {
+		t.Errorf("wc: unexpected output: %q", out)
+	}
+	if out := env.run(Wc, "wc", "-l", "/f", "/g"); out !=
+		"       2 /f\n       1 /g\n       3 total\n" {
+		t.Errorf("wc -l: unexpected output: %q", out)
+	}
+	env.Stdin = strings.NewReader("a b c")
+	if out := env.run(Wc, "wc", "-wc"); out != "       3       5\n" {
+		t.Errorf("wc stdin: unexpected output: %q", out)
+	}
+}
+
+func TestTee(t *testing.T) {
+	env := newTestEnv(t)
+	env.Stdin = strings.NewReader("data\n")
+	if out := env.run(Tee, "tee", "/a", "/b"); out != "data\n" {
+		t.Errorf("tee: unexpected output: %q", out)
+	}
+	if env.read("/a") != "data\n" || env.read("/b") != "data\n" {
+		t.Errorf("tee: files not written")
+	}
+	env.Stdin = strings.NewReader("more\n")
+	env.run(Tee, "tee", "-a", "/a")
+	if data := env.read("/a"); data != "data\nmore\n" {
+		t.Errorf("tee -a: unexpected content: %q", data)
+	}
+}
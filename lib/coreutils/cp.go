@@ -0,0 +1,137 @@
+//
+// cp.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+// Cp implements the cp command.
+func Cp(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "rR")
+	if !ok {
+		return 2
+	}
+	if len(operands) < 2 {
+		fmt.Fprintf(env.Stderr, "usage: cp [-r] source... target\n")
+		return 2
+	}
+	_, recursive := opts['r']
+	if _, ok := opts['R']; ok {
+		recursive = true
+	}
+	sources := operands[:len(operands)-1]
+	target, dir, err := env.target(operands[len(operands)-1], len(sources))
+	if err != nil {
+		return env.errorf(args[0], "%s", err)
+	}
+
+	var status int
+	for _, src := range sources {
+		dst := target
+		if dir {
+			dst = path.Join(target, path.Base(src))
+		}
+		err = env.copy(src, dst, recursive)
+		if err != nil {
+			status = env.errorf(args[0], "%s", err)
+		}
+	}
+	return status
+}
+
+// target checks the target operand of cp and mv. If the target is an
+// existing directory, the function returns true and the sources are
+// placed inside the directory.
+func (env *Env) target(target string, count int) (string, bool, error) {
+	info, err := env.FS.Stat(target)
+	if err == nil && info.IsDir() {
+		return target, true, nil
+	}
+	if count > 1 {
+		return "", false, fmt.Errorf("target '%s' is not a directory",
+			target)
+	}
+	return target, false, nil
+}
+
+// copy copies the file src to dst. If recursive is true, directories
+// are copied with their contents.
+func (env *Env) copy(src, dst string, recursive bool) error {
+	info, err := env.FS.Stat(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	absSrc := env.abs(src)
+	absDst := env.abs(dst)
+	if absSrc == absDst {
+		return fmt.Errorf("'%s' and '%s' are the same file", src, dst)
+	}
+	if !info.IsDir() {
+		return env.copyFile(src, dst, info.Mode().Perm())
+	}
+	if !recursive {
+		return fmt.Errorf("-r not specified; omitting directory '%s'", src)
+	}
+	if isSubdir(absSrc, absDst) {
+		return fmt.Errorf("cannot copy directory '%s' into itself", src)
+	}
+	err = env.FS.Mkdir(dst, info.Mode().Perm())
+	if err != nil && !errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+	names, err := env.FS.ReadDir(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	for _, name := range names {
+		err = env.copy(path.Join(src, name), path.Join(dst, name), true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isSubdir tests if the absolute path name is inside the directory
+// dir.
+func isSubdir(dir, name string) bool {
+	if dir == "/" {
+		return true
+	}
+	return len(name) > len(dir) && name[:len(dir)] == dir &&
+		name[len(dir)] == '/'
+}
+
+func (env *Env) copyFile(src, dst string, perm os.FileMode) error {
+	in, err := env.FS.Open(src, os.O_RDONLY, 0)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := env.FS.Open(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+	err = out.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", dst, err)
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+//
+// find.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// findPredicate tests if the file matches an expression of find.
+type findPredicate func(name string, info os.FileInfo) (bool, error)
+
+// Find implements the find command. The expression is a list of
+// primaries which all must match. If the expression does not have
+// -print or -exec actions, the matching files are printed.
+func Find(env *Env, args []string) int {
+	var roots []string
+	idx := 1
+	for ; idx < len(args) && !strings.HasPrefix(args[idx], "-"); idx++ {
+		roots = append(roots, args[idx])
+	}
+	if len(roots) == 0 {
+		roots = []string{"."}
+	}
+	preds, action, err := env.findExpr(args[idx:])
+	if err != nil {
+		env.errorf(args[0], "%s", err)
+		return 2
+	}
+	if !action {
+		preds = append(preds, func(name string, info os.FileInfo) (
+			bool, error) {
+			fmt.Fprintln(env.Stdout, name)
+			return true, nil
+		})
+	}
+
+	var status int
+	for _, root := range roots {
+		err := env.find(root, preds)
+		if err != nil {
+			status = env.errorf(args[0], "%s", err)
+		}
+	}
+	return status
+}
+
+func (env *Env) find(name string, preds []findPredicate) error {
+	info, err := env.FS.Stat(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, pred := range preds {
+		match, err := pred(name, info)
+		if err != nil {
+			return err
+		}
+		if !match {
+			break
+		}
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	names, err := env.FS.ReadDir(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	for _, n := range names {
+		err = env.find(path.Join(name, n), preds)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findExpr parses the find expression. The function returns the
+// predicates and a boolean indicating if the expression has an
+// action printing or processing the files.
+func (env *Env) findExpr(args []string) ([]findPredicate, bool, error) {
+	var preds []findPredicate
+	var action bool
+
+	for i := 0; i < len(args); i++ {
+		primary := args[i]
+		var arg string
+		switch primary {
+		case "-print", "-exec":
+		default:
+			if i+1 >= len(args) {
+				return nil, false, fmt.Errorf("%s: missing argument",
+					primary)
+			}
+			i++
+			arg = args[i]
+		}
+		switch primary {
+		case "-name":
+			if _, err := path.Match(arg, ""); err != nil {
+				return nil, false, fmt.Errorf("-name: %s", err)
+			}
+			preds = append(preds, func(name string, info os.FileInfo) (
+				bool, error) {
+				return path.Match(arg, path.Base(name))
+			})
+
+		case "-type":
+			if len(arg) != 1 || !strings.Contains("fdcbpls", arg) {
+				return nil, false, fmt.Errorf("-type: unknown type '%s'",
+					arg)
+			}
+			preds = append(preds, func(name string, info os.FileInfo) (
+				bool, error) {
+				return fileType(info.Mode()) == arg, nil
+			})
+
+		case "-newer":
+			ref, err := env.FS.Stat(arg)
+			if err != nil {
+				return nil, false, fmt.Errorf("-newer: %s: %w", arg, err)
+			}
+			preds = append(preds, func(name string, info os.FileInfo) (
+				bool, error) {
+				return info.ModTime().After(ref.ModTime()), nil
+			})
+
+		case "-size":
+			pred, err := findSize(arg)
+			if err != nil {
+				return nil, false, err
+			}
+			preds = append(preds, pred)
+
+		case "-print":
+			action = true
+			preds = append(preds, func(name string, info os.FileInfo) (
+				bool, error) {
+				fmt.Fprintln(env.Stdout, name)
+				return true, nil
+			})
+
+		case "-exec":
+			var argv []string
+			for i++; i < len(args) && args[i] != ";"; i++ {
+				argv = append(argv, args[i])
+			}
+			if i >= len(args) || len(argv) == 0 {
+				return nil, false, fmt.Errorf("-exec: missing ';'")
+			}
+			if env.Exec == nil {
+				return nil, false, fmt.Errorf("-exec: not supported")
+			}
+			action = true
+			preds = append(preds, func(name string, info os.FileInfo) (
+				bool, error) {
+				cmd := make([]string, len(argv))
+				for idx, arg := range argv {
+					cmd[idx] = strings.ReplaceAll(arg, "{}", name)
+				}
+				status, err := env.Exec(cmd)
+				if err != nil {
+					return false, fmt.Errorf("%s: %w", cmd[0], err)
+				}
+				return status == 0, nil
+			})
+
+		default:
+			return nil, false, fmt.Errorf("unknown primary '%s'", primary)
+		}
+	}
+	return preds, action, nil
+}
+
+// fileType returns the find -type letter of the file mode.
+func fileType(mode os.FileMode) string {
+	switch {
+	case mode.IsDir():
+		return "d"
+	case mode&os.ModeSymlink != 0:
+		return "l"
+	case mode&os.ModeCharDevice != 0:
+		return "c"
+	case mode&os.ModeDevice != 0:
+		return "b"
+	case mode&os.ModeNamedPipe != 0:
+		return "p"
+	case mode&os.ModeSocket != 0:
+		return "s"
+	default:
+		return "f"
+	}
+}
+
+// findSize creates the predicate for the -size primary. The size is
+// in 512 byte blocks unless it has the c, k, M or G suffix. The size
+// is rounded up to the units. The size can be prefixed with + or -
+// for testing sizes greater or less than the size.
+func findSize(arg string) (findPredicate, error) {
+	var cmp int
+	val := arg
+	if strings.HasPrefix(val, "+") {
+		cmp = 1
+		val = val[1:]
+	} else if strings.HasPrefix(val, "-") {
+		cmp = -1
+		val = val[1:]
+	}
+	unit := int64(512)
+	if len(val) > 0 {
+		switch val[len(val)-1] {
+		case 'c':
+			unit = 1
+		case 'k':
+			unit = 1024
+		case 'M':
+			unit = 1024 * 1024
+		case 'G':
+			unit = 1024 * 1024 * 1024
+		}
+		if unit != 512 {
+			val = val[:len(val)-1]
+		} else if val[len(val)-1] == 'b' {
+			val = val[:len(val)-1]
+		}
+	}
+	n, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("-size: invalid size '%s'", arg)
+	}
+	return func(name string, info os.FileInfo) (bool, error) {
+		size := (info.Size() + unit - 1) / unit
+		switch cmp {
+		case 1:
+			return size > n, nil
+		case -1:
+			return size < n, nil
+		default:
+			return size == n, nil
+		}
+	}, nil
+}
@@ -0,0 +1,80 @@
+//
+// find_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFind(t *testing.T) {
+	env := newTestEnv(t)
+	env.run(Mkdir, "mkdir", "-p", "/d/e")
+	env.write("/d/a.txt", "a")
+	env.write("/d/e/b.txt", strings.Repeat("b", 2000))
+	env.write("/d/e/c.go", "")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"/d"}, "/d /d/a.txt /d/e /d/e/b.txt /d/e/c.go"},
+		{[]string{"/d", "-name", "*.txt"}, "/d/a.txt /d/e/b.txt"},
+		{[]string{"/d", "-type", "d"}, "/d /d/e"},
+		{[]string{"/d", "-type", "f", "-name", "c*"}, "/d/e/c.go"},
+		{[]string{"/d", "-size", "+1"}, "/d/e/b.txt"},
+		{[]string{"/d", "-type", "f", "-size", "-1"}, "/d/e/c.go"},
+		{[]string{"/d", "-size", "1c"}, "/d/a.txt"},
+		{[]string{"/d/e", "-size", "2k"}, "/d/e/b.txt"},
+	}
+	for _, test := range tests {
+		out := env.run(Find, append([]string{"find"}, test.args...)...)
+		got := strings.Join(strings.Fields(out), " ")
+		if got != test.want {
+			t.Errorf("find %v: got %q, expected %q", test.args, got,
+				test.want)
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	env.write("/d/new", "")
+	out := env.run(Find, "find", "/d", "-newer", "/d/e/c.go", "-type", "f")
+	if out != "/d/new\n" {
+		t.Errorf("find -newer: unexpected output: %q", out)
+	}
+
+	env.fail(Find, "find", "/d", "-type", "x")
+	env.fail(Find, "find", "/d", "-bogus")
+	env.fail(Find, "find", "/d", "-exec", "echo", "{}")
+}
+
+func TestFindExec(t *testing.T) {
+	env := newTestEnv(t)
+	env.run(Mkdir, "mkdir", "/d")
+	env.write("/d/a", "")
+	env.write("/d/b", "")
+
+	var cmds []string
+	env.Exec = func(argv []string) (int, error) {
+		cmds = append(cmds, strings.Join(argv, " "))
+		if strings.HasSuffix(argv[len(argv)-1], "a") {
+			return 0, nil
+		}
+		return 1, nil
+	}
+	out := env.run(Find, "find", "/d", "-type", "f", "-exec", "test", "{}",
+		";", "-print")
+	if strings.Join(cmds, ",") != "test /d/a,test /d/b" {
+		t.Errorf("find -exec: unexpected commands: %q", cmds)
+	}
+	if out != "/d/a\n" {
+		t.Errorf("find -exec: unexpected output: %q", out)
+	}
+}
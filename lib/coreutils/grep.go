@@ -0,0 +1,180 @@
+//
+// grep.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+type grepOptions struct {
+	re        *regexp.Regexp
+	number    bool
+	filesOnly bool
+	invert    bool
+	prefix    bool
+}
+
+// Grep implements the grep command. The exit status is 0 if any line
+// matched, 1 if no lines matched, and 2 on errors.
+func Grep(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "inrlv")
+	if !ok {
+		return 2
+	}
+	if len(operands) == 0 {
+		fmt.Fprintf(env.Stderr, "usage: grep [-ilnrv] pattern [file...]\n")
+		return 2
+	}
+	pattern := operands[0]
+	operands = operands[1:]
+	if _, ok := opts['i']; ok {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		env.errorf(args[0], "%s", err)
+		return 2
+	}
+	_, recursive := opts['r']
+	_, number := opts['n']
+	_, filesOnly := opts['l']
+	_, invert := opts['v']
+	if len(operands) == 0 && recursive {
+		operands = []string{"."}
+	}
+	g := &grepOptions{
+		re:        re,
+		number:    number,
+		filesOnly: filesOnly,
+		invert:    invert,
+		prefix:    len(operands) > 1 || recursive,
+	}
+
+	if len(operands) == 0 {
+		matched, err := g.grep(env.Stdout, env.Stdin, "(standard input)")
+		if err != nil {
+			env.errorf(args[0], "%s", err)
+			return 2
+		}
+		if matched {
+			return 0
+		}
+		return 1
+	}
+
+	status := 1
+	for _, name := range operands {
+		err := env.walk(name, recursive, func(name string) error {
+			in, err := env.open(name)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+			matched, err := g.grep(env.Stdout, in, name)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if matched && status == 1 {
+				status = 0
+			}
+			return nil
+		})
+		if err != nil {
+			env.errorf(args[0], "%s", err)
+			status = 2
+		}
+	}
+	return status
+}
+
+// walk calls f for the named file. If the file is a directory and
+// recursive is true, f is called for all regular files in the
+// directory tree. Otherwise directories are reported as errors. The
+// named file is passed to f even if it is a device.
+func (env *Env) walk(name string, recursive bool, f func(string) error) error {
+	if name == "-" {
+		return f(name)
+	}
+	info, err := env.FS.Stat(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if !info.IsDir() {
+		return f(name)
+	}
+	if !recursive {
+		return fmt.Errorf("%s: is a directory", name)
+	}
+	return env.walkDir(name, f)
+}
+
+// walkDir calls f for all regular files in the directory tree
+// dir. The devices and other special files are skipped since reading
+// them can block or never end.
+func (env *Env) walkDir(dir string, f func(string) error) error {
+	names, err := env.FS.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	var result error
+	for _, n := range names {
+		name := path.Join(dir, n)
+		info, err := env.FS.Stat(name)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", name, err)
+		} else if info.IsDir() {
+			err = env.walkDir(name, f)
+		} else if info.Mode().IsRegular() {
+			err = f(name)
+		}
+		if err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// grep searches the input for the matching lines. The function
+// returns true if any line matched.
+func (g *grepOptions) grep(out io.Writer, in io.Reader, name string) (
+	bool, error) {
+
+	var matched bool
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1024*1024)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := scanner.Text()
+		if g.re.MatchString(line) == g.invert {
+			continue
+		}
+		matched = true
+		if g.filesOnly {
+			fmt.Fprintln(out, name)
+			return true, nil
+		}
+		var sb strings.Builder
+		if g.prefix {
+			sb.WriteString(name)
+			sb.WriteByte(':')
+		}
+		if g.number {
+			fmt.Fprintf(&sb, "%d:", lineno)
+		}
+		sb.WriteString(line)
+		sb.WriteByte('\n')
+		io.WriteString(out, sb.String())
+	}
+	return matched, scanner.Err()
+}
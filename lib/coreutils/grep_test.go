@@ -0,0 +1,88 @@
+//
+// grep_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestGrep(t *testing.T) {
+	env := newTestEnv(t)
+	env.run(Mkdir, "mkdir", "-p", "/d/e")
+	env.write("/d/a", "Hello\nworld\nhello again\n")
+	env.write("/d/e/b", "nothing\nhello\n")
+
+	if out := env.run(Grep, "grep", "hello", "/d/a"); out != "hello again\n" {
+		t.Errorf("grep: unexpected output: %q", out)
+	}
+	if out := env.run(Grep, "grep", "-in", "hello", "/d/a"); out !=
+		"1:Hello\n3:hello again\n" {
+		t.Errorf("grep -in: unexpected output: %q", out)
+	}
+	if out := env.run(Grep, "grep", "-v", "again", "/d/a"); out !=
+		"Hello\nworld\n" {
+		t.Errorf("grep -v: unexpected output: %q", out)
+	}
+	if out := env.run(Grep, "grep", "-r", "^hello", "/d"); out !=
+		"/d/a:hello again\n/d/e/b:hello\n" {
+		t.Errorf("grep -r: unexpected output: %q", out)
+	}
+	if out := env.run(Grep, "grep", "-rl", "o", "/d"); out !=
+		"/d/a\n/d/e/b\n" {
+		t.Errorf("grep -rl: unexpected output: %q", out)
+	}
+	env.Stdin = strings.NewReader("foo\nbar\n")
+	if out := env.run(Grep, "grep", "a."); out != "bar\n" {
+		t.Errorf("grep stdin: unexpected output: %q", out)
+	}
+
+	if status := Grep(env.Env, []string{"grep", "xyz", "/d/a"}); status != 1 {
+		t.Errorf("grep no match: status %d, expected 1", status)
+	}
+	if status := Grep(env.Env, []string{"grep", "x", "/d"}); status != 2 {
+		t.Errorf("grep directory: status %d, expected 2", status)
+	}
+	if status := Grep(env.Env, []string{"grep", "("}); status != 2 {
+		t.Errorf("grep invalid regexp: status %d, expected 2", status)
+	}
+}
+
+// countReader counts the reads of a device.
+type countReader struct {
+	reads int
+	r     io.Reader
+}
+
+func (c *countReader) Read(p []byte) (int, error) {
+	c.reads++
+	return c.r.Read(p)
+}
+
+func TestGrepDevices(t *testing.T) {
+	env := newTestEnv(t)
+	env.run(Mkdir, "mkdir", "/d")
+	env.write("/d/a", "dev\n")
+	dev := &countReader{
+		r: strings.NewReader("dev\n"),
+	}
+	if err := env.FS.(*memFS).mknod("/d/zero", dev); err != nil {
+		t.Fatal(err)
+	}
+	if out := env.run(Grep, "grep", "-r", "dev", "/d"); out != "/d/a:dev\n" {
+		t.Errorf("grep -r: unexpected output: %q", out)
+	}
+	if dev.reads != 0 {
+		t.Errorf("grep -r: device read")
+	}
+	if out := env.run(Grep, "grep", "dev", "/d/zero"); out != "dev\n" {
+		t.Errorf("grep device: unexpected output: %q", out)
+	}
+}
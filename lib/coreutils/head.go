@@ -0,0 +1,103 @@
+//
+// head.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Head implements the head command.
+func Head(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "n:c:")
+	if !ok {
+		return 2
+	}
+	lines, bytes, ok := env.count(args[0], opts)
+	if !ok {
+		return 2
+	}
+	if len(operands) == 0 {
+		operands = []string{"-"}
+	}
+
+	var status int
+	for idx, name := range operands {
+		if len(operands) > 1 {
+			if idx > 0 {
+				fmt.Fprintln(env.Stdout)
+			}
+			fmt.Fprintf(env.Stdout, "==> %s <==\n", name)
+		}
+		in, err := env.open(name)
+		if err != nil {
+			status = env.errorf(args[0], "%s: %s", name, err)
+			continue
+		}
+		if bytes >= 0 {
+			_, err = io.CopyN(env.Stdout, in, bytes)
+			if err == io.EOF {
+				err = nil
+			}
+		} else {
+			err = headLines(env.Stdout, in, lines)
+		}
+		in.Close()
+		if err != nil {
+			status = env.errorf(args[0], "%s: %s", name, err)
+		}
+	}
+	return status
+}
+
+func headLines(out io.Writer, in io.Reader, lines int64) error {
+	r := bufio.NewReader(in)
+	for ; lines > 0; lines-- {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 {
+			if _, werr := io.WriteString(out, line); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// count parses the -n and -c options of head and tail. The function
+// returns the number of lines and bytes. The bytes is -1 if the -c
+// option was not given.
+func (env *Env) count(cmd string, opts map[byte]string) (int64, int64, bool) {
+	lines := int64(10)
+	bytes := int64(-1)
+
+	for _, opt := range []byte{'n', 'c'} {
+		val, ok := opts[opt]
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil || n < 0 {
+			env.errorf(cmd, "invalid number: '%s'", val)
+			return 0, 0, false
+		}
+		if opt == 'n' {
+			lines = n
+		} else {
+			bytes = n
+		}
+	}
+	return lines, bytes, true
+}
@@ -0,0 +1,325 @@
+//
+// memfs_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// memNode implements a file, a directory, or a device of the memFS.
+type memNode struct {
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte
+	entries map[string]*memNode
+	dev     io.Reader
+}
+
+// memFS implements the FS interface with an in-memory filesystem.
+// The relative names are resolved from the root directory.
+type memFS struct {
+	root *memNode
+}
+
+func newMemFS() *memFS {
+	return &memFS{
+		root: &memNode{
+			mode:    os.ModeDir | 0755,
+			modTime: time.Now(),
+			entries: make(map[string]*memNode),
+		},
+	}
+}
+
+func splitPath(name string) []string {
+	name = path.Clean("/" + name)
+	if name == "/" {
+		return nil
+	}
+	return strings.Split(name[1:], "/")
+}
+
+func (m *memFS) lookup(name string) (*memNode, error) {
+	n := m.root
+	for _, part := range splitPath(name) {
+		if !n.mode.IsDir() {
+			return nil, syscall.ENOTDIR
+		}
+		child, ok := n.entries[part]
+		if !ok {
+			return nil, syscall.ENOENT
+		}
+		n = child
+	}
+	return n, nil
+}
+
+// parent returns the parent directory and the base name of name.
+func (m *memFS) parent(name string) (*memNode, string, error) {
+	parts := splitPath(name)
+	if len(parts) == 0 {
+		return nil, "", syscall.EINVAL
+	}
+	dir, err := m.lookup(strings.Join(parts[:len(parts)-1], "/"))
+	if err != nil {
+		return nil, "", err
+	}
+	if !dir.mode.IsDir() {
+		return nil, "", syscall.ENOTDIR
+	}
+	return dir, parts[len(parts)-1], nil
+}
+
+// mknod creates a device which reads its data from r.
+func (m *memFS) mknod(name string, r io.Reader) error {
+	dir, base, err := m.parent(name)
+	if err != nil {
+		return err
+	}
+	dir.entries[base] = &memNode{
+		mode:    os.ModeDevice | 0666,
+		modTime: time.Now(),
+		dev:     r,
+	}
+	return nil
+}
+
+func (m *memFS) Open(name string, flag int, perm os.FileMode) (
+	io.ReadWriteCloser, error) {
+
+	n, err := m.lookup(name)
+	if err != nil {
+		if err != syscall.ENOENT || flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+		dir, base, err := m.parent(name)
+		if err != nil {
+			return nil, err
+		}
+		n = &memNode{
+			mode:    perm,
+			modTime: time.Now(),
+		}
+		dir.entries[base] = n
+	} else if n.mode.IsDir() && flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return nil, syscall.EISDIR
+	}
+	if flag&os.O_TRUNC != 0 {
+		n.data = nil
+		n.modTime = time.Now()
+	}
+	f := &memFile{
+		node: n,
+	}
+	if flag&os.O_APPEND != 0 {
+		f.pos = len(n.data)
+	}
+	return f, nil
+}
+
+func (m *memFS) Stat(name string) (os.FileInfo, error) {
+	n, err := m.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return &memInfo{
+		name: path.Base(path.Clean("/" + name)),
+		node: n,
+	}, nil
+}
+
+func (m *memFS) ReadDir(name string) ([]string, error) {
+	n, err := m.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, syscall.ENOTDIR
+	}
+	var result []string
+	for name := range n.entries {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func (m *memFS) Mkdir(name string, perm os.FileMode) error {
+	dir, base, err := m.parent(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := dir.entries[base]; ok {
+		return syscall.EEXIST
+	}
+	dir.entries[base] = &memNode{
+		mode:    os.ModeDir | perm,
+		modTime: time.Now(),
+		entries: make(map[string]*memNode),
+	}
+	return nil
+}
+
+func (m *memFS) remove(name string, isDir bool) error {
+	dir, base, err := m.parent(name)
+	if err != nil {
+		return err
+	}
+	n, ok := dir.entries[base]
+	if !ok {
+		return syscall.ENOENT
+	}
+	if n.mode.IsDir() != isDir {
+		if isDir {
+			return syscall.ENOTDIR
+		}
+		return syscall.EISDIR
+	}
+	if isDir && len(n.entries) > 0 {
+		return syscall.ENOTEMPTY
+	}
+	delete(dir.entries, base)
+	return nil
+}
+
+func (m *memFS) Unlink(name string) error {
+	return m.remove(name, false)
+}
+
+func (m *memFS) Rmdir(name string) error {
+	return m.remove(name, true)
+}
+
+func (m *memFS) Rename(from, to string) error {
+	fromParts := splitPath(from)
+	toParts := splitPath(to)
+	if strings.Join(toParts, "/") == strings.Join(fromParts, "/") {
+		return nil
+	}
+	if len(toParts) > len(fromParts) &&
+		strings.Join(toParts[:len(fromParts)], "/") ==
+			strings.Join(fromParts, "/") {
+		return syscall.EINVAL
+	}
+	fromDir, fromBase, err := m.parent(from)
+	if err != nil {
+		return err
+	}
+	n, ok := fromDir.entries[fromBase]
+	if !ok {
+		return syscall.ENOENT
+	}
+	toDir, toBase, err := m.parent(to)
+	if err != nil {
+		return err
+	}
+	if old, ok := toDir.entries[toBase]; ok && old.mode.IsDir() {
+		return syscall.EISDIR
+	}
+	delete(fromDir.entries, fromBase)
+	toDir.entries[toBase] = n
+	return nil
+}
+
+func (m *memFS) Truncate(name string, size int64) error {
+	n, err := m.lookup(name)
+	if err != nil {
+		return err
+	}
+	if n.mode.IsDir() {
+		return syscall.EISDIR
+	}
+	if int(size) < len(n.data) {
+		n.data = n.data[:size]
+	} else {
+		n.data = append(n.data, make([]byte, int(size)-len(n.data))...)
+	}
+	n.modTime = time.Now()
+	return nil
+}
+
+func (m *memFS) Getwd() (string, error) {
+	return "/", nil
+}
+
+// memFile implements an open file of the memFS.
+type memFile struct {
+	node *memNode
+	pos  int
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.node.dev != nil {
+		return f.node.dev.Read(p)
+	}
+	if f.node.mode.IsDir() {
+		return 0, syscall.EISDIR
+	}
+	if f.pos >= len(f.node.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[f.pos:])
+	f.pos += n
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if f.node.dev != nil {
+		return len(p), nil
+	}
+	end := f.pos + len(p)
+	if end > len(f.node.data) {
+		f.node.data = append(f.node.data,
+			make([]byte, end-len(f.node.data))...)
+	}
+	copy(f.node.data[f.pos:], p)
+	f.pos = end
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+// memInfo implements the os.FileInfo for the memFS files.
+type memInfo struct {
+	name string
+	node *memNode
+}
+
+func (info *memInfo) Name() string {
+	return info.name
+}
+
+func (info *memInfo) Size() int64 {
+	return int64(len(info.node.data))
+}
+
+func (info *memInfo) Mode() os.FileMode {
+	return info.node.mode
+}
+
+func (info *memInfo) ModTime() time.Time {
+	return info.node.modTime
+}
+
+func (info *memInfo) IsDir() bool {
+	return info.node.mode.IsDir()
+}
+
+func (info *memInfo) Sys() interface{} {
+	return nil
+}
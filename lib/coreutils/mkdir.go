@@ -0,0 +1,106 @@
+//
+// mkdir.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Mkdir implements the mkdir command.
+func Mkdir(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "p")
+	if !ok {
+		return 2
+	}
+	if len(operands) == 0 {
+		fmt.Fprintf(env.Stderr, "usage: mkdir [-p] directory...\n")
+		return 2
+	}
+	_, parents := opts['p']
+
+	var status int
+	for _, name := range operands {
+		var err error
+		if parents {
+			err = env.mkdirAll(name)
+		} else {
+			err = env.FS.Mkdir(name, 0755)
+		}
+		if err != nil {
+			status = env.errorf(args[0], "%s: %s", name, err)
+		}
+	}
+	return status
+}
+
+// mkdirAll creates the directory and all its missing parent
+// directories.
+func (env *Env) mkdirAll(name string) error {
+	var dir string
+	if strings.HasPrefix(name, "/") {
+		dir = "/"
+	}
+	for _, part := range strings.Split(name, "/") {
+		if len(part) == 0 {
+			continue
+		}
+		dir = path.Join(dir, part)
+		info, err := env.FS.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s: ENOTDIR", dir)
+			}
+			continue
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		err = env.FS.Mkdir(dir, 0755)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rmdir implements the rmdir command.
+func Rmdir(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "p")
+	if !ok {
+		return 2
+	}
+	if len(operands) == 0 {
+		fmt.Fprintf(env.Stderr, "usage: rmdir [-p] directory...\n")
+		return 2
+	}
+	_, parents := opts['p']
+
+	var status int
+	for _, name := range operands {
+		for {
+			err := env.FS.Rmdir(name)
+			if err != nil {
+				status = env.errorf(args[0], "%s: %s", name, err)
+				break
+			}
+			if !parents {
+				break
+			}
+			name = path.Dir(path.Clean(name))
+			if name == "." || name == "/" {
+				break
+			}
+		}
+	}
+	return status
+}
@@ -0,0 +1,69 @@
+//
+// mkmain.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+//go:build ignore
+
+// The mkmain program generates the main programs of the commands
+// which run against the system calls with the standard files. The
+// arguments are the command names and the programs are written to
+// ../../bin/NAME/main.go.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+)
+
+var tmpl = template.Must(template.New("main").Parse(`// Code generated by mkmain.go; DO NOT EDIT.
+
+package main
+
+import (
+	"os"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
+	"github.com/markkurossi/blackbox-os/lib/coreutils"
+)
+
+func main() {
+	env := &coreutils.Env{
+		FS:     bbos.FS{},
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	os.Exit(coreutils.{{.}}(env, os.Args))
+}
+`))
+
+func main() {
+	log.SetFlags(0)
+	for _, name := range os.Args[1:] {
+		var buf bytes.Buffer
+		err := tmpl.Execute(&buf, strings.ToUpper(name[:1])+name[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		data, err := format.Source(buf.Bytes())
+		if err != nil {
+			log.Fatalf("%s: %s", name, err)
+		}
+		file := path.Join("..", "..", "bin", name, "main.go")
+		err = os.WriteFile(file, data, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%s\n", file)
+	}
+}
@@ -0,0 +1,62 @@
+//
+// mv.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"syscall"
+)
+
+// Mv implements the mv command. Files are moved across filesystems
+// by copying them and removing the originals.
+func Mv(env *Env, args []string) int {
+	_, operands, ok := env.getopt(args, "")
+	if !ok {
+		return 2
+	}
+	if len(operands) < 2 {
+		fmt.Fprintf(env.Stderr, "usage: mv source... target\n")
+		return 2
+	}
+	sources := operands[:len(operands)-1]
+	target, dir, err := env.target(operands[len(operands)-1], len(sources))
+	if err != nil {
+		return env.errorf(args[0], "%s", err)
+	}
+
+	var status int
+	for _, src := range sources {
+		dst := target
+		if dir {
+			dst = path.Join(target, path.Base(src))
+		}
+		err = env.move(src, dst)
+		if err != nil {
+			status = env.errorf(args[0], "%s", err)
+		}
+	}
+	return status
+}
+
+func (env *Env) move(src, dst string) error {
+	err := env.FS.Rename(src, dst)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, syscall.EXDEV) {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	err = env.copy(src, dst, true)
+	if err != nil {
+		return err
+	}
+	return env.remove(src)
+}
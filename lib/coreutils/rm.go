@@ -0,0 +1,94 @@
+//
+// rm.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+)
+
+// Rm implements the rm command.
+func Rm(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "rRf")
+	if !ok {
+		return 2
+	}
+	_, recursive := opts['r']
+	if _, ok := opts['R']; ok {
+		recursive = true
+	}
+	_, force := opts['f']
+	if len(operands) == 0 && !force {
+		fmt.Fprintf(env.Stderr, "usage: rm [-rf] file...\n")
+		return 2
+	}
+
+	var status int
+	for _, name := range operands {
+		switch path.Base(name) {
+		case ".", "..":
+			status = env.errorf(args[0], "refusing to remove '%s'", name)
+			continue
+		}
+		info, err := env.FS.Stat(name)
+		if err != nil {
+			if !force || !errors.Is(err, os.ErrNotExist) {
+				status = env.errorf(args[0], "%s: %s", name, err)
+			}
+			continue
+		}
+		if info.IsDir() {
+			if !recursive {
+				status = env.errorf(args[0], "%s: is a directory", name)
+				continue
+			}
+			err = env.remove(name)
+		} else {
+			err = env.FS.Unlink(name)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if err != nil {
+			status = env.errorf(args[0], "%s", err)
+		}
+	}
+	return status
+}
+
+// remove removes the named file. Directories are removed with their
+// contents.
+func (env *Env) remove(name string) error {
+	info, err := env.FS.Stat(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if !info.IsDir() {
+		err = env.FS.Unlink(name)
+	} else {
+		var names []string
+		names, err = env.FS.ReadDir(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, n := range names {
+			err = env.remove(path.Join(name, n))
+			if err != nil {
+				return err
+			}
+		}
+		err = env.FS.Rmdir(name)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+//
+// sed.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sedCommand implements a sed editing command with an optional
+// address.
+type sedCommand struct {
+	addr    sedAddress
+	cmd     byte
+	re      *regexp.Regexp
+	repl    string
+	global  bool
+	print   bool
+	hasAddr bool
+}
+
+// sedAddress selects the lines of a command: a line number, the last
+// line ($), or the lines matching a regular expression.
+type sedAddress struct {
+	line int
+	last bool
+	re   *regexp.Regexp
+}
+
+func (addr sedAddress) match(line string, lineno int, last bool) bool {
+	switch {
+	case addr.re != nil:
+		return addr.re.MatchString(line)
+	case addr.last:
+		return last
+	default:
+		return addr.line == lineno
+	}
+}
+
+// Sed implements the sed command with the s, d, and p commands.
+func Sed(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "ne:")
+	if !ok {
+		return 2
+	}
+	script, ok := opts['e']
+	if !ok {
+		if len(operands) == 0 {
+			fmt.Fprintf(env.Stderr, "usage: sed [-n] script [file...]\n")
+			return 2
+		}
+		script = operands[0]
+		operands = operands[1:]
+	}
+	_, quiet := opts['n']
+
+	cmds, err := parseSed(script)
+	if err != nil {
+		env.errorf(args[0], "%s", err)
+		return 2
+	}
+
+	var readers []io.Reader
+	var status int
+	if len(operands) == 0 {
+		operands = []string{"-"}
+	}
+	for _, name := range operands {
+		in, err := env.open(name)
+		if err != nil {
+			status = env.errorf(args[0], "%s: %s", name, err)
+			continue
+		}
+		defer in.Close()
+		readers = append(readers, in)
+	}
+	err = sed(env.Stdout, io.MultiReader(readers...), cmds, quiet)
+	if err != nil {
+		status = env.errorf(args[0], "%s", err)
+	}
+	return status
+}
+
+func sed(out io.Writer, in io.Reader, cmds []*sedCommand, quiet bool) error {
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+
+	r := bufio.NewReader(in)
+	next, err := r.ReadString('\n')
+
+	for lineno := 1; len(next) > 0; lineno++ {
+		line := next
+		if err != nil && err != io.EOF {
+			return err
+		}
+		next, err = r.ReadString('\n')
+
+		var nl string
+		if strings.HasSuffix(line, "\n") {
+			line = line[:len(line)-1]
+			nl = "\n"
+		}
+		last := len(next) == 0
+		deleted := false
+
+		for _, c := range cmds {
+			if c.hasAddr && !c.addr.match(line, lineno, last) {
+				continue
+			}
+			switch c.cmd {
+			case 'd':
+				deleted = true
+			case 'p':
+				w.WriteString(line + nl)
+			case 's':
+				var replaced bool
+				line, replaced = c.substitute(line)
+				if replaced && c.print {
+					w.WriteString(line + nl)
+				}
+			}
+			if deleted {
+				break
+			}
+		}
+		if !deleted && !quiet {
+			w.WriteString(line + nl)
+		}
+	}
+	if err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// substitute applies the s command to the line. The function returns
+// the result line and a boolean indicating if the line was modified.
+func (c *sedCommand) substitute(line string) (string, bool) {
+	if c.global {
+		if !c.re.MatchString(line) {
+			return line, false
+		}
+		return c.re.ReplaceAllString(line, c.repl), true
+	}
+	m := c.re.FindStringSubmatchIndex(line)
+	if m == nil {
+		return line, false
+	}
+	var result []byte
+	result = append(result, line[:m[0]]...)
+	result = c.re.ExpandString(result, c.repl, line, m)
+	result = append(result, line[m[1]:]...)
+	return string(result), true
+}
+
+// parseSed parses the sed script. The commands are separated by
+// newlines or semicolons.
+func parseSed(script string) ([]*sedCommand, error) {
+	var cmds []*sedCommand
+
+	p := &sedParser{
+		input: []rune(script),
+	}
+	for {
+		p.skip(" \t\n;")
+		if p.pos >= len(p.input) {
+			return cmds, nil
+		}
+		c := new(sedCommand)
+		addr, ok, err := p.address()
+		if err != nil {
+			return nil, err
+		}
+		c.addr = addr
+		c.hasAddr = ok
+		p.skip(" \t")
+		if p.pos >= len(p.input) {
+			return nil, fmt.Errorf("missing command")
+		}
+		c.cmd = byte(p.input[p.pos])
+		p.pos++
+
+		switch c.cmd {
+		case 'd', 'p':
+
+		case 's':
+			err = p.substitute(c)
+			if err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown command: '%c'", c.cmd)
+		}
+		p.skip(" \t")
+		if p.pos < len(p.input) && !strings.ContainsRune("\n;",
+			p.input[p.pos]) {
+			return nil, fmt.Errorf("extra characters after command")
+		}
+		cmds = append(cmds, c)
+	}
+}
+
+type sedParser struct {
+	input []rune
+	pos   int
+}
+
+func (p *sedParser) skip(chars string) {
+	for p.pos < len(p.input) && strings.ContainsRune(chars, p.input[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *sedParser) address() (sedAddress, bool, error) {
+	var addr sedAddress
+	if p.pos >= len(p.input) {
+		return addr, false, nil
+	}
+	switch ch := p.input[p.pos]; {
+	case ch == '$':
+		p.pos++
+		addr.last = true
+		return addr, true, nil
+
+	case ch == '/':
+		p.pos++
+		pattern, err := p.delimited('/')
+		if err != nil {
+			return addr, false, err
+		}
+		addr.re, err = regexp.Compile(pattern)
+		if err != nil {
+			return addr, false, err
+		}
+		return addr, true, nil
+
+	case ch >= '0' && ch <= '9':
+		start := p.pos
+		for p.pos < len(p.input) && p.input[p.pos] >= '0' &&
+			p.input[p.pos] <= '9' {
+			p.pos++
+		}
+		n, err := strconv.Atoi(string(p.input[start:p.pos]))
+		if err != nil {
+			return addr, false, err
+		}
+		addr.line = n
+		return addr, true, nil
+
+	default:
+		return addr, false, nil
+	}
+}
+
+// delimited reads the input until the unescaped delimiter. The
+// escaped delimiters are replaced with the delimiter character.
+func (p *sedParser) delimited(delim rune) (string, error) {
+	var sb strings.Builder
+	for ; p.pos < len(p.input); p.pos++ {
+		ch := p.input[p.pos]
+		if ch == delim {
+			p.pos++
+			return sb.String(), nil
+		}
+		if ch == '\\' && p.pos+1 < len(p.input) {
+			p.pos++
+			if p.input[p.pos] != delim {
+				sb.WriteRune('\\')
+			}
+			ch = p.input[p.pos]
+		}
+		sb.WriteRune(ch)
+	}
+	return "", fmt.Errorf("unterminated address regex")
+}
+
+func (p *sedParser) substitute(c *sedCommand) error {
+	if p.pos >= len(p.input) {
+		return fmt.Errorf("unterminated `s' command")
+	}
+	delim := p.input[p.pos]
+	p.pos++
+	pattern, err := p.delimited(delim)
+	if err != nil {
+		return fmt.Errorf("unterminated `s' command")
+	}
+	repl, err := p.delimited(delim)
+	if err != nil {
+		return fmt.Errorf("unterminated `s' command")
+	}
+	var flags string
+	for p.pos < len(p.input) && strings.ContainsRune("gpI", p.input[p.pos]) {
+		flags += string(p.input[p.pos])
+		p.pos++
+	}
+	if strings.ContainsRune(flags, 'I') {
+		pattern = "(?i)" + pattern
+	}
+	c.re, err = regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	c.repl = sedReplacement(repl)
+	c.global = strings.ContainsRune(flags, 'g')
+	c.print = strings.ContainsRune(flags, 'p')
+	return nil
+}
+
+// sedReplacement converts the sed replacement into the regexp
+// template syntax: & is the matched text and \1-\9 are the
+// subexpression matches.
+func sedReplacement(repl string) string {
+	var sb strings.Builder
+	for i := 0; i < len(repl); i++ {
+		switch ch := repl[i]; ch {
+		case '$':
+			sb.WriteString("$$")
+		case '&':
+			sb.WriteString("${0}")
+		case '\\':
+			if i+1 >= len(repl) {
+				sb.WriteByte(ch)
+				break
+			}
+			i++
+			switch next := repl[i]; {
+			case next >= '0' && next <= '9':
+				fmt.Fprintf(&sb, "${%c}", next)
+			case next == 'n':
+				sb.WriteByte('\n')
+			case next == 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(next)
+			}
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
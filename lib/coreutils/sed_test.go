@@ -0,0 +1,56 @@
+//
+// sed_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSed(t *testing.T) {
+	input := "one two\nthree two\nfour\n"
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"s/two/2/"}, "one 2\nthree 2\nfour\n"},
+		{[]string{"s/o/0/g"}, "0ne tw0\nthree tw0\nf0ur\n"},
+		{[]string{"s/o/0/"}, "0ne two\nthree tw0\nf0ur\n"},
+		{[]string{"s|(t)|[&]|"}, "one [t]wo\n[t]hree two\nfour\n"},
+		{[]string{"s/(\\w+) (\\w+)/\\2 \\1/"}, "two one\ntwo three\nfour\n"},
+		{[]string{"s/\\//x/"}, input},
+		{[]string{"2d"}, "one two\nfour\n"},
+		{[]string{"/two/d"}, "four\n"},
+		{[]string{"$d"}, "one two\nthree two\n"},
+		{[]string{"-n", "/^t/p"}, "three two\n"},
+		{[]string{"-n", "s/four/4/p"}, "4\n"},
+		{[]string{"1p;1d"}, "one two\nthree two\nfour\n"},
+		{[]string{"-e", "s/TWO/2/Ig"}, "one 2\nthree 2\nfour\n"},
+	}
+	for _, test := range tests {
+		env := newTestEnv(t)
+		env.Stdin = strings.NewReader(input)
+		out := env.run(Sed, append([]string{"sed"}, test.args...)...)
+		if out != test.want {
+			t.Errorf("sed %q: got %q, expected %q", test.args, out, test.want)
+		}
+	}
+}
+
+func TestSedFiles(t *testing.T) {
+	env := newTestEnv(t)
+	env.write("/a", "a\n")
+	env.write("/b", "b")
+	if out := env.run(Sed, "sed", "s/$/!/", "/a", "/b"); out != "a!\nb!" {
+		t.Errorf("sed files: unexpected output: %q", out)
+	}
+	env.fail(Sed, "sed", "s/a/b", "/a")
+	env.fail(Sed, "sed", "x", "/a")
+	env.fail(Sed, "sed", "p", "/missing")
+}
@@ -0,0 +1,79 @@
+//
+// tail.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"fmt"
+	"io"
+)
+
+// Tail implements the tail command.
+func Tail(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "n:c:")
+	if !ok {
+		return 2
+	}
+	lines, count, ok := env.count(args[0], opts)
+	if !ok {
+		return 2
+	}
+	if len(operands) == 0 {
+		operands = []string{"-"}
+	}
+
+	var status int
+	for idx, name := range operands {
+		if len(operands) > 1 {
+			if idx > 0 {
+				fmt.Fprintln(env.Stdout)
+			}
+			fmt.Fprintf(env.Stdout, "==> %s <==\n", name)
+		}
+		in, err := env.open(name)
+		if err != nil {
+			status = env.errorf(args[0], "%s: %s", name, err)
+			continue
+		}
+		data, err := io.ReadAll(in)
+		in.Close()
+		if err != nil {
+			status = env.errorf(args[0], "%s: %s", name, err)
+			continue
+		}
+		if count >= 0 {
+			if int64(len(data)) > count {
+				data = data[int64(len(data))-count:]
+			}
+		} else {
+			data = tailLines(data, lines)
+		}
+		env.Stdout.Write(data)
+	}
+	return status
+}
+
+// tailLines returns the last lines of data.
+func tailLines(data []byte, lines int64) []byte {
+	if lines == 0 {
+		return nil
+	}
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+	for idx := end - 1; idx >= 0; idx-- {
+		if data[idx] == '\n' {
+			lines--
+			if lines == 0 {
+				return data[idx+1:]
+			}
+		}
+	}
+	return data
+}
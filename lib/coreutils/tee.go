@@ -0,0 +1,50 @@
+//
+// tee.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"io"
+	"os"
+)
+
+// Tee implements the tee command.
+func Tee(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "a")
+	if !ok {
+		return 2
+	}
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if _, ok := opts['a']; ok {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	var status int
+	writers := []io.Writer{env.Stdout}
+	var files []io.Closer
+
+	for _, name := range operands {
+		f, err := env.FS.Open(name, flag, 0644)
+		if err != nil {
+			status = env.errorf(args[0], "%s: %s", name, err)
+			continue
+		}
+		writers = append(writers, f)
+		files = append(files, f)
+	}
+	_, err := io.Copy(io.MultiWriter(writers...), env.Stdin)
+	if err != nil {
+		status = env.errorf(args[0], "%s", err)
+	}
+	for _, f := range files {
+		if err := f.Close(); err != nil {
+			status = env.errorf(args[0], "%s", err)
+		}
+	}
+	return status
+}
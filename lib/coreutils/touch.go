@@ -0,0 +1,60 @@
+//
+// touch.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Touch implements the touch command. The modification time of an
+// existing file is updated by truncating the file to its current
+// size.
+func Touch(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "c")
+	if !ok {
+		return 2
+	}
+	if len(operands) == 0 {
+		fmt.Fprintf(env.Stderr, "usage: touch [-c] file...\n")
+		return 2
+	}
+	_, noCreate := opts['c']
+
+	var status int
+	for _, name := range operands {
+		err := env.touch(name, noCreate)
+		if err != nil {
+			status = env.errorf(args[0], "%s: %s", name, err)
+		}
+	}
+	return status
+}
+
+func (env *Env) touch(name string, noCreate bool) error {
+	info, err := env.FS.Stat(name)
+	if err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return env.FS.Truncate(name, info.Size())
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if noCreate {
+		return nil
+	}
+	f, err := env.FS.Open(name, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
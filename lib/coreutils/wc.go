@@ -0,0 +1,115 @@
+//
+// wc.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package coreutils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
+
+type wcCounts struct {
+	lines int64
+	words int64
+	bytes int64
+}
+
+// Wc implements the wc command.
+func Wc(env *Env, args []string) int {
+	opts, operands, ok := env.getopt(args, "lwc")
+	if !ok {
+		return 2
+	}
+	_, lines := opts['l']
+	_, words := opts['w']
+	_, bytes := opts['c']
+	if !lines && !words && !bytes {
+		lines = true
+		words = true
+		bytes = true
+	}
+	show := func(c wcCounts, name string) {
+		if lines {
+			fmt.Fprintf(env.Stdout, "%8d", c.lines)
+		}
+		if words {
+			fmt.Fprintf(env.Stdout, "%8d", c.words)
+		}
+		if bytes {
+			fmt.Fprintf(env.Stdout, "%8d", c.bytes)
+		}
+		if len(name) > 0 {
+			fmt.Fprintf(env.Stdout, " %s", name)
+		}
+		fmt.Fprintln(env.Stdout)
+	}
+
+	if len(operands) == 0 {
+		c, err := wc(env.Stdin)
+		if err != nil {
+			return env.errorf(args[0], "%s", err)
+		}
+		show(c, "")
+		return 0
+	}
+
+	var status int
+	var total wcCounts
+	for _, name := range operands {
+		in, err := env.open(name)
+		if err != nil {
+			status = env.errorf(args[0], "%s: %s", name, err)
+			continue
+		}
+		c, err := wc(in)
+		in.Close()
+		if err != nil {
+			status = env.errorf(args[0], "%s: %s", name, err)
+			continue
+		}
+		show(c, name)
+		total.lines += c.lines
+		total.words += c.words
+		total.bytes += c.bytes
+	}
+	if len(operands) > 1 {
+		show(total, "total")
+	}
+	return status
+}
+
+func wc(in io.Reader) (wcCounts, error) {
+	var c wcCounts
+	var inWord bool
+
+	r := bufio.NewReader(in)
+	for {
+		ch, size, err := r.ReadRune()
+		if err == io.EOF {
+			return c, nil
+		} else if err != nil {
+			return c, err
+		}
+		if ch == utf8.RuneError && size == 1 {
+			ch = 0
+		}
+		c.bytes += int64(size)
+		if ch == '\n' {
+			c.lines++
+		}
+		if unicode.IsSpace(ch) {
+			inWord = false
+		} else if !inWord {
+			inWord = true
+			c.words++
+		}
+	}
+}
@@ -0,0 +1,192 @@
+//
+// complete.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package readline
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Completer returns the completion candidates for the word. The args
+// contain the words of the command line before the word.
+type Completer func(args []string, word string) []string
+
+// Completion implements programmable completion. The first word of
+// the command line is completed with the Command completer. The
+// arguments are completed with the completer registered for the
+// command, or with the Default completer.
+type Completion struct {
+	Command  Completer
+	Default  Completer
+	commands map[string]Completer
+}
+
+// NewCompletion creates a new completion which completes command
+// arguments as file names.
+func NewCompletion() *Completion {
+	return &Completion{
+		Default:  CompleteFiles,
+		commands: make(map[string]Completer),
+	}
+}
+
+// Register registers the completer for the arguments of the command.
+func (c *Completion) Register(command string, completer Completer) {
+	c.commands[command] = completer
+}
+
+// Complete completes the word. It returns the expanded word and the
+// completion candidates if the word has more than one possible
+// completion.
+func (c *Completion) Complete(args []string, word string) (
+	string, []string) {
+
+	var completer Completer
+	if len(args) == 0 {
+		completer = c.Command
+	} else if f, ok := c.commands[args[0]]; ok {
+		completer = f
+	} else {
+		completer = c.Default
+	}
+	if completer == nil {
+		return word, nil
+	}
+	return Expand(word, completer(args, word))
+}
+
+// Expand returns the expansion of the word for the completion
+// candidates. The candidates are returned if the word has more than
+// one possible completion.
+func Expand(word string, candidates []string) (string, []string) {
+	candidates = Unique(candidates)
+	switch len(candidates) {
+	case 0:
+		return word, nil
+	case 1:
+		return candidates[0], nil
+	default:
+		prefix := CommonPrefix(candidates)
+		if len(prefix) < len(word) {
+			prefix = word
+		}
+		return prefix, candidates
+	}
+}
+
+// Unique sorts the strings and removes duplicates.
+func Unique(values []string) []string {
+	sorted := append([]string(nil), values...)
+	sort.Strings(sorted)
+
+	var result []string
+	for idx, v := range sorted {
+		if idx == 0 || v != sorted[idx-1] {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// FilterPrefix returns the values which have the prefix.
+func FilterPrefix(values []string, prefix string) []string {
+	var result []string
+	for _, v := range values {
+		if strings.HasPrefix(v, prefix) {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// CommonPrefix returns the longest common prefix of the values.
+func CommonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			_, size := lastRune(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+func lastRune(s string) (rune, int) {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return 0, 0
+	}
+	r := runes[len(runes)-1]
+	return r, len(string(r))
+}
+
+// CompleteFiles completes the word as a file name. The directory
+// names are completed with the trailing '/' character.
+func CompleteFiles(args []string, word string) []string {
+	return completeFiles(word, false)
+}
+
+// CompleteDirs completes the word as a directory name.
+func CompleteDirs(args []string, word string) []string {
+	return completeFiles(word, true)
+}
+
+func completeFiles(word string, dirsOnly bool) []string {
+	dir, prefix := path.Split(word)
+	readDir := dir
+	if len(readDir) == 0 {
+		readDir = "."
+	}
+	files, err := ioutil.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	var result []string
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+		if f.IsDir() {
+			name += "/"
+		} else if dirsOnly {
+			continue
+		}
+		result = append(result, dir+name)
+	}
+	return result
+}
+
+// CompleteVariables completes the environment variable names. The
+// word must start with the '$' character.
+func CompleteVariables(args []string, word string) []string {
+	if !strings.HasPrefix(word, "$") {
+		return nil
+	}
+	var result []string
+	for _, env := range os.Environ() {
+		idx := strings.IndexByte(env, '=')
+		if idx <= 0 {
+			continue
+		}
+		if strings.HasPrefix(env[:idx], word[1:]) {
+			result = append(result, "$"+env[:idx])
+		}
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+//
+// complete_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package readline
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		word       string
+		candidates []string
+		expanded   string
+		display    []string
+	}{
+		{"x", nil, "x", nil},
+		{"l", []string{"ls"}, "ls", nil},
+		{"l", []string{"ls", "ls"}, "ls", nil},
+		{"l", []string{"less", "ls", "ln"}, "l", []string{"less", "ln", "ls"}},
+		{"a", []string{"abcx", "abcy"}, "abc", []string{"abcx", "abcy"}},
+		{"ä", []string{"äöx", "äöy"}, "äö", []string{"äöx", "äöy"}},
+	}
+	for _, test := range tests {
+		expanded, display := Expand(test.word, test.candidates)
+		if expanded != test.expanded || !reflect.DeepEqual(display, test.display) {
+			t.Errorf("Expand(%q, %q)=%q,%q, expected %q,%q", test.word,
+				test.candidates, expanded, display, test.expanded, test.display)
+		}
+	}
+	if p := CommonPrefix([]string{"äx", "äy"}); p != "ä" {
+		t.Errorf("CommonPrefix=%q, expected ä", p)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c := NewCompletion()
+	c.Command = func(args []string, word string) []string {
+		return FilterPrefix([]string{"ssh", "sysctl", "sh"}, word)
+	}
+	c.Register("sysctl", func(args []string, word string) []string {
+		return FilterPrefix([]string{"kernel.power", "ws.proxy"}, word)
+	})
+
+	if w, _ := c.Complete(nil, "sy"); w != "sysctl" {
+		t.Errorf("command: got %q, expected sysctl", w)
+	}
+	if w, l := c.Complete(nil, "s"); w != "s" || len(l) != 3 {
+		t.Errorf("command: got %q,%q", w, l)
+	}
+	if w, _ := c.Complete([]string{"sysctl"}, "k"); w != "kernel.power" {
+		t.Errorf("sysctl: got %q, expected kernel.power", w)
+	}
+}
+
+func TestCompleteFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "complete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, d := range []string{"src", "sub"} {
+		if err := os.Mkdir(path.Join(dir, d), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []string{"setup.sh", ".hidden"} {
+		if err := ioutil.WriteFile(path.Join(dir, f), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := Unique(CompleteFiles(nil, dir+"/s"))
+	expected := []string{dir + "/setup.sh", dir + "/src/", dir + "/sub/"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("CompleteFiles=%q, expected %q", files, expected)
+	}
+	dirs := Unique(CompleteDirs(nil, dir+"/"))
+	expected = []string{dir + "/src/", dir + "/sub/"}
+	if !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("CompleteDirs=%q, expected %q", dirs, expected)
+	}
+	hidden := CompleteFiles(nil, dir+"/.h")
+	if !reflect.DeepEqual(hidden, []string{dir + "/.hidden"}) {
+		t.Errorf("CompleteFiles=%q, expected hidden file", hidden)
+	}
+}
+
+func TestCompleteVariables(t *testing.T) {
+	os.Setenv("COMPLETE_TEST", "1")
+	vars := CompleteVariables(nil, "$COMPLETE_T")
+	if !reflect.DeepEqual(vars, []string{"$COMPLETE_TEST"}) {
+		t.Errorf("CompleteVariables=%q", vars)
+	}
+}
@@ -0,0 +1,235 @@
+//
+// display.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package readline
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/markkurossi/vt100"
+)
+
+const (
+	defaultHeight     = 24
+	defaultQueryItems = 100
+	columnSpacing     = 2
+	selectStart       = "\x1b[7m"
+	selectEnd         = "\x1b[0m"
+)
+
+// columnize formats the items into columns which fit into the width
+// terminal columns. The items are ordered by columns, similarly to
+// ls. The item selected is highlighted; use -1 to disable
+// highlighting.
+func columnize(items []string, width, selected int) []string {
+	if len(items) == 0 {
+		return nil
+	}
+	var max int
+	for _, item := range items {
+		if w := displayWidth(item); w > max {
+			max = w
+		}
+	}
+	colWidth := max + columnSpacing
+	cols := (width + columnSpacing) / colWidth
+	if cols < 1 {
+		cols = 1
+	}
+	rows := (len(items) + cols - 1) / cols
+	cols = (len(items) + rows - 1) / rows
+
+	var lines []string
+	for row := 0; row < rows; row++ {
+		var line bytes.Buffer
+		var pad int
+		for col := 0; col < cols; col++ {
+			idx := col*rows + row
+			if idx >= len(items) {
+				break
+			}
+			line.WriteString(strings.Repeat(" ", pad))
+			item := items[idx]
+			if idx == selected {
+				line.WriteString(selectStart + item + selectEnd)
+			} else {
+				line.WriteString(item)
+			}
+			pad = colWidth - displayWidth(item)
+		}
+		lines = append(lines, line.String())
+	}
+	return lines
+}
+
+// listCompletions lists the completions below the line. Long lists
+// are confirmed with the "Display all" query and shown one screen at
+// a time.
+func (rl *Readline) listCompletions(completions []string, prompt string) {
+	// Move the cursor to the end of the line.
+	rl.draw(prompt, rl.buf, len(rl.buf))
+	fmt.Fprintf(rl.stdout, "\r\n")
+
+	rl.pages = columnize(completions, rl.width, -1)
+	rl.pageLine = 0
+
+	if len(completions) > rl.QueryItems {
+		fmt.Fprintf(rl.stdout, "Display all %d possibilities? (y or n)",
+			len(completions))
+		rl.state = rlQuery
+		return
+	}
+	rl.showPage(rl.height-1, prompt)
+}
+
+// showPage shows the next count lines of the completion list. If
+// the list continues, it shows the "--More--" prompt, otherwise the
+// line is redrawn below the list.
+func (rl *Readline) showPage(count int, prompt string) {
+	if count < 1 {
+		count = 1
+	}
+	var out bytes.Buffer
+	for ; count > 0 && rl.pageLine < len(rl.pages); count-- {
+		out.WriteString(rl.pages[rl.pageLine])
+		out.WriteString("\r\n")
+		rl.pageLine++
+	}
+	if rl.pageLine < len(rl.pages) {
+		out.WriteString("--More--")
+		rl.stdout.Write(out.Bytes())
+		rl.state = rlMore
+		return
+	}
+	rl.stdout.Write(out.Bytes())
+	rl.endList(prompt)
+}
+
+// endList redraws the prompt and the line below the completion
+// list.
+func (rl *Readline) endList(prompt string) {
+	rl.pages = nil
+	rl.state = rlStart
+	fmt.Fprintf(rl.stdout, "%s", prompt)
+	rl.row = 0
+	rl.refresh(prompt)
+}
+
+// rlQuery handles the "Display all" query.
+func rlQuery(rl *Readline, r rune, prompt string) bool {
+	switch r {
+	case 'y', 'Y', ' ':
+		fmt.Fprintf(rl.stdout, "\r\n")
+		rl.showPage(rl.height-1, prompt)
+
+	case 'n', 'N', 0x07, 0x03, 0x08, 0x7f: // C-g, C-c, Backspace
+		fmt.Fprintf(rl.stdout, "\r\n")
+		rl.endList(prompt)
+	}
+	return false
+}
+
+// rlMore handles the "--More--" pager prompt.
+func rlMore(rl *Readline, r rune, prompt string) bool {
+	var out bytes.Buffer
+	out.WriteByte('\r')
+	vt100.EraseLineTail(&out)
+	rl.stdout.Write(out.Bytes())
+
+	switch r {
+	case ' ':
+		rl.showPage(rl.height-1, prompt)
+
+	case '\r', '\n', 'j':
+		rl.showPage(1, prompt)
+
+	case 'q', 'Q', 'n', 'N', 0x07, 0x03, 0x08, 0x7f: // C-g, C-c, Backspace
+		rl.endList(prompt)
+
+	default:
+		// Keep waiting for a valid response.
+		rl.stdout.Write([]byte("--More--"))
+	}
+	return false
+}
+
+// startMenu starts the menu completion. The menu is shown only if it
+// fits below the line and if the line ends with the common prefix of
+// the completions.
+func (rl *Readline) startMenu(completions []string, prompt string) bool {
+	quote := rl.Quote
+	if quote == nil {
+		quote = func(s string) string { return s }
+	}
+	line := rl.line()
+	common := CommonPrefix(completions)
+	suffix := quote(common)
+	if !strings.HasSuffix(line, suffix) {
+		suffix = common
+		if !strings.HasSuffix(line, suffix) {
+			return false
+		}
+		quote = func(s string) string { return s }
+	}
+	lines := columnize(completions, rl.width, -1)
+	if len(lines)+rl.endRow(prompt)+1 >= rl.height {
+		return false
+	}
+	rl.menu = completions
+	rl.menuIdx = -1
+	rl.menuBase = strings.TrimSuffix(line, suffix)
+	rl.menuQuote = quote
+	rl.state = rlMenu
+	rl.drawMenu(prompt)
+	return true
+}
+
+// rlMenu cycles the menu completion candidates with Tab. Other input
+// closes the menu and is processed in the normal editing mode.
+func rlMenu(rl *Readline, r rune, prompt string) bool {
+	if r == 0x09 {
+		rl.menuIdx = (rl.menuIdx + 1) % len(rl.menu)
+		rl.setLine(rl.menuBase + rl.menuQuote(rl.menu[rl.menuIdx]))
+		rl.refresh(prompt)
+		rl.drawMenu(prompt)
+		return false
+	}
+	rl.menu = nil
+	rl.state = rlStart
+	// Erase the menu.
+	rl.refresh(prompt)
+	return rl.state(rl, r, prompt)
+}
+
+// drawMenu draws the menu below the line and returns the cursor to
+// its position on the line.
+func (rl *Readline) drawMenu(prompt string) {
+	var out bytes.Buffer
+
+	lines := columnize(rl.menu, rl.width, rl.menuIdx)
+	if down := rl.endRow(prompt) - rl.row; down > 0 {
+		fmt.Fprintf(&out, "\x1b[%dB", down)
+	}
+	for _, line := range lines {
+		out.WriteString("\r\n")
+		out.WriteString(line)
+	}
+	fmt.Fprintf(&out, "\x1b[%dA\r", rl.endRow(prompt)-rl.row+len(lines))
+	if col := (displayWidth(prompt) + rl.cursor) % rl.width; col > 0 {
+		fmt.Fprintf(&out, "\x1b[%dC", col)
+	}
+	rl.stdout.Write(out.Bytes())
+}
+
+// endRow returns the terminal row, relative to the prompt, where the
+// line ends.
+func (rl *Readline) endRow(prompt string) int {
+	return (displayWidth(prompt) + len(rl.buf)) / rl.width
+}
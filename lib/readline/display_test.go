@@ -0,0 +1,133 @@
+//
+// display_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package readline
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestColumnize(t *testing.T) {
+	items := []string{"a", "bb", "ccc", "d", "e"}
+
+	lines := columnize(items, 80, -1)
+	if !reflect.DeepEqual(lines, []string{"a    bb   ccc  d    e"}) {
+		t.Errorf("columnize=%q", lines)
+	}
+	lines = columnize(items, 12, -1)
+	expected := []string{"a    d", "bb   e", "ccc"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("columnize=%q, expected %q", lines, expected)
+	}
+	lines = columnize(items, 2, 1)
+	if len(lines) != 5 || lines[1] != selectStart+"bb"+selectEnd {
+		t.Errorf("columnize=%q", lines)
+	}
+}
+
+func newTestCompletion(out *bytes.Buffer, items []string) *Readline {
+	rl := NewReadline(strings.NewReader(""), out, ioutil.Discard)
+	rl.width = 20
+	rl.height = 5
+	rl.Tab = func(line string) (string, []string) {
+		return Expand(line, FilterPrefix(items, line))
+	}
+	return rl
+}
+
+func makeItems(n int) []string {
+	var items []string
+	for i := 0; i < n; i++ {
+		items = append(items, fmt.Sprintf("item%03d", i))
+	}
+	return items
+}
+
+func TestPager(t *testing.T) {
+	var out bytes.Buffer
+	rl := newTestCompletion(&out, makeItems(30))
+
+	// 30 items in two columns, 4 lines per page.
+	feed(rl, "i\t")
+	if !strings.HasSuffix(out.String(), "--More--") || rl.pageLine != 4 {
+		t.Fatalf("pager: pageLine=%d, output %q", rl.pageLine, out.String())
+	}
+	feed(rl, "\r")
+	if rl.pageLine != 5 {
+		t.Errorf("pager: pageLine=%d, expected 5", rl.pageLine)
+	}
+	feed(rl, "q")
+	if rl.pages != nil {
+		t.Errorf("pager not terminated")
+	}
+	if feed(rl, "x") || rl.line() != "item0x" {
+		t.Errorf("line=%q, expected item0x", rl.line())
+	}
+}
+
+func TestQuery(t *testing.T) {
+	var out bytes.Buffer
+	rl := newTestCompletion(&out, makeItems(30))
+	rl.QueryItems = 10
+
+	feed(rl, "i\t")
+	if !strings.HasSuffix(out.String(), "Display all 30 possibilities? (y or n)") {
+		t.Fatalf("query: output %q", out.String())
+	}
+	feed(rl, "xn")
+	if rl.pages != nil || rl.line() != "item0" {
+		t.Errorf("query: pages=%q, line=%q", rl.pages, rl.line())
+	}
+
+	feed(rl, "\ty    ")
+	if rl.pages != nil || strings.Contains(out.String()[len(out.String())-30:],
+		"--More--") {
+		t.Errorf("query: list not completed: %q", out.String())
+	}
+}
+
+func TestMenuSelect(t *testing.T) {
+	var out bytes.Buffer
+	rl := newTestCompletion(&out, []string{"ab c", "ab d", "x"})
+	rl.MenuSelect = true
+	rl.Quote = func(s string) string {
+		return strings.ReplaceAll(s, " ", "\\ ")
+	}
+	rl.Tab = func(line string) (string, []string) {
+		return "cat ab\\ ", []string{"ab c", "ab d"}
+	}
+
+	feed(rl, "cat a\t")
+	if rl.menu == nil || rl.menuBase != "cat " {
+		t.Fatalf("menu=%q, base=%q", rl.menu, rl.menuBase)
+	}
+	feed(rl, "\t")
+	if rl.line() != "cat ab\\ c" {
+		t.Errorf("menu: line=%q", rl.line())
+	}
+	feed(rl, "\t\t")
+	if rl.line() != "cat ab\\ c" {
+		t.Errorf("menu: line=%q", rl.line())
+	}
+	if feed(rl, "x") || rl.menu != nil || rl.line() != "cat ab\\ cx" {
+		t.Errorf("menu: line=%q", rl.line())
+	}
+
+	// The menu does not fit the terminal.
+	rl.height = 2
+	rl.setLine("cat a")
+	feed(rl, "\t")
+	if rl.menu != nil {
+		t.Errorf("menu started on a small terminal")
+	}
+}
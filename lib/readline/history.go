@@ -0,0 +1,121 @@
+//
+// history.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package readline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// History implements a command history ring. The history entries
+// are numbered from 1 and the numbers remain stable when old entries
+// are dropped from the ring.
+type History struct {
+	entries []string
+	max     int
+	dropped int
+}
+
+// NewHistory creates a new history which holds at most max entries.
+func NewHistory(max int) *History {
+	if max < 1 {
+		max = 1
+	}
+	return &History{
+		max: max,
+	}
+}
+
+// Add adds the line to the history. Empty lines and lines which
+// repeat the latest entry are ignored. The function returns true if
+// the line was added.
+func (h *History) Add(line string) bool {
+	if len(strings.TrimSpace(line)) == 0 {
+		return false
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return false
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > h.max {
+		drop := len(h.entries) - h.max
+		h.entries = append([]string(nil), h.entries[drop:]...)
+		h.dropped += drop
+	}
+	return true
+}
+
+// Len returns the number of entries in the history.
+func (h *History) Len() int {
+	return len(h.entries)
+}
+
+// First returns the number of the oldest history entry.
+func (h *History) First() int {
+	return h.dropped + 1
+}
+
+// Last returns the number of the latest history entry. The Last is
+// smaller than First if the history is empty.
+func (h *History) Last() int {
+	return h.dropped + len(h.entries)
+}
+
+// Get returns the history entry with the number n.
+func (h *History) Get(n int) (string, bool) {
+	idx := n - h.First()
+	if idx < 0 || idx >= len(h.entries) {
+		return "", false
+	}
+	return h.entries[idx], true
+}
+
+// Clear removes all history entries.
+func (h *History) Clear() {
+	h.dropped += len(h.entries)
+	h.entries = nil
+}
+
+// Search searches the history backwards for an entry containing
+// query. The search starts from the entry number from. The function
+// returns the number of the matching entry or 0 if no match was
+// found.
+func (h *History) Search(query string, from int) int {
+	if from > h.Last() {
+		from = h.Last()
+	}
+	for n := from; n >= h.First(); n-- {
+		if strings.Contains(h.entries[n-h.First()], query) {
+			return n
+		}
+	}
+	return 0
+}
+
+// Load reads history entries from the reader. Each line of the input
+// is added as a history entry.
+func (h *History) Load(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		h.Add(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// Save writes the history entries to the writer, one entry per line.
+func (h *History) Save(w io.Writer) error {
+	for _, entry := range h.entries {
+		if _, err := fmt.Fprintln(w, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
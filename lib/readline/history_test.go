@@ -0,0 +1,140 @@
+//
+// history_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package readline
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	h := NewHistory(3)
+	for _, line := range []string{"a", "b", "b", " ", "c", "d"} {
+		h.Add(line)
+	}
+	if h.Len() != 3 || h.First() != 2 || h.Last() != 4 {
+		t.Fatalf("unexpected history: len=%d, first=%d, last=%d",
+			h.Len(), h.First(), h.Last())
+	}
+	if _, ok := h.Get(1); ok {
+		t.Errorf("dropped entry found")
+	}
+	if line, _ := h.Get(3); line != "c" {
+		t.Errorf("Get(3)=%q, expected c", line)
+	}
+
+	var buf bytes.Buffer
+	if err := h.Save(&buf); err != nil {
+		t.Fatalf("Save failed: %s", err)
+	}
+	if buf.String() != "b\nc\nd\n" {
+		t.Errorf("unexpected saved history %q", buf.String())
+	}
+	loaded := NewHistory(2)
+	if err := loaded.Load(&buf); err != nil {
+		t.Fatalf("Load failed: %s", err)
+	}
+	if loaded.Len() != 2 || loaded.Last() != 3 {
+		t.Errorf("unexpected loaded history: len=%d, last=%d",
+			loaded.Len(), loaded.Last())
+	}
+
+	h.Clear()
+	if h.Len() != 0 || h.First() != 5 {
+		t.Errorf("unexpected cleared history: len=%d, first=%d",
+			h.Len(), h.First())
+	}
+}
+
+func TestHistorySearch(t *testing.T) {
+	h := NewHistory(10)
+	for _, line := range []string{"ls -l", "cat foo", "ls /bin"} {
+		h.Add(line)
+	}
+	tests := []struct {
+		query  string
+		from   int
+		result int
+	}{
+		{"ls", 10, 3},
+		{"ls", 2, 1},
+		{"cat", 3, 2},
+		{"xyz", 3, 0},
+	}
+	for _, test := range tests {
+		if n := h.Search(test.query, test.from); n != test.result {
+			t.Errorf("Search(%q, %d)=%d, expected %d", test.query, test.from,
+				n, test.result)
+		}
+	}
+}
+
+func newTestReadline(lines ...string) *Readline {
+	rl := NewReadline(strings.NewReader(""), ioutil.Discard, ioutil.Discard)
+	rl.History = NewHistory(10)
+	for _, line := range lines {
+		rl.History.Add(line)
+	}
+	rl.histPos = rl.History.Last() + 1
+	return rl
+}
+
+func feed(rl *Readline, input string) bool {
+	for _, b := range []byte(input) {
+		if rl.input(b, "$ ") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHistoryNavigation(t *testing.T) {
+	rl := newTestReadline("first", "second")
+
+	feed(rl, "new\x1b[A")
+	if rl.line() != "second" {
+		t.Errorf("up: got %q, expected second", rl.line())
+	}
+	feed(rl, "\x10\x10")
+	if rl.line() != "first" {
+		t.Errorf("C-p: got %q, expected first", rl.line())
+	}
+	feed(rl, "\x1b[B\x0e")
+	if rl.line() != "new" {
+		t.Errorf("down: got %q, expected new", rl.line())
+	}
+}
+
+func TestHistoryReverseSearch(t *testing.T) {
+	rl := newTestReadline("ls -l", "cat foo", "ls /bin")
+
+	feed(rl, "\x12ls")
+	if rl.match != 3 {
+		t.Errorf("search: got match %d, expected 3", rl.match)
+	}
+	feed(rl, "\x12")
+	if rl.match != 1 {
+		t.Errorf("C-r: got match %d, expected 1", rl.match)
+	}
+	if !feed(rl, "\r") || rl.line() != "ls -l" {
+		t.Errorf("accept: got %q, expected ls -l", rl.line())
+	}
+
+	rl = newTestReadline("ls -l")
+	feed(rl, "x\x12ls\x07")
+	if rl.line() != "x" {
+		t.Errorf("C-g: got %q, expected x", rl.line())
+	}
+	feed(rl, "\x12ls\x05y")
+	if rl.line() != "ls -ly" {
+		t.Errorf("edit: got %q, expected ls -ly", rl.line())
+	}
+}
@@ -13,9 +13,11 @@ import (
 )
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"unicode"
+	"unicode/utf8"
 )
 
 // TabCompletion provides tab completions for the line.
@@ -30,41 +32,88 @@ const (
 	MaskAsterisk
 )
 
-// Readline implements interactive line reader.
+const (
+	defaultWidth = 80
+	killRingSize = 10
+)
+
+// Readline implements interactive line reader. If History is set,
+// the previous lines can be recalled with the up and down arrows,
+// and searched with C-r.
+//
+// The tab completions are listed below the line. If there are more
+// than QueryItems completions, the user is asked if all completions
+// are displayed. If MenuSelect is set, Tab cycles through the
+// completions which are inserted into the line with the Quote
+// function.
 type Readline struct {
-	Tab    TabCompletion
-	Mask   Mask
-	stdin  io.Reader
-	stdout io.Writer
-	stderr io.Writer
-	buf    []byte
-	state  rlState
-	cursor int
-	tail   int
+	Tab        TabCompletion
+	Mask       Mask
+	History    *History
+	MenuSelect bool
+	QueryItems int
+	Quote      func(string) string
+	stdin      io.Reader
+	stdout     io.Writer
+	stderr     io.Writer
+	buf        []rune
+	state      rlState
+	cursor     int
+	width      int
+	height     int
+	row        int
+	pending    []byte
+	params     []byte
+	histPos    int
+	saved      string
+	query      string
+	match      int
+	orig       string
+	killRing   []string
+	killed     bool
+	yanked     int
+	pages      []string
+	pageLine   int
+	menu       []string
+	menuIdx    int
+	menuBase   string
+	menuQuote  func(string) string
 }
 
-type rlState func(rl *Readline, b byte, prompt string) bool
+type rlState func(rl *Readline, r rune, prompt string) bool
 
 // NewReadline creates a new readline instance.
 func NewReadline(stdin io.Reader, stdout, stderr io.Writer) *Readline {
 	return &Readline{
-		stdin:  stdin,
-		stdout: stdout,
-		stderr: stderr,
-		buf:    make([]byte, 1024),
-		state:  rlStart,
+		QueryItems: defaultQueryItems,
+		stdin:      stdin,
+		stdout:     stdout,
+		stderr:     stderr,
+		state:      rlStart,
+		width:      defaultWidth,
+		height:     defaultHeight,
 	}
 }
 
 func (rl *Readline) Read(prompt string) (string, error) {
-	flags, err := MakeRaw(rl.stdin)
+	termios, err := MakeRaw(rl.stdin)
 	if err != nil {
 		return "", err
 	}
-	defer MakeCooked(rl.stdin, flags)
+	defer MakeCooked(rl.stdin, termios)
 
+	rl.width, rl.height = termSize(rl.stdout)
+	rl.buf = nil
 	rl.cursor = 0
-	rl.tail = 0
+	rl.row = 0
+	rl.state = rlStart
+	rl.pending = nil
+	if rl.History != nil {
+		rl.histPos = rl.History.Last() + 1
+	}
+	rl.saved = ""
+	rl.killed = false
+	rl.yanked = 0
 	fmt.Fprintf(rl.stdout, "%s", prompt)
 
 	var buf [1]byte
@@ -74,194 +123,528 @@ func (rl *Readline) Read(prompt string) (string, error) {
 			return rl.line(), err
 		}
 		if rl.input(buf[0], prompt) {
-			// Line read.
+			// Line read. Move the cursor to the end of the line.
+			rl.cursor = len(rl.buf)
+			rl.refresh(prompt)
 			return rl.line(), nil
 		}
 	}
 }
 
 func (rl *Readline) line() string {
-	return string(rl.buf[:rl.tail])
+	return string(rl.buf)
 }
 
+// input processes the input byte b. The bytes are collected until
+// they form a complete UTF-8 encoded rune.
 func (rl *Readline) input(b byte, prompt string) bool {
-	return rl.state(rl, b, prompt)
-}
-
-func (rl *Readline) output(b []byte) {
-	switch rl.Mask {
-	case MaskNone:
-		rl.stdout.Write(b)
-
-	case MaskAsterisk:
-		for i := 0; i < len(b); i++ {
-			rl.stdout.Write([]byte{'*'})
-		}
+	rl.pending = append(rl.pending, b)
+	if !utf8.FullRune(rl.pending) {
+		return false
 	}
+	r, _ := utf8.DecodeRune(rl.pending)
+	rl.pending = rl.pending[:0]
+
+	return rl.state(rl, r, prompt)
 }
 
-func rlStart(rl *Readline, b byte, prompt string) bool {
-	switch b {
+func rlStart(rl *Readline, r rune, prompt string) bool {
+	killed := rl.killed
+	rl.killed = false
+	yanked := rl.yanked
+	rl.yanked = 0
+
+	switch r {
 	case 0x1b: // ESC
+		rl.killed = killed
+		rl.yanked = yanked
 		rl.state = rlESC
 		return false
 
 	case 0x01: // C-a
-		for rl.cursor > 0 {
-			vt100.Backspace(rl.stdout)
-			rl.cursor--
-		}
+		rl.moveTo(0, prompt)
 
 	case 0x02: // C-b
-		rl.cursorLeft()
+		rl.moveTo(rl.cursor-1, prompt)
 
 	case 0x04: // C-d
-		if rl.cursor < rl.tail {
-			vt100.DeleteChar(rl.stdout)
-			rl.cursor++
-			rl.delete()
-		}
+		rl.deleteRange(rl.cursor, rl.cursor+1, prompt)
 
 	case 0x05: // C-e
-		for rl.cursor < rl.tail {
-			rl.cursorRight()
-		}
+		rl.moveTo(len(rl.buf), prompt)
 
 	case 0x06: // C-f
-		rl.cursorRight()
+		rl.moveTo(rl.cursor+1, prompt)
 
 	case 0x09: // TAB
-		if rl.Tab != nil {
-			line, completions := rl.Tab(rl.line())
-
-			// Line contains expanded line.
-			for rl.cursor > 0 {
-				vt100.Backspace(rl.stdout)
-				rl.cursor--
-			}
-			vt100.EraseLineTail(rl.stdout)
-
-			l := []byte(line)
-			rl.tail = copy(rl.buf, l)
-			rl.cursor = rl.tail
-
-			rl.output(rl.buf[:rl.tail])
-
-			// Print completions.
-			if len(completions) > 0 {
-				fmt.Fprintf(rl.stdout, "\n")
-				Tabulate(completions, rl.stdout)
-				fmt.Fprintf(rl.stdout, "%s", prompt)
-				rl.output(rl.buf[:rl.tail])
-			}
-		}
+		rl.complete(prompt)
 
 	case 0x0b: // C-k
-		rl.tail = rl.cursor
-		vt100.EraseLineTail(rl.stdout)
+		rl.kill(rl.cursor, len(rl.buf), false, killed, prompt)
 
 	case 0x0c: // C-l
 		vt100.EraseScreen(rl.stdout)
 		vt100.MoveTo(rl.stdout, 0, 0)
 		fmt.Fprintf(rl.stdout, "%s", prompt)
-		rl.output(rl.buf[:rl.tail])
+		rl.row = 0
+		rl.refresh(prompt)
+
+	case 0x0e: // C-n
+		rl.historyNext(prompt)
+
+	case 0x10: // C-p
+		rl.historyPrev(prompt)
+
+	case 0x12: // C-r
+		if rl.History != nil {
+			rl.orig = rl.line()
+			rl.query = ""
+			rl.match = 0
+			rl.state = rlSearch
+			rl.showSearch()
+		}
 
-	case 0x7f: // Delete
-		if rl.cursor == 0 {
-			break
+	case 0x15: // C-u
+		rl.kill(0, rl.cursor, true, killed, prompt)
+
+	case 0x17: // C-w
+		start := rl.cursor
+		for start > 0 && unicode.IsSpace(rl.buf[start-1]) {
+			start--
 		}
-		vt100.Backspace(rl.stdout)
-		if rl.cursor == rl.tail {
-			vt100.EraseLineTail(rl.stdout)
-		} else {
-			vt100.DeleteChar(rl.stdout)
+		for start > 0 && !unicode.IsSpace(rl.buf[start-1]) {
+			start--
 		}
-		rl.delete()
+		rl.kill(start, rl.cursor, true, killed, prompt)
+
+	case 0x19: // C-y
+		rl.yank(prompt)
+
+	case 0x08, 0x7f: // Backspace
+		rl.deleteRange(rl.cursor-1, rl.cursor, prompt)
 
 	default:
-		if b == '\n' || b == '\r' {
+		if r == '\n' || r == '\r' {
 			return true
 		}
-		if unicode.IsPrint(rune(b)) {
-			rl.insert(b)
-
-			// Print line.
-			rl.output(rl.buf[rl.cursor-1 : rl.tail])
-
-			// Move cursor back to its position.
-			for i := rl.tail; i > rl.cursor; i-- {
-				vt100.Backspace(rl.stdout)
-			}
+		if unicode.IsPrint(r) {
+			rl.insert([]rune{r}, prompt)
 		} else {
-			fmt.Fprintf(rl.stderr, "readline: skipping non-printable 0x%x\n", b)
+			fmt.Fprintf(rl.stderr, "readline: skipping non-printable 0x%x\n", r)
 		}
 	}
 	return false
 }
 
-func rlESC(rl *Readline, b byte, prompt string) bool {
-	switch b {
+func rlESC(rl *Readline, r rune, prompt string) bool {
+	killed := rl.killed
+	rl.killed = false
+	yanked := rl.yanked
+	rl.yanked = 0
+	rl.state = rlStart
+
+	switch r {
 	case '[':
+		rl.params = rl.params[:0]
 		rl.state = rlCSI
-
+	case 'O':
+		rl.state = rlSS3
+	case 'b', 'B':
+		rl.moveTo(rl.wordStart(), prompt)
+	case 'f', 'F':
+		rl.moveTo(rl.wordEnd(), prompt)
+	case 'd', 'D':
+		rl.kill(rl.cursor, rl.wordEnd(), false, killed, prompt)
+	case 0x7f, 0x08:
+		rl.kill(rl.wordStart(), rl.cursor, true, killed, prompt)
+	case 'y', 'Y':
+		rl.yankPop(yanked, prompt)
 	default:
-		fmt.Fprintf(rl.stderr, "readline: ESC: unsupported: b=0x%x", b)
-		rl.state = rlStart
+		fmt.Fprintf(rl.stderr, "readline: ESC: unsupported: 0x%x\n", r)
 	}
 	return false
 }
 
-func rlCSI(rl *Readline, b byte, prompt string) bool {
-	switch b {
+func rlCSI(rl *Readline, r rune, prompt string) bool {
+	if r >= '0' && r <= '9' || r == ';' {
+		rl.params = append(rl.params, byte(r))
+		return false
+	}
+	rl.state = rlStart
+
+	switch r {
+	case 'A':
+		rl.historyPrev(prompt)
+	case 'B':
+		rl.historyNext(prompt)
 	case 'C':
-		rl.cursorRight()
+		rl.moveTo(rl.cursor+1, prompt)
 	case 'D':
-		rl.cursorLeft()
+		rl.moveTo(rl.cursor-1, prompt)
+	case 'H':
+		rl.moveTo(0, prompt)
+	case 'F':
+		rl.moveTo(len(rl.buf), prompt)
+	case '~':
+		switch string(rl.params) {
+		case "1", "7":
+			rl.moveTo(0, prompt)
+		case "4", "8":
+			rl.moveTo(len(rl.buf), prompt)
+		case "3":
+			rl.deleteRange(rl.cursor, rl.cursor+1, prompt)
+		default:
+			fmt.Fprintf(rl.stderr, "readline: CSI: unsupported: %s~\n",
+				rl.params)
+		}
 	default:
-		fmt.Fprintf(rl.stderr, "readline: CSI: unsupported: b=0x%x", b)
+		fmt.Fprintf(rl.stderr, "readline: CSI: unsupported: 0x%x\n", r)
 	}
+	return false
+}
+
+func rlSS3(rl *Readline, r rune, prompt string) bool {
 	rl.state = rlStart
+
+	switch r {
+	case 'H':
+		rl.moveTo(0, prompt)
+	case 'F':
+		rl.moveTo(len(rl.buf), prompt)
+	default:
+		fmt.Fprintf(rl.stderr, "readline: SS3: unsupported: 0x%x\n", r)
+	}
+	return false
+}
+
+// rlSearch implements the reverse incremental history search.
+func rlSearch(rl *Readline, r rune, prompt string) bool {
+	switch r {
+	case 0x12: // C-r
+		if len(rl.query) > 0 && rl.match > 0 {
+			if n := rl.History.Search(rl.query, rl.match-1); n > 0 {
+				rl.match = n
+			}
+		}
+
+	case 0x07: // C-g
+		rl.setLine(rl.orig)
+		rl.state = rlStart
+		rl.refresh(prompt)
+		return false
+
+	case 0x08, 0x7f: // Backspace
+		if len(rl.query) > 0 {
+			q := []rune(rl.query)
+			rl.query = string(q[:len(q)-1])
+			rl.match = rl.History.Search(rl.query, rl.History.Last())
+		}
+
+	default:
+		if unicode.IsPrint(r) {
+			rl.query += string(r)
+			from := rl.match
+			if from == 0 {
+				from = rl.History.Last()
+			}
+			rl.match = rl.History.Search(rl.query, from)
+			break
+		}
+		// Accept the match and process the input in the normal
+		// editing mode.
+		if rl.match > 0 {
+			line, _ := rl.History.Get(rl.match)
+			rl.setLine(line)
+			rl.histPos = rl.match
+		} else {
+			rl.setLine(rl.orig)
+		}
+		rl.state = rlStart
+		rl.refresh(prompt)
+		return rl.state(rl, r, prompt)
+	}
+	rl.showSearch()
 	return false
 }
 
-func (rl *Readline) cursorLeft() {
-	if rl.cursor > 0 {
-		vt100.Backspace(rl.stdout)
-		rl.cursor--
+func (rl *Readline) showSearch() {
+	var line string
+	if rl.match > 0 {
+		line, _ = rl.History.Get(rl.match)
+	}
+	label := "reverse-i-search"
+	if rl.match == 0 && len(rl.query) > 0 {
+		label = "failing " + label
 	}
+	runes := []rune(line)
+	rl.draw(fmt.Sprintf("(%s)`%s': ", label, rl.query), runes, len(runes))
 }
 
-func (rl *Readline) cursorRight() {
-	if rl.cursor < rl.tail {
-		vt100.CursorForward(rl.stdout)
-		rl.cursor++
+// historyPrev replaces the line with the previous history entry.
+func (rl *Readline) historyPrev(prompt string) {
+	if rl.History == nil || rl.histPos <= rl.History.First() {
+		return
 	}
+	if rl.histPos > rl.History.Last() {
+		rl.saved = rl.line()
+	}
+	rl.histPos--
+	line, _ := rl.History.Get(rl.histPos)
+	rl.setLine(line)
+	rl.refresh(prompt)
 }
 
-func (rl *Readline) insert(b byte) bool {
-	if rl.tail >= len(rl.buf) {
-		return false
+// historyNext replaces the line with the next history entry or with
+// the line being edited before the history navigation.
+func (rl *Readline) historyNext(prompt string) {
+	if rl.History == nil || rl.histPos > rl.History.Last() {
+		return
+	}
+	rl.histPos++
+	line, ok := rl.History.Get(rl.histPos)
+	if !ok {
+		line = rl.saved
+	}
+	rl.setLine(line)
+	rl.refresh(prompt)
+}
+
+// complete runs the tab completion for the line.
+func (rl *Readline) complete(prompt string) {
+	if rl.Tab == nil {
+		return
+	}
+	line, completions := rl.Tab(rl.line())
+	rl.setLine(line)
+	rl.refresh(prompt)
+
+	if len(completions) == 0 {
+		return
+	}
+	if rl.MenuSelect && rl.startMenu(completions, prompt) {
+		return
+	}
+	rl.listCompletions(completions, prompt)
+}
+
+// setLine sets the line buffer and moves the cursor to the end of
+// the line.
+func (rl *Readline) setLine(line string) {
+	rl.buf = []rune(line)
+	rl.cursor = len(rl.buf)
+}
+
+func (rl *Readline) moveTo(pos int, prompt string) {
+	if pos < 0 {
+		pos = 0
 	}
+	if pos > len(rl.buf) {
+		pos = len(rl.buf)
+	}
+	if pos == rl.cursor {
+		return
+	}
+	rl.cursor = pos
+	rl.refresh(prompt)
+}
+
+func (rl *Readline) insert(runes []rune, prompt string) {
+	n := append([]rune(nil), rl.buf[:rl.cursor]...)
+	n = append(n, runes...)
+	rl.buf = append(n, rl.buf[rl.cursor:]...)
+	rl.cursor += len(runes)
+	rl.refresh(prompt)
+}
 
-	if rl.cursor < rl.tail {
-		for i := rl.tail + 1; i > rl.cursor; i-- {
-			rl.buf[i] = rl.buf[i-1]
+// deleteRange deletes the runes [start:end] of the line and returns
+// the deleted runes.
+func (rl *Readline) deleteRange(start, end int, prompt string) string {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(rl.buf) {
+		end = len(rl.buf)
+	}
+	if start >= end {
+		return ""
+	}
+	deleted := string(rl.buf[start:end])
+	rl.buf = append(rl.buf[:start], rl.buf[end:]...)
+	rl.cursor = start
+	rl.refresh(prompt)
+	return deleted
+}
+
+// kill deletes the runes [start:end] and saves them to the kill
+// ring. If append is true, the consecutive kills are merged into the
+// latest kill ring entry. The backward specifies if the deleted text
+// is before the cursor.
+func (rl *Readline) kill(start, end int, backward, append bool,
+	prompt string) {
+
+	deleted := rl.deleteRange(start, end, prompt)
+	rl.killed = true
+	if len(deleted) == 0 {
+		return
+	}
+	if append && len(rl.killRing) > 0 {
+		last := len(rl.killRing) - 1
+		if backward {
+			rl.killRing[last] = deleted + rl.killRing[last]
+		} else {
+			rl.killRing[last] += deleted
 		}
+		return
 	}
-	rl.buf[rl.cursor] = b
+	rl.killRing = pushRing(rl.killRing, deleted)
+}
 
-	rl.cursor++
-	rl.tail++
+func pushRing(ring []string, s string) []string {
+	ring = append(ring, s)
+	if len(ring) > killRingSize {
+		ring = ring[len(ring)-killRingSize:]
+	}
+	return ring
+}
 
-	return true
+// yank inserts the latest kill ring entry.
+func (rl *Readline) yank(prompt string) {
+	if len(rl.killRing) == 0 {
+		return
+	}
+	text := []rune(rl.killRing[len(rl.killRing)-1])
+	rl.insert(text, prompt)
+	rl.yanked = len(text)
 }
 
-func (rl *Readline) delete() {
-	if rl.cursor == rl.tail {
-		rl.cursor--
-	} else {
-		rl.cursor--
-		rl.buf = append(rl.buf[0:rl.cursor], rl.buf[rl.cursor+1:]...)
+// yankPop replaces the yanked text with the previous kill ring
+// entry. The yanked is the length of the text inserted by the
+// previous yank.
+func (rl *Readline) yankPop(yanked int, prompt string) {
+	if yanked == 0 || len(rl.killRing) == 0 {
+		return
+	}
+	// Rotate the kill ring.
+	last := len(rl.killRing) - 1
+	rl.killRing = append(rl.killRing[last:], rl.killRing[:last]...)
+
+	text := []rune(rl.killRing[last])
+	start := rl.cursor - yanked
+	n := append([]rune(nil), rl.buf[:start]...)
+	n = append(n, text...)
+	rl.buf = append(n, rl.buf[rl.cursor:]...)
+	rl.cursor = start + len(text)
+	rl.yanked = len(text)
+	rl.refresh(prompt)
+}
+
+// wordStart returns the start position of the word before the
+// cursor.
+func (rl *Readline) wordStart() int {
+	pos := rl.cursor
+	for pos > 0 && !isWordRune(rl.buf[pos-1]) {
+		pos--
+	}
+	for pos > 0 && isWordRune(rl.buf[pos-1]) {
+		pos--
+	}
+	return pos
+}
+
+// wordEnd returns the end position of the word after the cursor.
+func (rl *Readline) wordEnd() int {
+	pos := rl.cursor
+	for pos < len(rl.buf) && !isWordRune(rl.buf[pos]) {
+		pos++
+	}
+	for pos < len(rl.buf) && isWordRune(rl.buf[pos]) {
+		pos++
+	}
+	return pos
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// refresh redraws the prompt and the line.
+func (rl *Readline) refresh(prompt string) {
+	rl.draw(prompt, rl.buf, rl.cursor)
+}
+
+// draw draws the prompt and the line, and moves the cursor to the
+// position cursor. The line can wrap over multiple terminal rows.
+func (rl *Readline) draw(prompt string, line []rune, cursor int) {
+	var out bytes.Buffer
+
+	// Move to the start of the prompt and erase old contents.
+	if rl.row > 0 {
+		fmt.Fprintf(&out, "\x1b[%dA", rl.row)
+	}
+	out.WriteByte('\r')
+	vt100.EraseScreenTail(&out)
+
+	out.WriteString(prompt)
+	switch rl.Mask {
+	case MaskNone:
+		out.WriteString(string(line))
+	case MaskAsterisk:
+		out.Write(bytes.Repeat([]byte{'*'}, len(line)))
+	}
+
+	width := rl.width
+	if width <= 0 {
+		width = defaultWidth
+	}
+	plen := displayWidth(prompt)
+	end := plen + len(line)
+	endRow := end / width
+	if end > 0 && end%width == 0 {
+		// Move the cursor to the next row from the pending wrap
+		// position.
+		out.WriteString("\r\n")
+	}
+
+	pos := plen + cursor
+	row := pos / width
+	if endRow > row {
+		fmt.Fprintf(&out, "\x1b[%dA", endRow-row)
+	}
+	out.WriteByte('\r')
+	if col := pos % width; col > 0 {
+		fmt.Fprintf(&out, "\x1b[%dC", col)
+	}
+	rl.row = row
+
+	rl.stdout.Write(out.Bytes())
+}
+
+// displayWidth returns the number of terminal columns the string
+// uses. The terminal escape sequences do not take any space.
+func displayWidth(s string) int {
+	var width int
+	var esc, csi bool
+
+	for _, r := range s {
+		switch {
+		case csi:
+			if r >= 0x40 && r <= 0x7e {
+				csi = false
+				esc = false
+			}
+		case esc:
+			if r == '[' {
+				csi = true
+			} else {
+				esc = false
+			}
+		case r == 0x1b:
+			esc = true
+		case r == '\r' || r == '\n':
+			width = 0
+		case unicode.IsPrint(r):
+			width++
+		}
 	}
-	rl.tail--
+	return width
 }
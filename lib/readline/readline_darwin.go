@@ -8,14 +8,21 @@ package readline
 
 import (
 	"io"
+
+	"github.com/markkurossi/blackbox-os/lib/bbos"
 )
 
 // MakeRaw enables raw input and disables echo.
-func MakeRaw(stdin io.Reader) (uint, error) {
-	return 0, nil
+func MakeRaw(stdin io.Reader) (*bbos.Termios, error) {
+	return &bbos.Termios{}, nil
 }
 
-// MakeCooked enables the input mode based on flags.
-func MakeCooked(stdin io.Reader, flags uint) error {
+// MakeCooked restores the terminal settings returned by MakeRaw.
+func MakeCooked(stdin io.Reader, termios *bbos.Termios) error {
 	return nil
 }
+
+// termSize returns the terminal width and height of the output.
+func termSize(out io.Writer) (int, int) {
+	return defaultWidth, defaultHeight
+}
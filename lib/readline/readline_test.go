@@ -0,0 +1,109 @@
+//
+// readline_test.go
+//
+// Copyright (c) 2021 Markku Rossi
+//
+// All rights reserved.
+//
+
+package readline
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var editTests = []struct {
+	input  string
+	line   string
+	cursor int
+}{
+	{"äö€x", "äö€x", 4},
+	{"äö€\x02\x7f", "ä€", 1},
+	{"abc\x01\x04", "bc", 0},
+	{"abc\x1b[D\x1b[D\x1b[3~", "ac", 1},
+	{"abc\x1b[1~x\x1b[4~y", "xabcy", 5},
+	{"abc\x1bOHx\x1bOFy", "xabcy", 5},
+	{"foo bar-baz\x1bb\x1bb", "foo bar-baz", 4},
+	{"foo bar\x01\x1bf\x1bf", "foo bar", 7},
+	{"foo bar baz\x01\x1bf\x1bd", "foo baz", 3},
+	{"foo bar\x1b\x7f", "foo ", 4},
+	{"foo bar  \x17", "foo ", 4},
+	{"foo bar\x15", "", 0},
+	{"foo bar\x01\x06\x0b", "f", 1},
+	{"foo bar\x17\x17\x19", "foo bar", 7},
+	{"foo bar\x17x \x19", "foo x bar", 9},
+	{"a b\x17\x17c \x19\x1by", "c a b", 5},
+	{"one two\x17\x01\x0b\x19\x1by", "two", 3},
+}
+
+func TestEdit(t *testing.T) {
+	for _, test := range editTests {
+		rl := NewReadline(strings.NewReader(""), ioutil.Discard,
+			ioutil.Discard)
+		if feed(rl, test.input) {
+			t.Errorf("%q: unexpected end of line", test.input)
+		}
+		if rl.line() != test.line || rl.cursor != test.cursor {
+			t.Errorf("%q: got %q@%d, expected %q@%d", test.input,
+				rl.line(), rl.cursor, test.line, test.cursor)
+		}
+	}
+}
+
+func TestKillRing(t *testing.T) {
+	rl := NewReadline(strings.NewReader(""), ioutil.Discard, ioutil.Discard)
+	for i := 0; i < killRingSize+5; i++ {
+		feed(rl, "x\x17")
+	}
+	if len(rl.killRing) != killRingSize {
+		t.Errorf("kill ring size %d, expected %d", len(rl.killRing),
+			killRingSize)
+	}
+}
+
+func TestDraw(t *testing.T) {
+	var out bytes.Buffer
+	rl := NewReadline(strings.NewReader(""), &out, ioutil.Discard)
+	rl.width = 10
+
+	// "$ " + 12 runes wraps to the second row.
+	feed(rl, "abcdefghijkl")
+	if rl.row != 1 {
+		t.Errorf("row=%d, expected 1", rl.row)
+	}
+	out.Reset()
+	feed(rl, "\x01")
+	if rl.row != 0 {
+		t.Errorf("row=%d, expected 0", rl.row)
+	}
+	expected := "\x1b[1A\r\x1b[J$ abcdefghijkl\x1b[1A\r\x1b[2C"
+	if out.String() != expected {
+		t.Errorf("draw=%q, expected %q", out.String(), expected)
+	}
+
+	// The line ends at the terminal edge.
+	rl = NewReadline(strings.NewReader(""), &out, ioutil.Discard)
+	rl.width = 10
+	out.Reset()
+	feed(rl, "abcdefgh")
+	if !strings.HasSuffix(out.String(), "$ abcdefgh\r\n\r") || rl.row != 1 {
+		t.Errorf("draw=%q, row=%d", out.String(), rl.row)
+	}
+}
+
+func TestDisplayWidth(t *testing.T) {
+	tests := map[string]int{
+		"$ ":                    2,
+		"\x1b[1;32mbbos\x1b[0m": 4,
+		"äö":                    2,
+		"a\nbc":                 2,
+	}
+	for input, width := range tests {
+		if w := displayWidth(input); w != width {
+			t.Errorf("displayWidth(%q)=%d, expected %d", input, w, width)
+		}
+	}
+}
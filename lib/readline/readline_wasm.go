@@ -14,32 +14,48 @@ import (
 	"github.com/markkurossi/blackbox-os/lib/bbos"
 )
 
-// MakeRaw enables raw input and disables echo.
-func MakeRaw(stdin io.Reader) (uint, error) {
+// MakeRaw enables raw input and disables echo. It returns the
+// original terminal settings which are restored with MakeCooked.
+func MakeRaw(stdin io.Reader) (*bbos.Termios, error) {
 	switch fd := stdin.(type) {
 	case *os.File:
-		flags, err := bbos.GetFlags(int(fd.Fd()))
+		orig, err := bbos.Tcgetattr(int(fd.Fd()))
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
-		err = bbos.SetFlags(int(fd.Fd()), flags & ^(1|2))
+		raw := *orig
+		raw.MakeRaw()
+		err = bbos.Tcsetattr(int(fd.Fd()), &raw)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
-		return uint(flags), nil
+		return orig, nil
 
 	default:
-		return 0, fmt.Errorf("unsupported fd: %T", fd)
+		return nil, fmt.Errorf("unsupported fd: %T", fd)
 	}
 }
 
-// MakeCooked enables the input mode based on flags.
-func MakeCooked(stdin io.Reader, flags uint) error {
+// MakeCooked restores the terminal settings returned by MakeRaw.
+func MakeCooked(stdin io.Reader, termios *bbos.Termios) error {
 	switch fd := stdin.(type) {
 	case *os.File:
-		return bbos.SetFlags(int(fd.Fd()), int(flags))
+		return bbos.Tcsetattr(int(fd.Fd()), termios)
 
 	default:
 		return fmt.Errorf("unsupported fd: %T", fd)
 	}
 }
+
+// termSize returns the terminal width and height of the output.
+func termSize(out io.Writer) (int, int) {
+	f, ok := out.(*os.File)
+	if !ok {
+		return defaultWidth, defaultHeight
+	}
+	ws, err := bbos.GetWinSize(int(f.Fd()))
+	if err != nil || ws.Cols <= 0 || ws.Rows <= 0 {
+		return defaultWidth, defaultHeight
+	}
+	return ws.Cols, ws.Rows
+}
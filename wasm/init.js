@@ -7,6 +7,7 @@
 //
 
 var keyboardHandler;
+var resizeHandler;
 var display;
 var loader;
 
@@ -32,6 +33,12 @@ function initJavaScript(displayId) {
             keyboardHandler(ev);
         }
     })
+    window.addEventListener('resize', function(ev) {
+        display.computeSize();
+        if (resizeHandler) {
+            resizeHandler(display.width, display.height);
+        }
+    })
     if (false) {
         document.addEventListener('keyup', function(ev) {
             if (ev.metaKey) {
@@ -69,17 +76,22 @@ function initKeyboard(keyboard) {
     keyboardHandler = keyboard;
 }
 
+function initResize(resize) {
+    resizeHandler = resize;
+}
+
 function init(keyboard, mouse, input) {
     keyboardHandler = keyboard;
 }
 
 function uninit() {
     keyboardHandler = undefined;
+    resizeHandler = undefined;
 }
 
 /***************************** Process handling *****************************/
 
-function syscallSpawn(onSyscall, onError, pid, code, ...argv) {
+function syscallSpawn(onSyscall, onError, pid, ppid, code, env, ...argv) {
     const worker = new Worker("process.js?_ts=" + new Date().getTime());
 
     worker.onmessage = function(e) {
@@ -93,7 +105,9 @@ function syscallSpawn(onSyscall, onError, pid, code, ...argv) {
     worker.postMessage({
         cmd: "init",
         pid: pid,
+        ppid: ppid,
         argv: argv,
+        env: env,
         code: code,
     })
 
@@ -23,23 +23,25 @@ function syscall_open(path, flags, mode, callback) {
     });
 }
 
-function syscall_write(fd, buf, offset, length, callback) {
+function syscall_write(fd, buf, offset, length, position, callback) {
     syscall({
         cmd: "write",
         fd: fd,
         data: buf,
         offset: offset,
-        length: length
+        length: length,
+        position: position
     }, {
         cb: callback
     });
 }
 
-function syscall_read(fd, buf, offset, length, callback) {
+function syscall_read(fd, buf, offset, length, position, callback) {
     syscall({
         cmd: "read",
         fd: fd,
-        length: length
+        length: length,
+        position: position
     }, {
         cb: callback,
         buf: buf,
@@ -47,13 +49,80 @@ function syscall_read(fd, buf, offset, length, callback) {
     });
 }
 
+function syscall_close(fd, callback) {
+    syscall({
+        cmd: "close",
+        fd: fd
+    }, {
+        cb: callback
+    });
+}
+
+function syscall_mkdir(path, perm, callback) {
+    syscall({
+        cmd: "mkdir",
+        path: path,
+        perm: perm
+    }, {
+        cb: callback
+    });
+}
+
+function syscall_unlink(path, callback) {
+    syscall({
+        cmd: "unlink",
+        path: path
+    }, {
+        cb: callback
+    });
+}
+
+function syscall_rmdir(path, callback) {
+    syscall({
+        cmd: "rmdir",
+        path: path
+    }, {
+        cb: callback
+    });
+}
+
+function syscall_rename(from, to, callback) {
+    syscall({
+        cmd: "rename",
+        from: from,
+        to: to
+    }, {
+        cb: callback
+    });
+}
+
+function syscall_truncate(path, length, callback) {
+    syscall({
+        cmd: "truncate",
+        path: path,
+        length: length
+    }, {
+        cb: callback
+    });
+}
+
+function syscall_ftruncate(fd, length, callback) {
+    syscall({
+        cmd: "ftruncate",
+        fd: fd,
+        length: length
+    }, {
+        cb: callback
+    });
+}
+
 function makeFileInfo(obj) {
     if (obj) {
         obj.isDirectory = function() {
-            return (obj.mode & 0040000) != 0;
+            return (obj.mode & 0170000) == 0040000;
         }
         obj.isFile = function() {
-            return (obj.mode & 0100000) != 0;
+            return (obj.mode & 0170000) == 0100000;
         }
     }
     return obj
@@ -122,7 +191,15 @@ function processEvent(e) {
         let go = new Go();
 
         go.argv = e.data.argv || ["wasm"];
+        go.env = {};
+        for (const kv of e.data.env || []) {
+            const idx = kv.indexOf("=");
+            if (idx > 0) {
+                go.env[kv.substring(0, idx)] = kv.substring(idx + 1);
+            }
+        }
         global.process.pid = e.data.pid;
+        global.process.ppid = e.data.ppid;
 
         let mod, inst;
         console.time("WebAssembly")
@@ -157,6 +234,12 @@ function processEvent(e) {
             });
         break;
 
+    case "signal":
+        if (global.signalHandler) {
+            global.signalHandler(e.data.signal);
+        }
+        break;
+
     case "result":
         let ctx = syscall_pending.get(e.data.id);
         if (!ctx) {
@@ -176,9 +259,9 @@ function processEvent(e) {
                 if (ctx.buf) {
                     ctx.buf.set(e.data.buf, ctx.offset || 0);
                 }
-                ctx.cb(err, e.data.code, e.data.buf);
+                ctx.cb(err, e.data.code, e.data.buf, e.data.obj);
             } else {
-                ctx.cb(err, e.data.code);
+                ctx.cb(err, e.data.code, undefined, e.data.obj);
             }
         }
         break;
@@ -20,7 +20,14 @@ const einval = () => {
 };
 
 global.fs = {
-    constants: { O_WRONLY: -1, O_RDWR: -1, O_CREAT: -1, O_TRUNC: -1, O_APPEND: -1, O_EXCL: -1 }, // unused
+    constants: {
+        O_WRONLY: 01,
+        O_RDWR: 02,
+        O_CREAT: 0100,
+        O_EXCL: 0200,
+        O_TRUNC: 01000,
+        O_APPEND: 02000,
+    },
     writeSync(fd, buf) {
 	outputBuf += decoder.decode(buf);
 	const nl = outputBuf.lastIndexOf("\n");
@@ -31,49 +38,59 @@ global.fs = {
 	return buf.length;
     },
     write(fd, buffer, offset, length, position, callback) {
-        if (position != null) {
-	    callback(einval());
-	    return;
-        }
-        syscall_write(fd, buffer, offset, length, callback);
+        syscall_write(fd, buffer, offset, length, position, callback);
     },
     chmod(path, mode, callback) { callback(enosys()); },
     chown(path, uid, gid, callback) { callback(enosys()); },
-    close(fd, callback) { callback(enosys()); },
+    close(fd, callback) {
+        syscall_close(fd, callback);
+    },
     fchmod(fd, mode, callback) { callback(enosys()); },
     fchown(fd, uid, gid, callback) { callback(enosys()); },
     fstat(fd, callback) {
         syscall_fstat(fd, callback);
     },
     fsync(fd, callback) { callback(null); },
-    ftruncate(fd, length, callback) { callback(enosys()); },
+    ftruncate(fd, length, callback) {
+        syscall_ftruncate(fd, length, callback);
+    },
     lchown(path, uid, gid, callback) { callback(enosys()); },
     link(path, link, callback) { callback(enosys()); },
     lstat(path, callback) {
         syscall_stat(path, callback);
     },
-    mkdir(path, perm, callback) { callback(enosys()); },
+    mkdir(path, perm, callback) {
+        syscall_mkdir(path, perm, callback);
+    },
     open(path, flags, mode, callback) {
         syscall_open(path, flags, mode, callback);
     },
     read(fd, buffer, offset, length, position, callback) {
-        if (offset < 0 || offset + length > buffer.length || position != null) {
+        if (offset < 0 || offset + length > buffer.length) {
             callback(einval());
             return
         }
-        syscall_read(fd, buffer, offset, length, callback);
+        syscall_read(fd, buffer, offset, length, position, callback);
     },
     readdir(path, callback) {
         syscall_readdir(path, callback);
     },
     readlink(path, callback) { callback(enosys()); },
-    rename(from, to, callback) { callback(enosys()); },
-    rmdir(path, callback) { callback(enosys()); },
+    rename(from, to, callback) {
+        syscall_rename(from, to, callback);
+    },
+    rmdir(path, callback) {
+        syscall_rmdir(path, callback);
+    },
     stat(path, callback) {
         syscall_stat(path, callback);
     },
     symlink(path, link, callback) { callback(enosys()); },
-    truncate(path, length, callback) { callback(enosys()); },
-    unlink(path, callback) { callback(enosys()); },
+    truncate(path, length, callback) {
+        syscall_truncate(path, length, callback);
+    },
+    unlink(path, callback) {
+        syscall_unlink(path, callback);
+    },
     utimes(path, atime, mtime, callback) { callback(enosys()); },
 };